@@ -5,10 +5,12 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tanagraspace/pocket-plus/implementations/go/pocketplus"
 )
@@ -47,6 +49,7 @@ func printHelp(progName string) {
 	fmt.Println("Usage:")
 	fmt.Printf("  %s <input> <packet_size> <pt> <ft> <rt> <robustness>\n", progName)
 	fmt.Printf("  %s -d <input.pkt> <packet_size> <robustness>\n", progName)
+	fmt.Printf("  %s bench <input> <packet_size> <robustness>\n", progName)
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -d             Decompress (default is compress)")
@@ -66,6 +69,13 @@ func printHelp(progName string) {
 	fmt.Println("  packet_size    Original packet size in bytes")
 	fmt.Println("  robustness     Robustness level (must match compression)")
 	fmt.Println()
+	fmt.Println("Bench arguments:")
+	fmt.Println("  input          Input file to evaluate (nothing is written)")
+	fmt.Println("  packet_size    Packet size in bytes")
+	fmt.Println("  robustness     Robustness level 1-7")
+	fmt.Println("  Compresses with a sweep of pt/ft/rt values and prints the ratio,")
+	fmt.Println("  compress and decompress speed, and round-trip result of each.")
+	fmt.Println()
 	fmt.Println("Output:")
 	fmt.Println("  Compress:   <input>.pkt")
 	fmt.Println("  Decompress: <input>.depkt (or <base>.depkt if input ends in .pkt)")
@@ -73,6 +83,7 @@ func printHelp(progName string) {
 	fmt.Println("Examples:")
 	fmt.Printf("  %s data.bin 90 10 20 50 1        # compress\n", progName)
 	fmt.Printf("  %s -d data.bin.pkt 90 1          # decompress\n", progName)
+	fmt.Printf("  %s bench data.bin 90 1           # compare parameters\n", progName)
 	fmt.Println()
 }
 
@@ -167,6 +178,115 @@ func doDecompress(inputPath string, packetSize, robustness int) int {
 	return 0
 }
 
+// Parameter grid swept by bench; only combinations with pt <= ft <= rt
+// are run.
+var (
+	benchPt = []int{5, 10, 20, 50}
+	benchFt = []int{20, 50, 100}
+	benchRt = []int{50, 100, 200}
+)
+
+// benchMinDuration is how long each measurement repeats its operation, so
+// small inputs still give stable speeds.
+const benchMinDuration = 100 * time.Millisecond
+
+// measure runs fn repeatedly for at least benchMinDuration and returns the
+// mean time per run, or the first error.
+func measure(fn func() error) (time.Duration, error) {
+	runs := 0
+	start := time.Now()
+	for {
+		if err := fn(); err != nil {
+			return 0, err
+		}
+		runs++
+		if elapsed := time.Since(start); elapsed >= benchMinDuration {
+			return elapsed / time.Duration(runs), nil
+		}
+	}
+}
+
+func doBench(inputPath string, packetSize, robustness int) int {
+	inputData, err := os.ReadFile(inputPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Cannot open input file: %s\n", inputPath)
+		return 1
+	}
+
+	if len(inputData) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: Input file is empty")
+		return 1
+	}
+
+	if len(inputData)%packetSize != 0 {
+		fmt.Fprintf(os.Stderr, "Error: Input size (%d) not divisible by packet size (%d)\n",
+			len(inputData), packetSize)
+		return 1
+	}
+
+	mb := float64(len(inputData)) / 1e6
+	fmt.Printf("Input:       %s (%d bytes, %d packets)\n", inputPath, len(inputData), len(inputData)/packetSize)
+	fmt.Printf("Parameters:  packet_size=%d, R=%d\n", packetSize, robustness)
+	fmt.Println()
+	fmt.Printf("%4s %4s %4s %8s %12s %14s  %s\n", "pt", "ft", "rt", "ratio", "comp MB/s", "decomp MB/s", "round-trip")
+
+	failed := 0
+	bestRatio := 0.0
+	var best string
+	for _, pt := range benchPt {
+		for _, ft := range benchFt {
+			for _, rt := range benchRt {
+				if pt > ft || ft > rt {
+					continue
+				}
+
+				var compressed, decompressed []byte
+				compTime, err := measure(func() (err error) {
+					compressed, err = pocketplus.Compress(inputData, packetSize, robustness, pt, ft, rt)
+					return err
+				})
+				if err != nil {
+					fmt.Printf("%4d %4d %4d  FAIL: compression: %v\n", pt, ft, rt, err)
+					failed++
+					continue
+				}
+				decompTime, err := measure(func() (err error) {
+					decompressed, err = pocketplus.Decompress(compressed, packetSize, robustness)
+					return err
+				})
+				if err != nil {
+					fmt.Printf("%4d %4d %4d  FAIL: decompression: %v\n", pt, ft, rt, err)
+					failed++
+					continue
+				}
+
+				ratio := float64(len(inputData)) / float64(len(compressed))
+				status := "ok"
+				if !bytes.Equal(decompressed, inputData) {
+					status = "FAIL"
+					failed++
+				} else if ratio > bestRatio {
+					bestRatio = ratio
+					best = fmt.Sprintf("pt=%d, ft=%d, rt=%d", pt, ft, rt)
+				}
+				fmt.Printf("%4d %4d %4d %7.2fx %12.1f %14.1f  %s\n", pt, ft, rt, ratio,
+					mb/compTime.Seconds(), mb/decompTime.Seconds(), status)
+			}
+		}
+	}
+
+	fmt.Println()
+	if best != "" {
+		fmt.Printf("Best:        %s (%.2fx)\n", best, bestRatio)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %d combination(s) failed\n", failed)
+		return 1
+	}
+
+	return 0
+}
+
 func main() {
 	args := os.Args
 	progName := args[0]
@@ -186,6 +306,29 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Bench mode: bench <input> <packet_size> <robustness>
+	if args[1] == "bench" {
+		if len(args) != 5 {
+			fmt.Fprintln(os.Stderr, "Error: Bench requires 3 arguments after bench")
+			fmt.Fprintf(os.Stderr, "Usage: %s bench <input> <packet_size> <robustness>\n", progName)
+			os.Exit(1)
+		}
+
+		packetSize, err := strconv.Atoi(args[3])
+		if err != nil || packetSize <= 0 || packetSize > pocketplus.MaxPacketSize {
+			fmt.Fprintf(os.Stderr, "Error: packet_size must be 1-%d bytes\n", pocketplus.MaxPacketSize)
+			os.Exit(1)
+		}
+
+		robustness, err := strconv.Atoi(args[4])
+		if err != nil || robustness < 1 || robustness > 7 {
+			fmt.Fprintln(os.Stderr, "Error: robustness must be 1-7")
+			os.Exit(1)
+		}
+
+		os.Exit(doBench(args[2], packetSize, robustness))
+	}
+
 	// Check for decompress flag
 	decompressMode := false
 	argOffset := 1
@@ -204,8 +347,8 @@ func main() {
 
 		inputPath := args[argOffset]
 		packetSize, err := strconv.Atoi(args[argOffset+1])
-		if err != nil || packetSize <= 0 || packetSize > 8192 {
-			fmt.Fprintln(os.Stderr, "Error: packet_size must be 1-8192 bytes")
+		if err != nil || packetSize <= 0 || packetSize > pocketplus.MaxPacketSize {
+			fmt.Fprintf(os.Stderr, "Error: packet_size must be 1-%d bytes\n", pocketplus.MaxPacketSize)
 			os.Exit(1)
 		}
 
@@ -226,8 +369,8 @@ func main() {
 
 		inputPath := args[1]
 		packetSize, err := strconv.Atoi(args[2])
-		if err != nil || packetSize <= 0 || packetSize > 8192 {
-			fmt.Fprintln(os.Stderr, "Error: packet_size must be 1-8192 bytes")
+		if err != nil || packetSize <= 0 || packetSize > pocketplus.MaxPacketSize {
+			fmt.Fprintf(os.Stderr, "Error: packet_size must be 1-%d bytes\n", pocketplus.MaxPacketSize)
 			os.Exit(1)
 		}
 
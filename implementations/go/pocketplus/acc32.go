@@ -0,0 +1,12 @@
+//go:build pocketplus_acc32 || 386 || arm || mips || mipsle
+
+package pocketplus
+
+// accWord is the BitBuffer accumulator. 32-bit targets use a 32-bit
+// accumulator to avoid multi-register shifts; appends that would not fit
+// next to the (at most 7) pending bits are split in two. Build with the
+// pocketplus_acc32 tag to select this variant on 64-bit targets.
+type accWord = uint32
+
+// accBits is the width of accWord in bits.
+const accBits = 32
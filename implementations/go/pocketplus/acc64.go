@@ -0,0 +1,10 @@
+//go:build !pocketplus_acc32 && !386 && !arm && !mips && !mipsle
+
+package pocketplus
+
+// accWord is the BitBuffer accumulator. 64-bit targets use a 64-bit
+// accumulator, so a 32-bit word always fits next to pending bits.
+type accWord = uint64
+
+// accBits is the width of accWord in bits.
+const accBits = 64
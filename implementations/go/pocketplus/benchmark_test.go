@@ -1,6 +1,8 @@
 package pocketplus
 
 import (
+	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
@@ -161,3 +163,451 @@ func BenchmarkDecompressVenusExpress(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkCountPacketsVenusExpress(b *testing.B) {
+	input, err := os.ReadFile(filepath.Join(getTestVectorsPath(), "input", "venus-express.ccsds"))
+	if err != nil {
+		b.Skip("Could not load venus-express.ccsds")
+	}
+
+	compressed, err := Compress(input, 90, 2, 20, 50, 100)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		_, err := CountPackets(compressed, 90, 2)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkRLEDecode encodes a 720-bit vector with every step-th bit set
+// and measures decoding it.
+func benchmarkRLEDecode(b *testing.B, step int) {
+	bv, _ := NewBitVector(720)
+	for i := 0; i < 720; i += step {
+		bv.SetBit(i, 1)
+	}
+
+	bb := NewBitBuffer()
+	if err := RLEEncode(bb, bv); err != nil {
+		b.Fatal(err)
+	}
+	encoded := bb.ToBytes()
+	numBits := bb.NumBits()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		br := NewBitReaderWithBits(encoded, numBits)
+		if _, err := RLEDecode(br, 720); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRLEDecodeSparse(b *testing.B) {
+	// Typical mask change: a handful of bits
+	benchmarkRLEDecode(b, 97)
+}
+
+func BenchmarkRLEDecodeDense(b *testing.B) {
+	benchmarkRLEDecode(b, 2)
+}
+
+// BenchmarkRLEEncodeDense encodes a 720-bit vector whose runs cycle
+// through all three COUNT forms ('0', '110' and '111' prefixes), the
+// COUNT-heavy case of a dense mask.
+func BenchmarkRLEEncodeDense(b *testing.B) {
+	bv, _ := NewBitVector(720)
+	gaps := []int{1, 2, 7, 20, 35}
+	for pos, i := 0, 0; pos < 720; pos, i = pos+gaps[i%len(gaps)], i+1 {
+		bv.SetBit(pos, 1)
+	}
+	bb := NewBitBuffer()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		bb.Clear()
+		if err := RLEEncode(bb, bv); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetSetBitVsWord copies a 720-bit vector bit by bit through
+// GetBit/SetBit and word by word, showing the per-bit indexing cost.
+func BenchmarkGetSetBitVsWord(b *testing.B) {
+	src, _ := NewBitVector(720)
+	for i := 0; i < 720; i += 3 {
+		src.SetBit(i, 1)
+	}
+	dst, _ := NewBitVector(720)
+
+	b.Run("GetSetBit", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < src.length; j++ {
+				dst.SetBit(j, src.GetBit(j))
+			}
+		}
+	})
+
+	b.Run("Word", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for w := 0; w < src.numWords; w++ {
+				dst.data[w] = src.data[w]
+			}
+		}
+	})
+}
+
+func benchmarkVector720() *BitVector {
+	bv, _ := NewBitVector(720)
+	for i := 0; i < 720; i += 3 {
+		bv.SetBit(i, 1)
+	}
+	return bv
+}
+
+func BenchmarkAppendBitVector(b *testing.B) {
+	bv := benchmarkVector720()
+	bb := NewBitBuffer()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bb.Clear()
+		bb.AppendBit(1) // Unaligned start
+		bb.AppendBitVector(bv)
+	}
+}
+
+func BenchmarkAppendBits(b *testing.B) {
+	data := benchmarkVector720().ToBytes()
+	bb := NewBitBuffer()
+
+	b.Run("Aligned", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bb.Clear()
+			bb.AppendBits(data, 720)
+		}
+	})
+	b.Run("Unaligned", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bb.Clear()
+			bb.AppendBit(1)
+			bb.AppendBits(data, 720)
+		}
+	})
+	b.Run("BitLoop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bb.Clear()
+			for j := 0; j < 720; j++ {
+				bb.AppendBit(int(data[j/8]>>(7-j%8)) & 1)
+			}
+		}
+	})
+}
+
+func BenchmarkReverse(b *testing.B) {
+	bv := benchmarkVector720()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bv.Reverse()
+	}
+}
+
+func BenchmarkReadBitsIntoVector(b *testing.B) {
+	bb := NewBitBuffer()
+	bb.AppendBit(1) // Unaligned start
+	bb.AppendBitVector(benchmarkVector720())
+	data := bb.ToBytes()
+	dst, _ := NewBitVector(720)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br := NewBitReader(data)
+		br.Skip(1)
+		if err := br.ReadBitsIntoVector(dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBitExtractPooled(b *testing.B) {
+	data := benchmarkVector720()
+	mask, _ := NewBitVector(720)
+	for i := 0; i < 720; i += 7 {
+		mask.SetBit(i, 1)
+	}
+
+	b.Run("NewBuffer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bb := NewBitBuffer()
+			BitExtract(bb, data, mask)
+		}
+	})
+
+	b.Run("Pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bb := GetBitBuffer()
+			BitExtract(bb, data, mask)
+			PutBitBuffer(bb)
+		}
+	})
+}
+
+// generateTelemetry produces numPackets synthetic packets in which every bit
+// flips with the given probability from one packet to the next.
+func generateTelemetry(numPackets, packetSize int, density float64, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	data := make([]byte, numPackets*packetSize)
+
+	// Random initial packet, then independent bit flips per packet
+	rng.Read(data[:packetSize])
+	for i := 1; i < numPackets; i++ {
+		packet := data[i*packetSize : (i+1)*packetSize]
+		copy(packet, data[(i-1)*packetSize:i*packetSize])
+		for bit := 0; bit < packetSize*8; bit++ {
+			if rng.Float64() < density {
+				packet[bit/8] ^= 0x80 >> (bit % 8)
+			}
+		}
+	}
+
+	return data
+}
+
+var telemetryDensities = []float64{0.0001, 0.001, 0.01, 0.1}
+
+func BenchmarkCompressDensity(b *testing.B) {
+	for _, density := range telemetryDensities {
+		b.Run(fmt.Sprintf("density=%g", density), func(b *testing.B) {
+			input := generateTelemetry(2000, 90, density, 1)
+
+			var compressed []byte
+			b.ResetTimer()
+			b.SetBytes(int64(len(input)))
+
+			for i := 0; i < b.N; i++ {
+				var err error
+				compressed, err = Compress(input, 90, 2, 20, 50, 100)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(input))/float64(len(compressed)), "ratio")
+		})
+	}
+}
+
+func BenchmarkDecompressDensity(b *testing.B) {
+	for _, density := range telemetryDensities {
+		b.Run(fmt.Sprintf("density=%g", density), func(b *testing.B) {
+			input := generateTelemetry(2000, 90, density, 1)
+			compressed, err := Compress(input, 90, 2, 20, 50, 100)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			b.SetBytes(int64(len(input)))
+
+			for i := 0; i < b.N; i++ {
+				_, err := Decompress(compressed, 90, 2)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(input))/float64(len(compressed)), "ratio")
+		})
+	}
+}
+
+// BenchmarkDecompressCtPath decodes a stream compressed with pt=1, so the
+// mask is rebuilt every packet and ct=1 packets (extraction over Xt OR Mt)
+// dominate.
+func BenchmarkDecompressCtPath(b *testing.B) {
+	input := generateTelemetry(2000, 90, 0.001, 1)
+	compressed, err := Compress(input, 90, 2, 1, 50, 100)
+	if err != nil {
+		b.Fatal(err)
+	}
+	decomp, _ := NewDecompressor(720, nil, 2)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		decomp.Reset()
+		reader := NewBitReader(compressed)
+		for reader.Remaining() > 0 {
+			if _, err := decomp.DecompressPacket(reader); err != nil {
+				b.Fatal(err)
+			}
+			reader.AlignByte()
+		}
+	}
+}
+
+// BenchmarkExtractInsertMaskDensity covers BitExtract and BitInsert from a
+// sparse mask to a saturated one, where whole mask words are set.
+func BenchmarkExtractInsertMaskDensity(b *testing.B) {
+	data := benchmarkVector720()
+	for _, every := range []int{97, 7, 2, 1} {
+		mask, _ := NewBitVector(720)
+		for i := 0; i < 720; i += every {
+			mask.SetBit(i, 1)
+		}
+		bb := NewBitBuffer()
+		BitExtract(bb, data, mask)
+		extracted := bb.ToBytes()
+		dst, _ := NewBitVector(720)
+
+		b.Run(fmt.Sprintf("Extract/weight=%d", mask.HammingWeight()), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bb.Clear()
+				BitExtract(bb, data, mask)
+			}
+		})
+		b.Run(fmt.Sprintf("Insert/weight=%d", mask.HammingWeight()), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := BitInsert(NewBitReader(extracted), dst, mask); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecompressPacketInto decodes a stream into one reused output
+// vector; with RLEDecodeInto for Xt and the mask this allocates nothing
+// per packet, unlike DecompressPacket.
+func BenchmarkDecompressPacketInto(b *testing.B) {
+	input := generateTelemetry(2000, 90, 0.001, 1)
+	compressed, err := Compress(input, 90, 2, 10, 20, 50)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, reuse := range []bool{false, true} {
+		name := "DecompressPacket"
+		if reuse {
+			name = "DecompressPacketInto"
+		}
+		b.Run(name, func(b *testing.B) {
+			decomp, _ := NewDecompressor(720, nil, 2)
+			output, _ := NewBitVector(720)
+			reader := NewBitReader(compressed)
+
+			b.ResetTimer()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+
+			for i := 0; i < b.N; i++ {
+				decomp.Reset()
+				reader.position = 0
+				for reader.Remaining() > 0 {
+					if reuse {
+						err = decomp.DecompressPacketInto(reader, output)
+					} else {
+						_, err = decomp.DecompressPacket(reader)
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+					reader.AlignByte()
+				}
+			}
+		})
+	}
+}
+
+// loadHousekeepingCompressed returns the housekeeping vector input and its
+// compressed form for the streaming decode benchmarks.
+func loadHousekeepingCompressed(b *testing.B) (input, compressed []byte) {
+	b.Helper()
+	input, err := os.ReadFile(filepath.Join(getTestVectorsPath(), "input", "housekeeping.bin"))
+	if err != nil {
+		b.Skip("Could not load housekeeping.bin")
+	}
+	compressed, err = Compress(input, 90, 2, 20, 50, 100)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return input, compressed
+}
+
+// BenchmarkDecodeChannel, BenchmarkDecodeIterator and BenchmarkDecodePull
+// compare the three streaming decode APIs over the same stream. The
+// channel adds a goroutine and a send per packet, and it and the iterator
+// allocate each packet; the pull closure reuses one packet buffer.
+func BenchmarkDecodeChannel(b *testing.B) {
+	input, compressed := loadHousekeepingCompressed(b)
+	decomp, _ := NewDecompressor(720, nil, 2)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for packet := range decomp.StreamPackets(compressed, len(compressed)*8) {
+			n += len(packet)
+		}
+		if n != len(input) {
+			b.Fatalf("decoded %d bytes, expected %d", n, len(input))
+		}
+	}
+}
+
+func BenchmarkDecodeIterator(b *testing.B) {
+	input, compressed := loadHousekeepingCompressed(b)
+	decomp, _ := NewDecompressor(720, nil, 2)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		n := 0
+		it := decomp.NewPacketIterator(compressed, len(compressed)*8)
+		for packet := it.Next(); packet != nil; packet = it.Next() {
+			n += len(packet)
+		}
+		if it.Err() != nil || n != len(input) {
+			b.Fatalf("decoded %d bytes, expected %d: %v", n, len(input), it.Err())
+		}
+	}
+}
+
+func BenchmarkDecodePull(b *testing.B) {
+	input, compressed := loadHousekeepingCompressed(b)
+	decomp, _ := NewDecompressor(720, nil, 2)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		n := 0
+		next := decomp.Packets(compressed, len(compressed)*8)
+		for packet, ok := next(); ok; packet, ok = next() {
+			n += len(packet)
+		}
+		if n != len(input) {
+			b.Fatalf("decoded %d bytes, expected %d", n, len(input))
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package pocketplus
 
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
 // BitBuffer is a variable-length bit buffer for building compressed output.
 //
 // Bits are appended sequentially using MSB-first ordering as required by
@@ -11,9 +17,15 @@ package pocketplus
 type BitBuffer struct {
 	data    []byte
 	numBits int
-	// Accumulator for batch bit operations
-	acc    uint64 // accumulate up to 64 bits
-	accLen int    // number of bits in accumulator
+	// Accumulator for batch bit operations; fewer than 8 bits remain
+	// pending after every append (see acc64.go and acc32.go for its width)
+	acc    accWord
+	accLen int // number of bits in accumulator
+
+	// PadBit (0 or 1) fills the unused low bits of the final partial byte
+	// in ToBytes. Decoders skip these bits when aligning to the next
+	// packet, so any pattern decodes the same.
+	PadBit int
 }
 
 // NewBitBuffer creates a new empty bit buffer.
@@ -24,6 +36,27 @@ func NewBitBuffer() *BitBuffer {
 	}
 }
 
+// bitBufferPool recycles buffers handed out by GetBitBuffer.
+var bitBufferPool = sync.Pool{
+	New: func() any { return NewBitBuffer() },
+}
+
+// GetBitBuffer returns an empty BitBuffer from a shared pool. Callers
+// building many small outputs (e.g. repeated BitExtract calls) can use it
+// with PutBitBuffer to avoid a buffer allocation per call.
+func GetBitBuffer() *BitBuffer {
+	bb := bitBufferPool.Get().(*BitBuffer)
+	bb.Clear()
+	bb.PadBit = 0
+	return bb
+}
+
+// PutBitBuffer returns bb to the pool. The buffer must not be used
+// afterwards; slices already returned by its ToBytes remain valid.
+func PutBitBuffer(bb *BitBuffer) {
+	bitBufferPool.Put(bb)
+}
+
 // Clear resets the buffer to empty.
 func (bb *BitBuffer) Clear() {
 	bb.data = bb.data[:0]
@@ -50,7 +83,7 @@ func (bb *BitBuffer) flushAcc() {
 
 // AppendBit appends a single bit to the buffer.
 func (bb *BitBuffer) AppendBit(bit int) {
-	bb.acc = (bb.acc << 1) | uint64(bit&1)
+	bb.acc = (bb.acc << 1) | accWord(bit&1)
 	bb.accLen++
 	bb.numBits++
 
@@ -66,8 +99,16 @@ func (bb *BitBuffer) AppendBitsFromWord(word uint32, n int) {
 	if n <= 0 {
 		return
 	}
+	if bb.accLen+n > accBits {
+		// Only with a 32-bit accumulator: append the top and bottom
+		// halves separately
+		half := n / 2
+		bb.AppendBitsFromWord(word, half)
+		bb.AppendBitsFromWord(word<<half, n-half)
+		return
+	}
 	// Shift word so the n bits are at the top
-	bits := uint64(word >> (32 - n))
+	bits := accWord(word >> (32 - n))
 	bb.acc = (bb.acc << n) | bits
 	bb.accLen += n
 	bb.numBits += n
@@ -81,42 +122,36 @@ func (bb *BitBuffer) AppendBitsFromWord(word uint32, n int) {
 // AppendBits appends multiple bits from bytes.
 // Bits are read MSB-first from the source data.
 func (bb *BitBuffer) AppendBits(data []byte, numBits int) {
-	for i := 0; i < numBits; i++ {
-		byteIndex := i / 8
-		bitIndex := i % 8
+	if numBits <= 0 {
+		return
+	}
+	full := numBits / 8
+
+	if bb.accLen == 0 {
+		// Byte-aligned: whole bytes go straight into the output
+		bb.data = append(bb.data, data[:full]...)
+		bb.numBits += full * 8
+	} else {
+		for _, b := range data[:full] {
+			bb.AppendBitsFromWord(uint32(b)<<24, 8)
+		}
+	}
 
-		// Extract bits MSB-first (bit 7, 6, 5, ..., 0)
-		bit := (data[byteIndex] >> (7 - bitIndex)) & 1
-		bb.AppendBit(int(bit))
+	// Partial final byte
+	if rem := numBits % 8; rem > 0 {
+		bb.AppendBitsFromWord(uint32(data[full])<<24, rem)
 	}
 }
 
 // AppendBitVector appends all bits from a BitVector.
 func (bb *BitBuffer) AppendBitVector(bv *BitVector) {
-	// Calculate number of bytes from bit length
-	numBytes := (bv.length + 7) / 8
-
-	// CCSDS MSB-first: bytes in order, bits within each byte from MSB to LSB
-	for byteIdx := 0; byteIdx < numBytes; byteIdx++ {
-		bitsInThisByte := 8
-
-		// Last byte may have fewer than 8 bits
-		if byteIdx == numBytes-1 {
-			remainder := bv.length % 8
-			if remainder != 0 {
-				bitsInThisByte = remainder
-			}
-		}
-
-		// With MSB-first bitvector indexing: bit 0 is MSB, bit 7 is LSB
-		// We want to append bits in order: MSB first, LSB last
-		startBit := byteIdx * 8
-		for bitOffset := 0; bitOffset < bitsInThisByte; bitOffset++ {
-			pos := startBit + bitOffset
-			bit := bv.GetBit(pos)
-			bb.AppendBit(bit)
-		}
+	// CCSDS MSB-first: bit 0 of the vector is the MSB of its first word,
+	// so whole words can be appended directly
+	last := bv.numWords - 1
+	for w := 0; w < last; w++ {
+		bb.AppendBitsFromWord(bv.data[w], 32)
 	}
+	bb.AppendBitsFromWord(bv.data[last], bv.length-last*32)
 }
 
 // AppendBitVectorN appends the first n bits from a BitVector.
@@ -124,19 +159,33 @@ func (bb *BitBuffer) AppendBitVectorN(bv *BitVector, n int) {
 	if n > bv.length {
 		n = bv.length
 	}
-	for i := 0; i < n; i++ {
-		bb.AppendBit(bv.GetBit(i))
+	for w := 0; n > 0; w++ {
+		count := min(n, 32)
+		bb.AppendBitsFromWord(bv.data[w], count)
+		n -= count
 	}
 }
 
 // AppendValue appends a value as count bits (MSB-first).
+// count is clamped to 64.
 func (bb *BitBuffer) AppendValue(value uint64, count int) {
 	if count <= 0 {
 		return
 	}
+	if count > 64 {
+		count = 64
+	}
+	if bb.accLen+count > accBits {
+		// Pending bits would be shifted out of the accumulator before the
+		// flush, so append the value in two parts that each fit
+		low := count / 2
+		bb.AppendValue(value>>low, count-low)
+		bb.AppendValue(value, low)
+		return
+	}
 	// Mask to get only the bottom 'count' bits
 	mask := uint64((1 << count) - 1)
-	bb.acc = (bb.acc << count) | (value & mask)
+	bb.acc = (bb.acc << count) | accWord(value&mask)
 	bb.accLen += count
 	bb.numBits += count
 
@@ -145,44 +194,82 @@ func (bb *BitBuffer) AppendValue(value uint64, count int) {
 	}
 }
 
-// ToBytes converts buffer contents to bytes.
-func (bb *BitBuffer) ToBytes() []byte {
-	if bb.numBits == 0 {
-		return []byte{}
-	}
-
-	// Flush any remaining bits in accumulator
-	numBytes := (bb.numBits + 7) / 8
-
-	// Make sure we have enough space
-	for len(bb.data) < numBytes {
-		// Pad accumulator and flush
-		if bb.accLen > 0 {
-			// Pad to byte boundary
-			padBits := 8 - (bb.accLen % 8)
-			if padBits < 8 {
-				bb.acc <<= padBits
-				bb.accLen += padBits
-			}
-			bb.flushAcc()
+// AppendUnary appends n in unary: n '1' bits followed by a terminating '0'.
+// Negative n appends nothing.
+func (bb *BitBuffer) AppendUnary(n int) {
+	if n < 0 {
+		return
+	}
+	for n >= 32 {
+		bb.AppendBitsFromWord(0xFFFFFFFF, 32)
+		n -= 32
+	}
+	// n ones then the terminator, in n+1 <= 32 bits
+	bb.AppendValue(((uint64(1)<<n)-1)<<1, n+1)
+}
+
+// ReserveBits appends n zero bits and returns a handle for overwriting them
+// later with PatchBits, e.g. for a length field written before its payload.
+func (bb *BitBuffer) ReserveBits(n int) int {
+	handle := bb.numBits
+	for n > 0 {
+		count := min(n, 32)
+		bb.AppendValue(0, count)
+		n -= count
+	}
+	return handle
+}
+
+// PatchBits overwrites n bits (MSB-first, at most 64) starting at handle,
+// as returned by ReserveBits, with the low n bits of value. The bits may
+// already have been flushed from the accumulator.
+func (bb *BitBuffer) PatchBits(handle int, value uint64, n int) error {
+	if n < 0 || n > 64 {
+		return errors.New("PatchBits: n must be between 0 and 64")
+	}
+	if handle < 0 || handle+n > bb.numBits {
+		return fmt.Errorf("PatchBits: bits %d..%d outside buffer of %d bits", handle, handle+n-1, bb.numBits)
+	}
+
+	// Bits still in the accumulator start here
+	accStart := bb.numBits - bb.accLen
+
+	for i := 0; i < n; i++ {
+		pos := handle + i
+		bit := (value >> (n - 1 - i)) & 1
+
+		if pos >= accStart {
+			shift := bb.accLen - 1 - (pos - accStart)
+			bb.acc = bb.acc&^(1<<shift) | accWord(bit)<<shift
 		} else {
-			bb.data = append(bb.data, 0)
+			shift := 7 - pos%8
+			bb.data[pos/8] = bb.data[pos/8]&^(1<<shift) | byte(bit)<<shift
 		}
 	}
+	return nil
+}
 
-	// Handle any remaining bits in accumulator (< 8 bits)
+// ToBytes converts buffer contents to bytes, padding the final partial
+// byte with PadBit (zero by default). The buffer is not modified, so
+// appending may continue.
+func (bb *BitBuffer) ToBytes() []byte {
+	return bb.ToBytesPadded(bb.PadBit)
+}
+
+// ToBytesPadded is ToBytes with the unused low bits of the final partial
+// byte set to padBit (0 or 1) instead of PadBit, e.g. for transfer frames
+// that require an all-ones idle pattern.
+func (bb *BitBuffer) ToBytesPadded(padBit int) []byte {
+	// Complete bytes are already in data; at most 7 bits remain in the
+	// accumulator
+	result := make([]byte, (bb.numBits+7)/8)
+	copy(result, bb.data)
 	if bb.accLen > 0 {
-		// Pad to byte boundary and add
-		padBits := 8 - bb.accLen
-		lastByte := byte(bb.acc << padBits)
-		if len(bb.data) < numBytes {
-			bb.data = append(bb.data, lastByte)
-		} else {
-			bb.data[numBytes-1] = lastByte
+		last := byte(bb.acc << (8 - bb.accLen))
+		if padBit != 0 {
+			last |= 0xFF >> bb.accLen
 		}
+		result[len(bb.data)] = last
 	}
-
-	result := make([]byte, numBytes)
-	copy(result, bb.data[:numBytes])
 	return result
 }
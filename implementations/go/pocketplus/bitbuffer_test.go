@@ -180,6 +180,78 @@ func TestBitBufferAppendValueLarge(t *testing.T) {
 	}
 }
 
+func TestBitBufferAppendValueWide(t *testing.T) {
+	const value = 0xFEDCBA9876543210
+
+	for _, count := range []int{33, 40, 48, 56, 63, 64} {
+		expected := uint64(value)
+		if count < 64 {
+			expected &= (uint64(1) << count) - 1
+		}
+
+		// Aligned, and after a 1-bit prefix so the value straddles bytes
+		for _, prefix := range []int{0, 1} {
+			bb := NewBitBuffer()
+			bb.AppendValue(1, prefix)
+			bb.AppendValue(value, count)
+			bb.AppendBit(1)
+
+			if bb.NumBits() != prefix+count+1 {
+				t.Errorf("count %d prefix %d: expected %d bits, got %d",
+					count, prefix, prefix+count+1, bb.NumBits())
+			}
+
+			br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+			br.Skip(prefix)
+			got, err := br.ReadBits(count)
+			if err != nil {
+				t.Fatalf("count %d prefix %d: ReadBits error: %v", count, prefix, err)
+			}
+			if got != expected {
+				t.Errorf("count %d prefix %d: expected 0x%X, got 0x%X", count, prefix, expected, got)
+			}
+			if bit, _ := br.ReadBit(); bit != 1 {
+				t.Errorf("count %d prefix %d: trailing bit lost", count, prefix)
+			}
+		}
+	}
+}
+
+func TestBitBufferAppendValueAccumulatorOverflow(t *testing.T) {
+	// 7 pending bits plus a 60-bit value exceed the 64-bit accumulator
+	bb := NewBitBuffer()
+	bb.AppendValue(0x55, 7)                  // 1010101
+	bb.AppendValue(0xF123456789ABCDE, 60)    // 1111 0001 0010 ... 1110
+	bb.AppendValue(0x7FFFFFFFFFFFFFFF, 64-1) // Wide append at accLen 3
+
+	if bb.NumBits() != 7+60+63 {
+		t.Fatalf("Expected %d bits, got %d", 7+60+63, bb.NumBits())
+	}
+
+	// Check the exact bit sequence bit by bit
+	var expected []int
+	for i := 6; i >= 0; i-- {
+		expected = append(expected, int(0x55>>i)&1)
+	}
+	for i := 59; i >= 0; i-- {
+		expected = append(expected, int(uint64(0xF123456789ABCDE)>>i)&1)
+	}
+	for i := 0; i < 63; i++ {
+		expected = append(expected, 1)
+	}
+
+	br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	for i, want := range expected {
+		got, err := br.ReadBit()
+		if err != nil {
+			t.Fatalf("ReadBit %d error: %v", i, err)
+		}
+		if got != want {
+			t.Fatalf("Bit %d: expected %d, got %d", i, want, got)
+		}
+	}
+}
+
 func TestBitBufferClear(t *testing.T) {
 	bb := NewBitBuffer()
 	bb.AppendBit(1)
@@ -322,6 +394,44 @@ func TestBitBufferFlushAccumulator(t *testing.T) {
 	}
 }
 
+// TestBitBufferBulkAppendMatchesBitLoop checks the byte- and word-level
+// append paths against appending one bit at a time, for every length and
+// starting alignment.
+func TestBitBufferBulkAppendMatchesBitLoop(t *testing.T) {
+	src := make([]byte, 12)
+	for i := range src {
+		src[i] = byte(0x5B * (i + 1))
+	}
+	bv, _ := NewBitVector(len(src) * 8)
+	bv.FromBytes(src)
+
+	for offset := 0; offset < 8; offset++ {
+		for n := 0; n <= len(src)*8; n++ {
+			want := NewBitBuffer()
+			want.AppendValue(0x55, offset)
+			for i := 0; i < n; i++ {
+				want.AppendBit(int(src[i/8]>>(7-i%8)) & 1)
+			}
+
+			got := NewBitBuffer()
+			got.AppendValue(0x55, offset)
+			got.AppendBits(src, n)
+			if got.NumBits() != want.NumBits() || !bytes.Equal(got.ToBytes(), want.ToBytes()) {
+				t.Fatalf("AppendBits offset %d length %d: got %x, want %x",
+					offset, n, got.ToBytes(), want.ToBytes())
+			}
+
+			got = NewBitBuffer()
+			got.AppendValue(0x55, offset)
+			got.AppendBitVectorN(bv, n)
+			if got.NumBits() != want.NumBits() || !bytes.Equal(got.ToBytes(), want.ToBytes()) {
+				t.Fatalf("AppendBitVectorN offset %d length %d: got %x, want %x",
+					offset, n, got.ToBytes(), want.ToBytes())
+			}
+		}
+	}
+}
+
 func TestBitBufferAppendBitVectorNExceedsLength(t *testing.T) {
 	bb := NewBitBuffer()
 
@@ -355,3 +465,155 @@ func TestBitBufferAppendBitsFromWordZero(t *testing.T) {
 		t.Errorf("Expected 0 bits, got %d", bb.NumBits())
 	}
 }
+
+func TestBitBufferAppendUnary(t *testing.T) {
+	bb := NewBitBuffer()
+	bb.AppendUnary(3)
+	bb.AppendUnary(0)
+	bb.AppendUnary(2)
+
+	// 1110 0 110 -> 0xE6
+	if bb.NumBits() != 8 {
+		t.Errorf("Expected 8 bits, got %d", bb.NumBits())
+	}
+	if data := bb.ToBytes(); data[0] != 0xE6 {
+		t.Errorf("Expected 0xE6, got 0x%02X", data[0])
+	}
+
+	bb.Clear()
+	bb.AppendUnary(-1)
+	if bb.NumBits() != 0 {
+		t.Errorf("Expected negative n to append nothing, got %d bits", bb.NumBits())
+	}
+}
+
+func TestBitBufferPool(t *testing.T) {
+	bb := GetBitBuffer()
+	bb.AppendValue(0xAB, 8)
+	data := bb.ToBytes()
+	PutBitBuffer(bb)
+
+	// Buffers from the pool always start empty
+	bb = GetBitBuffer()
+	defer PutBitBuffer(bb)
+	if bb.NumBits() != 0 {
+		t.Errorf("Expected empty buffer from pool, got %d bits", bb.NumBits())
+	}
+
+	bb.AppendValue(0xCD, 8)
+	if data[0] != 0xAB {
+		t.Errorf("ToBytes result changed after buffer reuse: 0x%02X", data[0])
+	}
+}
+
+func TestBitBufferReservePatch(t *testing.T) {
+	// Reserve a 12-bit length field, append a payload that flushes it out
+	// of the accumulator, then patch it
+	bb := NewBitBuffer()
+	bb.AppendBit(1)
+	handle := bb.ReserveBits(12)
+	if handle != 1 {
+		t.Errorf("Expected handle 1, got %d", handle)
+	}
+	bb.AppendValue(0xABCD, 16)
+	bb.AppendBit(1)
+
+	if err := bb.PatchBits(handle, 0xF0F, 12); err != nil {
+		t.Fatalf("PatchBits failed: %v", err)
+	}
+
+	// 1 | 1111 0000 1111 | 1010 1011 1100 1101 | 1
+	expected := NewBitBuffer()
+	expected.AppendBit(1)
+	expected.AppendValue(0xF0F, 12)
+	expected.AppendValue(0xABCD, 16)
+	expected.AppendBit(1)
+	if !bytes.Equal(bb.ToBytes(), expected.ToBytes()) || bb.NumBits() != 30 {
+		t.Errorf("Expected %x, got %x", expected.ToBytes(), bb.ToBytes())
+	}
+}
+
+func TestBitBufferPatchInAccumulator(t *testing.T) {
+	// Patch bits that have not been flushed yet, and clear set bits
+	bb := NewBitBuffer()
+	bb.AppendValue(0x7, 3)
+	handle := bb.ReserveBits(4)
+	if err := bb.PatchBits(handle, 0x9, 4); err != nil {
+		t.Fatalf("PatchBits failed: %v", err)
+	}
+	if err := bb.PatchBits(0, 0x2, 3); err != nil {
+		t.Fatalf("PatchBits failed: %v", err)
+	}
+
+	// 010 1001 -> 0101 0010
+	if got := bb.ToBytes(); !bytes.Equal(got, []byte{0x52}) {
+		t.Errorf("Expected 52, got %x", got)
+	}
+}
+
+func TestBitBufferReserveLarge(t *testing.T) {
+	bb := NewBitBuffer()
+	handle := bb.ReserveBits(100)
+	if bb.NumBits() != 100 {
+		t.Errorf("Expected 100 bits, got %d", bb.NumBits())
+	}
+	if err := bb.PatchBits(handle+90, 0x3FF, 10); err != nil {
+		t.Fatalf("PatchBits failed: %v", err)
+	}
+	out := bb.ToBytes()
+	if out[11] != 0x3F || out[12] != 0xF0 {
+		t.Errorf("Unexpected tail %x", out[10:])
+	}
+}
+
+func TestBitBufferPatchErrors(t *testing.T) {
+	bb := NewBitBuffer()
+	handle := bb.ReserveBits(8)
+
+	if err := bb.PatchBits(handle, 0, 9); err == nil {
+		t.Error("Expected error for patch beyond buffer")
+	}
+	if err := bb.PatchBits(-1, 0, 1); err == nil {
+		t.Error("Expected error for negative handle")
+	}
+	if err := bb.PatchBits(handle, 0, 65); err == nil {
+		t.Error("Expected error for n > 64")
+	}
+}
+
+func TestBitBufferToBytesMidStream(t *testing.T) {
+	// ToBytes must not disturb later appends
+	bb := NewBitBuffer()
+	bb.AppendValue(0x5, 3)
+	if got := bb.ToBytes(); !bytes.Equal(got, []byte{0xA0}) {
+		t.Errorf("Expected a0, got %x", got)
+	}
+	bb.AppendValue(0x1F, 5)
+	bb.AppendBit(1)
+	if got := bb.ToBytes(); !bytes.Equal(got, []byte{0xBF, 0x80}) {
+		t.Errorf("Expected bf80, got %x", got)
+	}
+}
+
+func TestBitBufferToBytesPadded(t *testing.T) {
+	bb := NewBitBuffer()
+	bb.AppendValue(0xABC, 12) // 1010 1011 1100
+
+	if got := bb.ToBytesPadded(0); !bytes.Equal(got, []byte{0xAB, 0xC0}) {
+		t.Errorf("ToBytesPadded(0) = %x, want abc0", got)
+	}
+	if got := bb.ToBytesPadded(1); !bytes.Equal(got, []byte{0xAB, 0xCF}) {
+		t.Errorf("ToBytesPadded(1) = %x, want abcf", got)
+	}
+
+	bb.PadBit = 1
+	if got := bb.ToBytes(); !bytes.Equal(got, []byte{0xAB, 0xCF}) {
+		t.Errorf("ToBytes with PadBit=1 = %x, want abcf", got)
+	}
+
+	// Whole bytes have nothing to pad
+	bb.AppendValue(0x5, 4)
+	if got := bb.ToBytes(); !bytes.Equal(got, []byte{0xAB, 0xC5}) {
+		t.Errorf("ToBytes byte-aligned = %x, want abc5", got)
+	}
+}
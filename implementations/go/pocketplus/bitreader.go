@@ -30,11 +30,20 @@ func NewBitReader(data []byte) *BitReader {
 
 // NewBitReaderWithBits creates a new bit reader with a specific bit count.
 // This is useful when the data doesn't fill the last byte completely.
+//
+// numBits is clamped to [0, len(data)*8] rather than rejected: a larger
+// count reads only the available bytes, and a count of 0 (or less) gives a
+// reader with Remaining() == 0 on which every read returns ErrEOF, even if
+// data is non-empty. Callers that derive numBits from untrusted input and
+// need to detect a mismatch should compare it with len(data)*8 themselves.
 func NewBitReaderWithBits(data []byte, numBits int) *BitReader {
 	maxBits := len(data) * 8
 	if numBits > maxBits {
 		numBits = maxBits
 	}
+	if numBits < 0 {
+		numBits = 0
+	}
 	return &BitReader{
 		data:      data,
 		totalBits: numBits,
@@ -66,6 +75,21 @@ func (br *BitReader) PeekBit() (int, error) {
 	return int(bit), nil
 }
 
+// peekByte returns the next 8 bits MSB-first without consuming them.
+// The caller must ensure at least 8 bits remain.
+func (br *BitReader) peekByte() byte {
+	byteIndex := br.position >> 3
+	bitOffset := br.position & 7
+
+	if bitOffset == 0 {
+		return br.data[byteIndex]
+	}
+
+	// Assemble from two adjacent bytes
+	word := uint16(br.data[byteIndex])<<8 | uint16(br.data[byteIndex+1])
+	return byte(word >> (8 - bitOffset))
+}
+
 // ReadBit reads and consumes a single bit.
 func (br *BitReader) ReadBit() (int, error) {
 	bit, err := br.PeekBit()
@@ -103,13 +127,88 @@ func (br *BitReader) ReadBits(numBits int) (uint64, error) {
 	return result, nil
 }
 
+// ReadBitsSlice reads and consumes n bits, returning each as a separate
+// 0 or 1 value in reading order. Unlike ReadBits, n is not limited to 64.
+func (br *BitReader) ReadBitsSlice(n int) ([]int, error) {
+	if n < 0 || n > br.Remaining() {
+		return nil, fmt.Errorf("not enough bits: need %d, have %d", n, br.Remaining())
+	}
+
+	result := make([]int, n)
+	for i := range result {
+		byteIndex := br.position / 8
+		bitIndex := 7 - (br.position % 8)
+		result[i] = int(br.data[byteIndex]>>bitIndex) & 1
+		br.position++
+	}
+	return result, nil
+}
+
+// ReadBitsIntoVector reads bv.Length() bits MSB-first into bv, replacing
+// its contents. Bits are transferred a word at a time.
+func (br *BitReader) ReadBitsIntoVector(bv *BitVector) error {
+	if br.Remaining() < bv.length {
+		return fmt.Errorf("not enough bits: need %d, have %d", bv.length, br.Remaining())
+	}
+
+	for w := 0; w < bv.numWords; w++ {
+		n := bv.length - w*32
+		if n > 32 {
+			n = 32
+		}
+		bv.data[w] = br.readWord(n) << (32 - n)
+	}
+
+	return nil
+}
+
+// readWord consumes n (1-32) bits MSB-first and returns them right-aligned.
+// The caller must ensure n bits remain.
+func (br *BitReader) readWord(n int) uint32 {
+	byteIndex := br.position >> 3
+	bitOffset := br.position & 7
+
+	// Gather the (up to) 5 bytes spanning the requested bits
+	var window uint64
+	for i := 0; i < 5; i++ {
+		window <<= 8
+		if byteIndex+i < len(br.data) {
+			window |= uint64(br.data[byteIndex+i])
+		}
+	}
+
+	br.position += n
+	return uint32(window>>(40-bitOffset-n)) & uint32((uint64(1)<<n)-1)
+}
+
+// ReadUnary reads a unary-coded value: the number of '1' bits before the
+// next '0'. The terminating '0' is consumed.
+func (br *BitReader) ReadUnary() (int, error) {
+	n := 0
+	for {
+		bit, err := br.ReadBit()
+		if err != nil {
+			return 0, fmt.Errorf("unary decode: %w", err)
+		}
+		if bit == 0 {
+			return n, nil
+		}
+		n++
+	}
+}
+
 // AlignByte advances to the next byte boundary.
-// If already at a byte boundary, does nothing.
+// If already at a byte boundary, does nothing. The position never moves
+// past the end of the data, so Remaining() stays non-negative when the
+// reader was created with a bit count that is not a multiple of 8.
 func (br *BitReader) AlignByte() {
 	bitOffset := br.position % 8
 	if bitOffset != 0 {
 		br.position += 8 - bitOffset
 	}
+	if br.position > br.totalBits {
+		br.position = br.totalBits
+	}
 }
 
 // Skip advances the position by the given number of bits.
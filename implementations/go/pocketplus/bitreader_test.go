@@ -1,6 +1,7 @@
 package pocketplus
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -156,6 +157,19 @@ func TestBitReaderAlignByte(t *testing.T) {
 	}
 }
 
+func TestBitReaderAlignByteClamped(t *testing.T) {
+	br := NewBitReaderWithBits([]byte{0xFF, 0x00}, 11)
+
+	br.ReadBits(10)
+	br.AlignByte()
+	if br.Position() != 11 {
+		t.Errorf("Expected position clamped to 11, got %d", br.Position())
+	}
+	if br.Remaining() != 0 {
+		t.Errorf("Expected 0 remaining, got %d", br.Remaining())
+	}
+}
+
 func TestBitReaderSkip(t *testing.T) {
 	br := NewBitReader([]byte{0xFF, 0x00, 0xAA})
 
@@ -253,6 +267,36 @@ func TestNewBitReaderWithBitsExceedsData(t *testing.T) {
 	}
 }
 
+func TestNewBitReaderWithBitsClamping(t *testing.T) {
+	data := []byte{0xFF, 0xAA}
+	tests := []struct {
+		name    string
+		data    []byte
+		numBits int
+		want    int
+	}{
+		{"empty data, nonzero bits", []byte{}, 8, 0},
+		{"nil data, nonzero bits", nil, 8, 0},
+		{"data, zero bits", data, 0, 0},
+		{"data, negative bits", data, -3, 0},
+		{"data, partial bits", data, 12, 12},
+		{"data, exact bits", data, 16, 16},
+		{"data, more bits than data", data, 17, 16},
+	}
+
+	for _, tc := range tests {
+		br := NewBitReaderWithBits(tc.data, tc.numBits)
+		if br.Remaining() != tc.want {
+			t.Errorf("%s: expected %d bits, got %d", tc.name, tc.want, br.Remaining())
+		}
+		if tc.want == 0 {
+			if _, err := br.ReadBit(); !errors.Is(err, ErrEOF) {
+				t.Errorf("%s: expected ErrEOF, got %v", tc.name, err)
+			}
+		}
+	}
+}
+
 func TestBitReaderReadBitsLarge(t *testing.T) {
 	// Test reading many bits at once
 	data := []byte{0xDE, 0xAD, 0xBE, 0xEF, 0xCA, 0xFE, 0xBA, 0xBE}
@@ -285,3 +329,132 @@ func TestBitReaderPosition(t *testing.T) {
 		t.Errorf("Position after ReadBits(4) should be 5, got %d", br.Position())
 	}
 }
+
+func TestBitReaderReadUnary(t *testing.T) {
+	// 0b1110_0101 -> 3, 0, 1, then a truncated run
+	br := NewBitReader([]byte{0xE5})
+
+	for _, expected := range []int{3, 0, 1} {
+		val, err := br.ReadUnary()
+		if err != nil {
+			t.Fatalf("ReadUnary failed: %v", err)
+		}
+		if val != expected {
+			t.Errorf("Expected %d, got %d", expected, val)
+		}
+	}
+
+	// Remaining bit is a '1' with no terminator
+	if _, err := br.ReadUnary(); !errors.Is(err, ErrEOF) {
+		t.Errorf("Expected ErrEOF for unterminated run, got %v", err)
+	}
+}
+
+func TestBitReaderUnaryRoundTrip(t *testing.T) {
+	values := []int{0, 1, 2, 7, 8, 31, 32, 33, 64, 100}
+
+	bb := NewBitBuffer()
+	bb.AppendBit(1) // Misalign so runs straddle bytes
+	for _, v := range values {
+		bb.AppendUnary(v)
+	}
+
+	br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	br.Skip(1)
+	for _, expected := range values {
+		val, err := br.ReadUnary()
+		if err != nil {
+			t.Fatalf("ReadUnary failed: %v", err)
+		}
+		if val != expected {
+			t.Errorf("Round-trip: expected %d, got %d", expected, val)
+		}
+	}
+	if br.Remaining() != 0 {
+		t.Errorf("Expected all bits consumed, %d remaining", br.Remaining())
+	}
+}
+
+func TestBitReaderReadBitsIntoVector(t *testing.T) {
+	for _, length := range []int{1, 8, 31, 32, 33, 100, 720} {
+		src, _ := NewBitVector(length)
+		for i := 0; i < length; i += 3 {
+			src.SetBit(i, 1)
+		}
+		src.SetBit(length-1, 1)
+
+		// Offset by 3 bits so words straddle byte boundaries
+		bb := NewBitBuffer()
+		bb.AppendValue(0x5, 3)
+		bb.AppendBitVector(src)
+		bb.AppendBit(1)
+
+		br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+		br.Skip(3)
+
+		dst, _ := NewBitVector(length)
+		if err := br.ReadBitsIntoVector(dst); err != nil {
+			t.Fatalf("length %d: ReadBitsIntoVector failed: %v", length, err)
+		}
+		if !dst.Equals(src) {
+			t.Errorf("length %d: vector mismatch", length)
+		}
+
+		bit, _ := br.ReadBit()
+		if bit != 1 || br.Remaining() != 0 {
+			t.Errorf("length %d: reader left at wrong position", length)
+		}
+	}
+}
+
+func TestBitReaderReadBitsIntoVectorInsufficient(t *testing.T) {
+	br := NewBitReader([]byte{0xFF})
+	dst, _ := NewBitVector(9)
+
+	if err := br.ReadBitsIntoVector(dst); err == nil {
+		t.Error("Expected error when fewer bits remain than the vector length")
+	}
+	if br.Position() != 0 {
+		t.Error("Position should not advance on error")
+	}
+}
+
+func TestBitReaderReadBitsSlice(t *testing.T) {
+	// 0xA5 0x0F -> 1010 0101 0000 1111, read 70 bits across a longer buffer
+	data := []byte{0xA5, 0x0F, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00}
+	br := NewBitReader(data)
+	br.Skip(1)
+
+	bits, err := br.ReadBitsSlice(70)
+	if err != nil {
+		t.Fatalf("ReadBitsSlice failed: %v", err)
+	}
+	if len(bits) != 70 {
+		t.Fatalf("Expected 70 bits, got %d", len(bits))
+	}
+
+	check := NewBitReader(data)
+	check.Skip(1)
+	for i, bit := range bits {
+		want, _ := check.ReadBit()
+		if bit != want {
+			t.Errorf("bit %d: expected %d, got %d", i, want, bit)
+		}
+	}
+	if br.Position() != 71 {
+		t.Errorf("Expected position 71, got %d", br.Position())
+	}
+
+	if _, err := br.ReadBitsSlice(10); err == nil {
+		t.Error("Expected error when fewer bits remain than requested")
+	}
+	if br.Position() != 71 {
+		t.Error("Position should not advance on error")
+	}
+	if _, err := br.ReadBitsSlice(-1); err == nil {
+		t.Error("Expected error for negative count")
+	}
+	if bits, err := br.ReadBitsSlice(0); err != nil || len(bits) != 0 {
+		t.Errorf("Expected empty slice for n=0, got %v, %v", bits, err)
+	}
+}
@@ -2,9 +2,17 @@ package pocketplus
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"math/bits"
 )
 
+// MaxVectorBits is the maximum supported bit vector length (and therefore
+// the maximum packet length F) in bits. It bounds allocations made on
+// behalf of untrusted packet size parameters. Packets longer than MaxCount
+// bits are subject to the COUNT limits described there.
+const MaxVectorBits = 1 << 20
+
 // BitVector is a fixed-length bit vector using 32-bit word storage.
 //
 // Bit Numbering Convention (CCSDS 124.0-B-1 Section 1.6.1):
@@ -26,6 +34,9 @@ func NewBitVector(numBits int) (*BitVector, error) {
 	if numBits <= 0 {
 		return nil, errors.New("numBits must be positive")
 	}
+	if numBits > MaxVectorBits {
+		return nil, fmt.Errorf("numBits must not exceed %d", MaxVectorBits)
+	}
 
 	// Calculate number of 32-bit words needed
 	numBytes := (numBits + 7) / 8
@@ -38,6 +49,46 @@ func NewBitVector(numBits int) (*BitVector, error) {
 	}, nil
 }
 
+// ParseBitVector creates a bit vector from a string of '0' and '1'
+// characters, MSB first (bit 0 is the first character). Spaces and
+// underscores may be used as separators and are ignored.
+func ParseBitVector(s string) (*BitVector, error) {
+	numBits := 0
+	for i, c := range s {
+		switch c {
+		case '0', '1':
+			numBits++
+		case ' ', '_':
+		default:
+			return nil, fmt.Errorf("invalid character %q at offset %d", c, i)
+		}
+	}
+
+	bv, err := NewBitVector(numBits)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	for _, c := range s {
+		if c == '0' || c == '1' {
+			bv.SetBit(pos, int(c-'0'))
+			pos++
+		}
+	}
+	return bv, nil
+}
+
+// String returns the bits as a string of '0' and '1', MSB first, in the
+// format accepted by ParseBitVector.
+func (bv *BitVector) String() string {
+	buf := make([]byte, bv.length)
+	for i := range buf {
+		buf[i] = byte('0' + bv.GetBit(i))
+	}
+	return string(buf)
+}
+
 // Length returns the length of the bit vector in bits.
 func (bv *BitVector) Length() int {
 	return bv.length
@@ -50,6 +101,24 @@ func (bv *BitVector) Zero() {
 	}
 }
 
+// SetAll sets all length bits to one, leaving padding bits of the last word
+// clear, e.g. for an all-unpredictable initial mask.
+func (bv *BitVector) SetAll() {
+	for i := range bv.data {
+		bv.data[i] = ^uint32(0)
+	}
+	bv.clearPadding()
+}
+
+// Fill sets all bits to bit (0 or 1).
+func (bv *BitVector) Fill(bit int) {
+	if bit != 0 {
+		bv.SetAll()
+	} else {
+		bv.Zero()
+	}
+}
+
 // Copy creates a copy of this bit vector.
 func (bv *BitVector) Copy() *BitVector {
 	result := &BitVector{
@@ -108,6 +177,37 @@ func (bv *BitVector) SetBit(pos int, value int) {
 	}
 }
 
+// NumWords returns the number of 32-bit words holding the vector,
+// ceil(length/32).
+func (bv *BitVector) NumWords() int {
+	return bv.numWords
+}
+
+// GetWord returns word i, which holds bits 32i to 32i+31 with bit 32i as
+// the most significant bit of the word (the same MSB-first layout as the
+// packed bytes). Bits beyond the vector length read as zero, as does an
+// out-of-range index.
+func (bv *BitVector) GetWord(i int) uint32 {
+	if i < 0 || i >= bv.numWords {
+		return 0
+	}
+	return bv.data[i]
+}
+
+// SetWord sets bits 32i to 32i+31 from v, MSB first: bit 32i is v's most
+// significant bit and bit 32i+31 its least significant bit. In the last
+// word, the low bits of v beyond the vector length are discarded.
+func (bv *BitVector) SetWord(i int, v uint32) error {
+	if i < 0 || i >= bv.numWords {
+		return fmt.Errorf("word index %d out of range [0, %d)", i, bv.numWords)
+	}
+	bv.data[i] = v
+	if i == bv.numWords-1 {
+		bv.clearPadding()
+	}
+	return nil
+}
+
 // FromBytes loads the bit vector from bytes (big-endian).
 func (bv *BitVector) FromBytes(data []byte) {
 	// Zero the array first
@@ -138,9 +238,21 @@ func (bv *BitVector) FromBytes(data []byte) {
 }
 
 // ToBytes converts the bit vector to bytes (big-endian).
+//
+// Bytes are taken from each word high byte first and exactly (length+7)/8
+// bytes are returned, so a final partial word (e.g. 90 bytes = 22.5 words)
+// contributes only its leading bytes. Bits beyond length in the last byte
+// are returned as stored.
 func (bv *BitVector) ToBytes() []byte {
+	result := make([]byte, (bv.length+7)/8)
+	bv.toBytesInto(result)
+	return result
+}
+
+// toBytesInto writes the bit vector bytes (big-endian) into result,
+// which must hold at least (length+7)/8 bytes.
+func (bv *BitVector) toBytesInto(result []byte) {
 	expectedBytes := (bv.length + 7) / 8
-	result := make([]byte, expectedBytes)
 
 	byteIndex := 0
 	for wordIndex := 0; wordIndex < bv.numWords && byteIndex < expectedBytes; wordIndex++ {
@@ -152,86 +264,216 @@ func (bv *BitVector) ToBytes() []byte {
 			byteIndex++
 		}
 	}
+}
 
-	return result
+// WriteTo writes the packed bytes of the bit vector, as returned by
+// ToBytes, to w. No length prefix is written.
+func (bv *BitVector) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(bv.ToBytes())
+	return int64(n), err
 }
 
+// ReadFrom reads exactly (length+7)/8 bytes from r into the bit vector.
+//
+// The length is not part of the data, so the vector must already be
+// allocated with the expected length (e.g. known from a schema). On error
+// the vector is left unchanged.
+func (bv *BitVector) ReadFrom(r io.Reader) (int64, error) {
+	buf := make([]byte, (bv.length+7)/8)
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return int64(n), err
+	}
+	bv.FromBytes(buf)
+	return int64(n), nil
+}
+
+// Binary operations between vectors of different lengths zero-extend the
+// shorter operand. The result always has the receiver's (or, for the Into
+// variants, the destination's) length: bits of a longer operand beyond it
+// are dropped and padding bits of the last word are cleared. Use the
+// Checked variants to reject mismatched lengths instead.
+
 // XOR computes the bitwise XOR of this vector with another.
 func (bv *BitVector) XOR(other *BitVector) *BitVector {
 	result, _ := NewBitVector(bv.length)
+	result.XORInto(bv, other)
+	return result
+}
 
-	numWords := bv.numWords
-	if other.numWords < numWords {
-		numWords = other.numWords
+// XORInto computes a XOR b and stores the result in this vector.
+func (bv *BitVector) XORInto(a, b *BitVector) {
+	n := bv.commonWords(a, b)
+	for i := 0; i < n; i++ {
+		bv.data[i] = a.data[i] ^ b.data[i]
 	}
-
-	for i := 0; i < numWords; i++ {
-		result.data[i] = bv.data[i] ^ other.data[i]
+	if n < bv.numWords || a.length != b.length || a.length != bv.length {
+		for i := n; i < bv.numWords; i++ {
+			bv.data[i] = a.wordAt(i) ^ b.wordAt(i)
+		}
+		bv.clearPadding()
 	}
+}
 
+// OR computes the bitwise OR of this vector with another.
+func (bv *BitVector) OR(other *BitVector) *BitVector {
+	result, _ := NewBitVector(bv.length)
+	result.ORInto(bv, other)
 	return result
 }
 
-// XORInto computes a XOR b and stores the result in this vector.
-func (bv *BitVector) XORInto(a, b *BitVector) {
-	numWords := bv.numWords
-	if a.numWords < numWords {
-		numWords = a.numWords
+// ORInto computes a OR b and stores the result in this vector.
+func (bv *BitVector) ORInto(a, b *BitVector) {
+	n := bv.commonWords(a, b)
+	for i := 0; i < n; i++ {
+		bv.data[i] = a.data[i] | b.data[i]
 	}
-	if b.numWords < numWords {
-		numWords = b.numWords
+	if n < bv.numWords || a.length != b.length || a.length != bv.length {
+		for i := n; i < bv.numWords; i++ {
+			bv.data[i] = a.wordAt(i) | b.wordAt(i)
+		}
+		bv.clearPadding()
 	}
+}
 
-	for i := 0; i < numWords; i++ {
-		bv.data[i] = a.data[i] ^ b.data[i]
+// AND computes the bitwise AND of this vector with another.
+func (bv *BitVector) AND(other *BitVector) *BitVector {
+	result, _ := NewBitVector(bv.length)
+
+	n := result.commonWords(bv, other)
+	for i := 0; i < n; i++ {
+		result.data[i] = bv.data[i] & other.data[i]
+	}
+	// Words past n stay zero: one operand is zero-extended there
+	if bv.length != other.length {
+		result.clearPadding()
 	}
+
+	return result
 }
 
-// OR computes the bitwise OR of this vector with another.
-func (bv *BitVector) OR(other *BitVector) *BitVector {
+// Masked returns the bits of this vector where mask is 1, with every
+// other position zeroed. It is AND under a name that reads as selection:
+// the prediction It-1 AND Mt, or extracting a field from a packet.
+func (bv *BitVector) Masked(mask *BitVector) *BitVector {
 	result, _ := NewBitVector(bv.length)
+	bv.MaskedInto(result, mask)
+	return result
+}
 
-	numWords := bv.numWords
-	if other.numWords < numWords {
-		numWords = other.numWords
+// MaskedInto stores the bits of this vector where mask is 1 in dst, zeroing
+// every other position of dst.
+func (bv *BitVector) MaskedInto(dst, mask *BitVector) {
+	n := dst.commonWords(bv, mask)
+	for i := 0; i < n; i++ {
+		dst.data[i] = bv.data[i] & mask.data[i]
 	}
+	for i := n; i < dst.numWords; i++ {
+		dst.data[i] = 0
+	}
+	dst.clearPadding()
+}
 
-	for i := 0; i < numWords; i++ {
-		result.data[i] = bv.data[i] | other.data[i]
+// XORChecked is XOR that returns an error if the lengths differ.
+func (bv *BitVector) XORChecked(other *BitVector) (*BitVector, error) {
+	if bv.length != other.length {
+		return nil, errors.New("XOR: vectors must have same length")
 	}
+	return bv.XOR(other), nil
+}
 
-	return result
+// ORChecked is OR that returns an error if the lengths differ.
+func (bv *BitVector) ORChecked(other *BitVector) (*BitVector, error) {
+	if bv.length != other.length {
+		return nil, errors.New("OR: vectors must have same length")
+	}
+	return bv.OR(other), nil
 }
 
-// ORInto computes a OR b and stores the result in this vector.
-func (bv *BitVector) ORInto(a, b *BitVector) {
-	numWords := bv.numWords
-	if a.numWords < numWords {
-		numWords = a.numWords
+// ANDChecked is AND that returns an error if the lengths differ.
+func (bv *BitVector) ANDChecked(other *BitVector) (*BitVector, error) {
+	if bv.length != other.length {
+		return nil, errors.New("AND: vectors must have same length")
 	}
-	if b.numWords < numWords {
-		numWords = b.numWords
+	return bv.AND(other), nil
+}
+
+// OrReduce returns the bitwise OR of all vectors, e.g. the union of the
+// positions that changed over a sequence of deltas. The vectors must be
+// non-empty, non-nil and all of the same length.
+func OrReduce(vectors []*BitVector) (*BitVector, error) {
+	if err := checkReduce("OrReduce", vectors); err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < numWords; i++ {
-		bv.data[i] = a.data[i] | b.data[i]
+	result := vectors[0].Copy()
+	for _, v := range vectors[1:] {
+		for i := 0; i < result.numWords; i++ {
+			result.data[i] |= v.data[i]
+		}
 	}
+
+	return result, nil
 }
 
-// AND computes the bitwise AND of this vector with another.
-func (bv *BitVector) AND(other *BitVector) *BitVector {
-	result, _ := NewBitVector(bv.length)
+// AndReduce returns the bitwise AND of all vectors, i.e. the positions set
+// in every one of them. The vectors must be non-empty, non-nil and all of
+// the same length.
+func AndReduce(vectors []*BitVector) (*BitVector, error) {
+	if err := checkReduce("AndReduce", vectors); err != nil {
+		return nil, err
+	}
 
-	numWords := bv.numWords
-	if other.numWords < numWords {
-		numWords = other.numWords
+	result := vectors[0].Copy()
+	for _, v := range vectors[1:] {
+		for i := 0; i < result.numWords; i++ {
+			result.data[i] &= v.data[i]
+		}
 	}
 
-	for i := 0; i < numWords; i++ {
-		result.data[i] = bv.data[i] & other.data[i]
+	return result, nil
+}
+
+// checkReduce validates the operands of OrReduce and AndReduce.
+func checkReduce(name string, vectors []*BitVector) error {
+	if len(vectors) == 0 {
+		return fmt.Errorf("%s: no vectors", name)
 	}
+	for i, v := range vectors {
+		if v == nil {
+			return fmt.Errorf("%s: vector %d is nil", name, i)
+		}
+		if v.length != vectors[0].length {
+			return fmt.Errorf("%s: vector %d has length %d, expected %d",
+				name, i, v.length, vectors[0].length)
+		}
+	}
+	return nil
+}
 
-	return result
+// commonWords returns the number of words present in all of bv, a and b.
+func (bv *BitVector) commonWords(a, b *BitVector) int {
+	n := bv.numWords
+	if a.numWords < n {
+		n = a.numWords
+	}
+	if b.numWords < n {
+		n = b.numWords
+	}
+	return n
+}
+
+// wordAt returns word i, or 0 beyond the end of the vector (zero-extension).
+func (bv *BitVector) wordAt(i int) uint32 {
+	if i < bv.numWords {
+		return bv.data[i]
+	}
+	return 0
+}
+
+// clearPadding zeroes the bits of the last word beyond length.
+func (bv *BitVector) clearPadding() {
+	bv.data[bv.numWords-1] &= bv.lastWordMask()
 }
 
 // NOT computes the bitwise NOT (inversion) of this vector.
@@ -290,9 +532,19 @@ func (bv *BitVector) LeftShift() *BitVector {
 func (bv *BitVector) Reverse() *BitVector {
 	result, _ := NewBitVector(bv.length)
 
-	for i := 0; i < bv.length; i++ {
-		bit := bv.GetBit(i)
-		result.SetBit((bv.length-1)-i, bit)
+	// Reverse word order and the bits within each word. The padding bits of
+	// the last word end up at the front, so shift them out afterwards.
+	last := bv.numWords - 1
+	for i := 0; i <= last; i++ {
+		result.data[last-i] = bits.Reverse32(bv.data[i])
+	}
+
+	pad := uint(bv.numWords*32 - bv.length)
+	if pad > 0 {
+		for i := 0; i < last; i++ {
+			result.data[i] = result.data[i]<<pad | result.data[i+1]>>(32-pad)
+		}
+		result.data[last] <<= pad
 	}
 
 	return result
@@ -320,6 +572,80 @@ func (bv *BitVector) HammingWeight() int {
 	return count
 }
 
+// HammingDistance returns the number of positions at which this vector and
+// other differ. Both vectors must have the same length.
+func (bv *BitVector) HammingDistance(other *BitVector) (int, error) {
+	if bv.length != other.length {
+		return 0, errors.New("HammingDistance: vectors must have same length")
+	}
+
+	// Fold XOR and popcount word by word without allocating
+	count := 0
+	last := bv.numWords - 1
+	for i := 0; i < last; i++ {
+		count += bits.OnesCount32(bv.data[i] ^ other.data[i])
+	}
+	count += bits.OnesCount32((bv.data[last] ^ other.data[last]) & bv.lastWordMask())
+
+	return count, nil
+}
+
+// EqualsWhere reports whether this vector and other agree at every position
+// where mask is 1, i.e. (bv XOR other) AND mask == 0. All three vectors must
+// have the same length.
+func (bv *BitVector) EqualsWhere(other, mask *BitVector) (bool, error) {
+	if bv.length != other.length || bv.length != mask.length {
+		return false, errors.New("EqualsWhere: vectors must have same length")
+	}
+
+	last := bv.numWords - 1
+	for i := 0; i < last; i++ {
+		if (bv.data[i]^other.data[i])&mask.data[i] != 0 {
+			return false, nil
+		}
+	}
+	diff := (bv.data[last] ^ other.data[last]) & mask.data[last] & bv.lastWordMask()
+
+	return diff == 0, nil
+}
+
+// AnyBitSetInRange reports whether any bit in positions [start, end) is 1.
+// The range is clipped to the vector, so an empty or out-of-range span
+// reports false.
+func (bv *BitVector) AnyBitSetInRange(start, end int) bool {
+	if start < 0 {
+		start = 0
+	}
+	if end > bv.length {
+		end = bv.length
+	}
+	if start >= end {
+		return false
+	}
+
+	first, last := start/32, (end-1)/32
+	for w := first; w <= last; w++ {
+		word := bv.data[w]
+		if w == first {
+			word &= ^uint32(0) >> (start % 32)
+		}
+		if w == last {
+			word &= ^uint32(0) << (31 - (end-1)%32)
+		}
+		if word != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lastWordMask returns a mask of the valid (in-length) bits of the last word.
+func (bv *BitVector) lastWordMask() uint32 {
+	used := bv.length - (bv.numWords-1)*32
+	return ^uint32(0) << (32 - used)
+}
+
 // Equals checks if this bit vector equals another.
 func (bv *BitVector) Equals(other *BitVector) bool {
 	if bv.length != other.length {
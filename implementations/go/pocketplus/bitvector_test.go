@@ -33,6 +33,11 @@ func TestNewBitVector(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for NewBitVector(-1)")
 	}
+
+	_, err = NewBitVector(MaxVectorBits + 1)
+	if err == nil {
+		t.Error("Expected error for NewBitVector(MaxVectorBits+1)")
+	}
 }
 
 func TestBitVectorGetSetBit(t *testing.T) {
@@ -445,3 +450,475 @@ func TestBitVectorHammingWeightNonByteAligned(t *testing.T) {
 		t.Errorf("HammingWeight of 12 set bits should be 12, got %d", hw)
 	}
 }
+
+func TestBitVectorHammingDistance(t *testing.T) {
+	testCases := []struct {
+		length   int
+		a, b     []byte
+		expected int
+	}{
+		{8, []byte{0xFF}, []byte{0xFF}, 0},
+		{8, []byte{0xF0}, []byte{0x0F}, 8},
+		{8, []byte{0xB3}, []byte{0x4A}, 6},
+		{32, []byte{0x12, 0x34, 0x56, 0x78}, []byte{0x12, 0x34, 0x56, 0x79}, 1},
+		// Non-byte-aligned: bits beyond the length are ignored
+		{12, []byte{0xFF, 0xFF}, []byte{0x00, 0x00}, 12},
+		{33, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, []byte{0, 0, 0, 0, 0}, 33},
+		{33, []byte{0x00, 0x00, 0x00, 0x00, 0x7F}, []byte{0, 0, 0, 0, 0}, 0},
+		{720 - 3, make([]byte, 90), make([]byte, 90), 0},
+	}
+
+	for _, tc := range testCases {
+		a, _ := NewBitVector(tc.length)
+		b, _ := NewBitVector(tc.length)
+		a.FromBytes(tc.a)
+		b.FromBytes(tc.b)
+
+		dist, err := a.HammingDistance(b)
+		if err != nil {
+			t.Errorf("length %d: unexpected error: %v", tc.length, err)
+			continue
+		}
+		if dist != tc.expected {
+			t.Errorf("length %d: HammingDistance(%x, %x) = %d, expected %d",
+				tc.length, tc.a, tc.b, dist, tc.expected)
+		}
+	}
+}
+
+func TestBitVectorHammingDistanceLengthMismatch(t *testing.T) {
+	a, _ := NewBitVector(8)
+	b, _ := NewBitVector(16)
+
+	if _, err := a.HammingDistance(b); err == nil {
+		t.Error("Expected error for length mismatch")
+	}
+}
+
+func TestBitVectorEqualsWhere(t *testing.T) {
+	a, _ := NewBitVector(40)
+	b, _ := NewBitVector(40)
+	mask, _ := NewBitVector(40)
+
+	a.FromBytes([]byte{0xF0, 0x00, 0x00, 0x00, 0x81})
+	b.FromBytes([]byte{0x0F, 0x00, 0x00, 0x00, 0x80})
+
+	// Differences at positions 0-7 and 39; mask covers neither
+	mask.FromBytes([]byte{0x00, 0xFF, 0xFF, 0xFF, 0xFE})
+	equal, err := a.EqualsWhere(b, mask)
+	if err != nil {
+		t.Fatalf("EqualsWhere error: %v", err)
+	}
+	if !equal {
+		t.Error("Expected vectors to agree outside differing positions")
+	}
+
+	// Unmask position 39 in the partial last word
+	mask.SetBit(39, 1)
+	equal, _ = a.EqualsWhere(b, mask)
+	if equal {
+		t.Error("Expected difference at position 39 to be detected")
+	}
+
+	// Empty mask always agrees
+	mask.Zero()
+	equal, _ = a.EqualsWhere(b, mask)
+	if !equal {
+		t.Error("Expected empty mask to always agree")
+	}
+}
+
+func TestBitVectorEqualsWhereLengthMismatch(t *testing.T) {
+	a, _ := NewBitVector(8)
+	b, _ := NewBitVector(8)
+	mask, _ := NewBitVector(16)
+
+	if _, err := a.EqualsWhere(b, mask); err == nil {
+		t.Error("Expected error for length mismatch")
+	}
+}
+
+func TestBitVectorReverseLengths(t *testing.T) {
+	// Word-level reversal must match a per-bit reference, including
+	// lengths with a partial last word
+	for _, length := range []int{1, 7, 31, 32, 33, 64, 100, 720} {
+		bv, _ := NewBitVector(length)
+		for i := 0; i < length; i += 3 {
+			bv.SetBit(i, 1)
+		}
+		bv.SetBit(length-1, 1)
+
+		result := bv.Reverse()
+		for i := 0; i < length; i++ {
+			if result.GetBit(i) != bv.GetBit(length-1-i) {
+				t.Errorf("length %d: bit %d mismatch", length, i)
+				break
+			}
+		}
+		if !result.Reverse().Equals(bv) {
+			t.Errorf("length %d: double reverse mismatch", length)
+		}
+	}
+}
+
+func TestBitVectorToBytesRoundTripLengths(t *testing.T) {
+	for _, length := range []int{8, 24, 33, 90 * 8, 100} {
+		numBytes := (length + 7) / 8
+		data := make([]byte, numBytes)
+		for i := range data {
+			data[i] = byte(i*37 + 11)
+		}
+
+		bv, _ := NewBitVector(length)
+		bv.FromBytes(data)
+		result := bv.ToBytes()
+
+		if len(result) != numBytes {
+			t.Errorf("length %d: expected %d bytes, got %d", length, numBytes, len(result))
+		}
+		if !bytes.Equal(result, data) {
+			t.Errorf("length %d: round-trip mismatch: got %v, expected %v", length, result, data)
+		}
+	}
+}
+
+func TestBitVectorMismatchedLengthOps(t *testing.T) {
+	short, _ := NewBitVector(16)
+	short.FromBytes([]byte{0xF0, 0x0F})
+
+	long, _ := NewBitVector(48)
+	long.FromBytes([]byte{0xFF, 0x00, 0xAA, 0x55, 0xFF, 0xFF})
+
+	// Receiver length wins; the shorter operand is zero-extended
+	cases := []struct {
+		name     string
+		result   *BitVector
+		expected []byte
+	}{
+		{"long XOR short", long.XOR(short), []byte{0x0F, 0x0F, 0xAA, 0x55, 0xFF, 0xFF}},
+		{"long OR short", long.OR(short), []byte{0xFF, 0x0F, 0xAA, 0x55, 0xFF, 0xFF}},
+		{"long AND short", long.AND(short), []byte{0xF0, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"short XOR long", short.XOR(long), []byte{0x0F, 0x0F}},
+		{"short OR long", short.OR(long), []byte{0xFF, 0x0F}},
+		{"short AND long", short.AND(long), []byte{0xF0, 0x00}},
+	}
+	for _, c := range cases {
+		if !bytes.Equal(c.result.ToBytes(), c.expected) {
+			t.Errorf("%s: expected %X, got %X", c.name, c.expected, c.result.ToBytes())
+		}
+	}
+
+	// Into variants overwrite stale destination words past the operands
+	dst, _ := NewBitVector(48)
+	dst.FromBytes([]byte{0xDE, 0xAD, 0xBE, 0xEF, 0xDE, 0xAD})
+	dst.ORInto(short, short)
+	if !bytes.Equal(dst.ToBytes(), []byte{0xF0, 0x0F, 0, 0, 0, 0}) {
+		t.Errorf("ORInto left stale words: %X", dst.ToBytes())
+	}
+	dst.FromBytes([]byte{0xDE, 0xAD, 0xBE, 0xEF, 0xDE, 0xAD})
+	dst.XORInto(short, short)
+	if !bytes.Equal(dst.ToBytes(), make([]byte, 6)) {
+		t.Errorf("XORInto left stale words: %X", dst.ToBytes())
+	}
+}
+
+func TestBitVectorMismatchedLengthPadding(t *testing.T) {
+	// A 12-bit result must not pick up bits 12-15 of a 16-bit operand
+	a, _ := NewBitVector(12)
+	b, _ := NewBitVector(16)
+	b.FromBytes([]byte{0x00, 0xFF})
+
+	result := a.OR(b)
+	if !bytes.Equal(result.ToBytes(), []byte{0x00, 0xF0}) {
+		t.Errorf("Expected padding cleared, got %X", result.ToBytes())
+	}
+}
+
+func TestBitVectorCheckedOps(t *testing.T) {
+	a, _ := NewBitVector(16)
+	b, _ := NewBitVector(24)
+	a.FromBytes([]byte{0xF0, 0x0F})
+
+	if _, err := a.XORChecked(b); err == nil {
+		t.Error("XORChecked: expected error for length mismatch")
+	}
+	if _, err := a.ORChecked(b); err == nil {
+		t.Error("ORChecked: expected error for length mismatch")
+	}
+	if _, err := a.ANDChecked(b); err == nil {
+		t.Error("ANDChecked: expected error for length mismatch")
+	}
+
+	result, err := a.ORChecked(a)
+	if err != nil || !result.Equals(a) {
+		t.Errorf("ORChecked equal lengths: got %v, %v", result, err)
+	}
+}
+
+func TestBitVectorWriteToReadFrom(t *testing.T) {
+	for _, length := range []int{1, 8, 13, 32, 90} {
+		bv, _ := NewBitVector(length)
+		for i := 0; i < length; i += 3 {
+			bv.SetBit(i, 1)
+		}
+
+		var buf bytes.Buffer
+		n, err := bv.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("Length %d: WriteTo failed: %v", length, err)
+		}
+		if n != int64((length+7)/8) || buf.Len() != (length+7)/8 {
+			t.Errorf("Length %d: wrote %d bytes, expected %d", length, n, (length+7)/8)
+		}
+
+		restored, _ := NewBitVector(length)
+		n, err = restored.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("Length %d: ReadFrom failed: %v", length, err)
+		}
+		if n != int64((length+7)/8) {
+			t.Errorf("Length %d: read %d bytes, expected %d", length, n, (length+7)/8)
+		}
+		if !restored.Equals(bv) {
+			t.Errorf("Length %d: round-trip mismatch", length)
+		}
+	}
+
+	// Short input
+	bv, _ := NewBitVector(32)
+	bv.SetBit(0, 1)
+	if _, err := bv.ReadFrom(bytes.NewReader([]byte{0xFF, 0xFF})); err == nil {
+		t.Error("Expected error for short input")
+	}
+	if bv.GetBit(0) != 1 || bv.GetBit(1) != 0 {
+		t.Error("Vector modified by failed ReadFrom")
+	}
+}
+
+func TestParseBitVector(t *testing.T) {
+	bv, err := ParseBitVector("0100_1010 1")
+	if err != nil {
+		t.Fatalf("ParseBitVector failed: %v", err)
+	}
+	if bv.Length() != 9 {
+		t.Errorf("Expected length 9, got %d", bv.Length())
+	}
+	expected, _ := NewBitVector(9)
+	expected.FromBytes([]byte{0x4A, 0x80})
+	if !bv.Equals(expected) {
+		t.Errorf("Expected %s, got %s", expected, bv)
+	}
+	if bv.String() != "010010101" {
+		t.Errorf("String() = %q", bv.String())
+	}
+
+	for _, s := range []string{"", " _ ", "0102", "1x", "10\n"} {
+		if _, err := ParseBitVector(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestBitVectorStringRoundTrip(t *testing.T) {
+	for _, length := range []int{1, 8, 31, 33, 90} {
+		bv, _ := NewBitVector(length)
+		for i := 0; i < length; i += 3 {
+			bv.SetBit(i, 1)
+		}
+
+		parsed, err := ParseBitVector(bv.String())
+		if err != nil {
+			t.Fatalf("Length %d: ParseBitVector failed: %v", length, err)
+		}
+		if !parsed.Equals(bv) {
+			t.Errorf("Length %d: round-trip mismatch: %s vs %s", length, parsed, bv)
+		}
+	}
+}
+
+func TestBitVectorSetAllFill(t *testing.T) {
+	for _, length := range []int{1, 7, 12, 31, 32, 33, 90} {
+		bv, _ := NewBitVector(length)
+
+		bv.SetAll()
+		if bv.HammingWeight() != length {
+			t.Errorf("Length %d: SetAll weight %d", length, bv.HammingWeight())
+		}
+		if bv.GetBit(length-1) != 1 {
+			t.Errorf("Length %d: last bit not set", length)
+		}
+
+		// Padding stays clear, so NOT of all ones is all zeros
+		if bv.NOT().HammingWeight() != 0 {
+			t.Errorf("Length %d: NOT(SetAll) not zero", length)
+		}
+
+		bv.Fill(0)
+		if bv.HammingWeight() != 0 {
+			t.Errorf("Length %d: Fill(0) weight %d", length, bv.HammingWeight())
+		}
+		bv.Fill(1)
+		if bv.HammingWeight() != length {
+			t.Errorf("Length %d: Fill(1) weight %d", length, bv.HammingWeight())
+		}
+	}
+}
+
+func TestOrAndReduce(t *testing.T) {
+	a, _ := ParseBitVector("1100000000000000000000000000000001")
+	b, _ := ParseBitVector("0110000000000000000000000000000001")
+	c, _ := ParseBitVector("0100000000000000000000000000000011")
+
+	or, err := OrReduce([]*BitVector{a, b, c})
+	if err != nil {
+		t.Fatalf("OrReduce: %v", err)
+	}
+	if got, want := or.String(), "1110000000000000000000000000000011"; got != want {
+		t.Errorf("OrReduce = %s, want %s", got, want)
+	}
+
+	and, err := AndReduce([]*BitVector{a, b, c})
+	if err != nil {
+		t.Fatalf("AndReduce: %v", err)
+	}
+	if got, want := and.String(), "0100000000000000000000000000000001"; got != want {
+		t.Errorf("AndReduce = %s, want %s", got, want)
+	}
+
+	// The result is a new vector, not an alias of the first operand
+	or.Zero()
+	if a.HammingWeight() != 3 {
+		t.Error("OrReduce modified its first operand")
+	}
+
+	single, err := AndReduce([]*BitVector{a})
+	if err != nil || !single.Equals(a) {
+		t.Errorf("AndReduce single: got %v, %v", single, err)
+	}
+}
+
+func TestOrAndReduceInvalid(t *testing.T) {
+	a, _ := NewBitVector(16)
+	b, _ := NewBitVector(24)
+
+	cases := map[string][]*BitVector{
+		"empty":    nil,
+		"nil":      {a, nil},
+		"mismatch": {a, b},
+	}
+	for name, vectors := range cases {
+		if _, err := OrReduce(vectors); err == nil {
+			t.Errorf("OrReduce %s: expected error", name)
+		}
+		if _, err := AndReduce(vectors); err == nil {
+			t.Errorf("AndReduce %s: expected error", name)
+		}
+	}
+}
+
+func TestBitVectorAnyBitSetInRange(t *testing.T) {
+	bv, _ := NewBitVector(70)
+	bv.SetBit(33, 1)
+
+	cases := []struct {
+		start, end int
+		want       bool
+	}{
+		{0, 70, true},
+		{0, 33, false},
+		{33, 34, true},
+		{34, 70, false},
+		{32, 64, true},
+		{0, 32, false},
+		{33, 33, false},
+		{40, 20, false},
+		{-5, 100, true},
+		{70, 80, false},
+	}
+	for _, c := range cases {
+		if got := bv.AnyBitSetInRange(c.start, c.end); got != c.want {
+			t.Errorf("AnyBitSetInRange(%d, %d) = %v, want %v", c.start, c.end, got, c.want)
+		}
+	}
+
+	// Padding bits past the length are never reported
+	bv.SetAll()
+	bv.data[bv.numWords-1] = ^uint32(0)
+	if bv.AnyBitSetInRange(70, 96) {
+		t.Error("AnyBitSetInRange reported padding bits")
+	}
+}
+
+func TestBitVectorMasked(t *testing.T) {
+	for _, length := range []int{1, 8, 33, 70} {
+		bv, _ := NewBitVector(length)
+		mask, _ := NewBitVector(length)
+		for i := 0; i < length; i++ {
+			bv.SetBit(i, (i/2)%2)
+			mask.SetBit(i, (i*7/3)%2)
+		}
+
+		masked := bv.Masked(mask)
+		for i := 0; i < length; i++ {
+			want := 0
+			if mask.GetBit(i) == 1 {
+				want = bv.GetBit(i)
+			}
+			if masked.GetBit(i) != want {
+				t.Errorf("Length %d: Masked bit %d = %d, want %d", length, i, masked.GetBit(i), want)
+			}
+		}
+
+		// MaskedInto overwrites every position of a dirty destination
+		dst, _ := NewBitVector(length)
+		dst.SetAll()
+		bv.MaskedInto(dst, mask)
+		if !dst.Equals(masked) {
+			t.Errorf("Length %d: MaskedInto = %s, want %s", length, dst, masked)
+		}
+	}
+
+	// A shorter mask zero-extends, so positions past it are cleared
+	bv, _ := NewBitVector(40)
+	bv.SetAll()
+	mask, _ := NewBitVector(8)
+	mask.SetAll()
+	if got := bv.Masked(mask); got.Length() != 40 || got.HammingWeight() != 8 {
+		t.Errorf("Masked with short mask: got %s", got)
+	}
+}
+
+func TestBitVectorWords(t *testing.T) {
+	bv, _ := NewBitVector(40)
+	if bv.NumWords() != 2 {
+		t.Fatalf("NumWords = %d, expected 2", bv.NumWords())
+	}
+
+	if err := bv.SetWord(0, 0x80000001); err != nil {
+		t.Fatalf("SetWord failed: %v", err)
+	}
+	if bv.GetBit(0) != 1 || bv.GetBit(31) != 1 || bv.HammingWeight() != 2 {
+		t.Errorf("SetWord(0): wrong bit layout %s", bv)
+	}
+
+	// Only the top 8 bits of the last word are in range
+	if err := bv.SetWord(1, 0xFFFFFFFF); err != nil {
+		t.Fatalf("SetWord failed: %v", err)
+	}
+	if got := bv.GetWord(1); got != 0xFF000000 {
+		t.Errorf("GetWord(1) = %08X, expected FF000000", got)
+	}
+	if !bytes.Equal(bv.ToBytes(), []byte{0x80, 0x00, 0x00, 0x01, 0xFF}) {
+		t.Errorf("ToBytes = %X", bv.ToBytes())
+	}
+
+	if err := bv.SetWord(2, 1); err == nil {
+		t.Error("SetWord: expected error for index past the last word")
+	}
+	if err := bv.SetWord(-1, 1); err == nil {
+		t.Error("SetWord: expected error for negative index")
+	}
+	if bv.GetWord(2) != 0 || bv.GetWord(-1) != 0 {
+		t.Error("GetWord: expected 0 for out-of-range index")
+	}
+}
@@ -0,0 +1,150 @@
+package pocketplus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CompressColumns compresses a table of telemetry channels column by column.
+//
+// matrix holds rows x cols elements of elemBits bits each, bit-packed in
+// row-major order (row = sample time, column = channel) and padded with zeros
+// to a whole byte. Every column becomes its own POCKET+ stream with one
+// elemBits-bit packet per row, using the same parameters as Compress. The
+// packets of a column are packed back to back without byte alignment, and
+// the output is the column streams in order, each padded to a whole byte
+// and prefixed with its length in bytes as a 32-bit big-endian integer.
+//
+// Column-wise compression keeps channels independent: each has its own
+// mask and robustness window, a single channel can be decoded on its own,
+// and corruption in one column does not affect the others. The cost is a
+// packet header of at least 7 bits per element rather than per row, so the
+// output is usually larger than row-wise Compress. It comes closest for
+// wide elements that change rarely and at unrelated times; compare both on
+// representative data before choosing. elemBits must be between 1 and
+// 65535.
+func CompressColumns(matrix []byte, rows, cols, elemBits, robustness, ptLimit, ftLimit, rtLimit int) ([]byte, error) {
+	totalBits, err := matrixBits(rows, cols, elemBits)
+	if err != nil {
+		return nil, err
+	}
+	if len(matrix) != (totalBits+7)/8 {
+		return nil, fmt.Errorf("matrix length %d does not match %d bytes for %dx%d elements of %d bits",
+			len(matrix), (totalBits+7)/8, rows, cols, elemBits)
+	}
+	if robustness < 1 || robustness > 7 {
+		return nil, errors.New("robustness must be between 1 and 7")
+	}
+
+	comp, err := NewCompressor(elemBits, nil, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return nil, err
+	}
+	input, _ := NewBitVector(elemBits)
+
+	var output []byte
+	column := NewBitBuffer()
+	for c := 0; c < cols; c++ {
+		comp.Reset()
+		column.Clear()
+
+		for r := 0; r < rows; r++ {
+			offset := (r*cols + c) * elemBits
+			for i := 0; i < elemBits; i++ {
+				input.SetBit(i, matrixBit(matrix, offset+i))
+			}
+
+			compressed, err := comp.CompressPacket(input, comp.scheduleParams(r))
+			if err != nil {
+				return nil, fmt.Errorf("column %d row %d: %w", c, r, err)
+			}
+			column.AppendBits(compressed, comp.workOutput.NumBits())
+		}
+
+		columnBytes := column.ToBytes()
+		output = binary.BigEndian.AppendUint32(output, uint32(len(columnBytes)))
+		output = append(output, columnBytes...)
+	}
+
+	return output, nil
+}
+
+// DecompressColumns reverses CompressColumns and returns the row-major
+// matrix of rows x cols elements of elemBits bits.
+func DecompressColumns(data []byte, rows, cols, elemBits, robustness int) ([]byte, error) {
+	totalBits, err := matrixBits(rows, cols, elemBits)
+	if err != nil {
+		return nil, err
+	}
+	// Every compressed packet takes at least 7 bits
+	if rows*cols > len(data)*8/7 {
+		return nil, fmt.Errorf("%d bytes cannot hold %d compressed elements", len(data), rows*cols)
+	}
+	if robustness < 1 || robustness > 7 {
+		return nil, errors.New("robustness must be between 1 and 7")
+	}
+
+	decomp, err := NewDecompressor(elemBits, nil, robustness)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := make([]byte, (totalBits+7)/8)
+	output, _ := NewBitVector(elemBits)
+	for c := 0; c < cols; c++ {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("column %d: missing length prefix", c)
+		}
+		columnLen := int(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if columnLen > len(data) {
+			return nil, fmt.Errorf("column %d: length %d exceeds remaining %d bytes", c, columnLen, len(data))
+		}
+
+		decomp.Reset()
+		reader := NewBitReader(data[:columnLen])
+		for r := 0; r < rows; r++ {
+			if err := decomp.decompressPacketInto(reader, output, nil); err != nil {
+				return nil, fmt.Errorf("column %d row %d: %w", c, r, err)
+			}
+
+			offset := (r*cols + c) * elemBits
+			for i := 0; i < elemBits; i++ {
+				if output.GetBit(i) != 0 {
+					matrix[(offset+i)/8] |= 0x80 >> ((offset + i) % 8)
+				}
+			}
+		}
+		if reader.Remaining() >= 8 {
+			return nil, fmt.Errorf("column %d: %d unused bits", c, reader.Remaining())
+		}
+
+		data = data[columnLen:]
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after last column", len(data))
+	}
+
+	return matrix, nil
+}
+
+// matrixBits validates matrix dimensions and returns the total bit count.
+func matrixBits(rows, cols, elemBits int) (int, error) {
+	if rows <= 0 || cols <= 0 {
+		return 0, errors.New("rows and cols must be positive")
+	}
+	if elemBits < 1 || elemBits > MaxCount {
+		return 0, fmt.Errorf("elemBits must be between 1 and %d", MaxCount)
+	}
+	if cols > math.MaxInt/elemBits || rows > math.MaxInt/(cols*elemBits) {
+		return 0, errors.New("matrix too large")
+	}
+	return rows * cols * elemBits, nil
+}
+
+// matrixBit returns bit pos of data, MSB first.
+func matrixBit(data []byte, pos int) int {
+	return int(data[pos/8]>>(7-pos%8)) & 1
+}
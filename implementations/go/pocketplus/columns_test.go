@@ -0,0 +1,98 @@
+package pocketplus
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// generateChannelMatrix builds a rows x cols matrix of 16-bit channels:
+// mostly constant channels plus one counter and one noisy channel.
+func generateChannelMatrix(rows, cols int, seed int64) []byte {
+	rng := rand.New(rand.NewSource(seed))
+	matrix := make([]byte, rows*cols*2)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			var v uint16
+			switch {
+			case c == 0:
+				v = uint16(r)
+			case c == cols-1:
+				v = uint16(rng.Intn(1 << 16))
+			default:
+				v = uint16(c*1000 + (r/50)%2)
+			}
+			matrix[(r*cols+c)*2] = byte(v >> 8)
+			matrix[(r*cols+c)*2+1] = byte(v)
+		}
+	}
+	return matrix
+}
+
+func TestCompressColumnsRoundTrip(t *testing.T) {
+	matrix := generateChannelMatrix(200, 12, 1)
+
+	compressed, err := CompressColumns(matrix, 200, 12, 16, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressColumns failed: %v", err)
+	}
+	decompressed, err := DecompressColumns(compressed, 200, 12, 16, 1)
+	if err != nil {
+		t.Fatalf("DecompressColumns failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, matrix) {
+		t.Error("Column round-trip mismatch")
+	}
+}
+
+func TestCompressColumnsOddElementWidth(t *testing.T) {
+	// 5 rows x 3 cols x 5 bits = 75 bits, padded to 10 bytes
+	matrix := []byte{0xA5, 0x3C, 0xFF, 0x00, 0x12, 0x34, 0x56, 0x78, 0x9A, 0xE0}
+
+	compressed, err := CompressColumns(matrix, 5, 3, 5, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressColumns failed: %v", err)
+	}
+	decompressed, err := DecompressColumns(compressed, 5, 3, 5, 2)
+	if err != nil {
+		t.Fatalf("DecompressColumns failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, matrix) {
+		t.Errorf("Expected %x, got %x", matrix, decompressed)
+	}
+}
+
+func TestCompressColumnsErrors(t *testing.T) {
+	matrix := make([]byte, 8)
+
+	if _, err := CompressColumns(matrix, 2, 2, 16, 1, 10, 20, 50); err != nil {
+		t.Errorf("Valid matrix rejected: %v", err)
+	}
+	if _, err := CompressColumns(matrix, 3, 2, 16, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for matrix length mismatch")
+	}
+	if _, err := CompressColumns(matrix, 0, 2, 16, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for zero rows")
+	}
+	if _, err := CompressColumns(matrix, 2, 2, 0, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for zero elemBits")
+	}
+	if _, err := CompressColumns(matrix, math.MaxInt/16, 2, 16, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for overflowing dimensions")
+	}
+	if _, err := CompressColumns(matrix, 2, 2, 16, 0, 10, 20, 50); err == nil {
+		t.Error("Expected error for robustness 0")
+	}
+
+	compressed, _ := CompressColumns(matrix, 2, 2, 16, 1, 10, 20, 50)
+	if _, err := DecompressColumns(compressed[:len(compressed)-1], 2, 2, 16, 1); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+	if _, err := DecompressColumns(append(compressed, 0), 2, 2, 16, 1); err == nil {
+		t.Error("Expected error for trailing data")
+	}
+	if _, err := DecompressColumns(compressed, 1<<30, 2, 16, 1); err == nil {
+		t.Error("Expected error for more rows than data can hold")
+	}
+}
@@ -0,0 +1,81 @@
+package pocketplus
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// CompareCompressed decodes two compressed streams side by side and
+// reports the first packet whose encoded bits differ, for regression tests
+// of encoder changes against golden files.
+//
+// Packets are compared over their parsed length only, so differences in
+// byte-alignment padding are ignored. firstDiffBit is the offset of the
+// first differing bit from the start of packet firstDiffPacket;
+// DecompressPacketTraced on that packet maps the offset to a component.
+// When one stream has more packets than the other, the first extra packet
+// is reported with firstDiffBit 0. For equal streams both indices are -1.
+//
+// An error is returned if either stream fails to decode before a
+// difference is found.
+func CompareCompressed(a, b []byte, packetSize, robustness int) (firstDiffPacket, firstDiffBit int, equal bool, err error) {
+	decompA, err := newStreamDecompressor(packetSize, robustness)
+	if err != nil {
+		return -1, -1, false, err
+	}
+	decompB, _ := newStreamDecompressor(packetSize, robustness)
+
+	readerA := NewBitReader(a)
+	readerB := NewBitReader(b)
+
+	for packet := 0; ; packet++ {
+		doneA, doneB := readerA.Remaining() == 0, readerB.Remaining() == 0
+		if doneA && doneB {
+			return -1, -1, true, nil
+		}
+		if doneA || doneB {
+			return packet, 0, false, nil
+		}
+
+		startA, startB := readerA.Position(), readerB.Position()
+		_, traceA, err := decompA.DecompressPacketTraced(readerA)
+		if err != nil {
+			return -1, -1, false, fmt.Errorf("first stream: packet %d: %w", packet, err)
+		}
+		_, traceB, err := decompB.DecompressPacketTraced(readerB)
+		if err != nil {
+			return -1, -1, false, fmt.Errorf("second stream: packet %d: %w", packet, err)
+		}
+
+		if bit := firstBitDiff(a, startA, traceA.Length, b, startB, traceB.Length); bit >= 0 {
+			return packet, bit, false, nil
+		}
+
+		readerA.AlignByte()
+		readerB.AlignByte()
+	}
+}
+
+// firstBitDiff returns the offset of the first differing bit between the
+// aLen bits of a starting at bit aStart and the bLen bits of b starting at
+// bStart, or -1 if they are identical. If one run is a prefix of the other,
+// the offset is the shorter length.
+func firstBitDiff(a []byte, aStart, aLen int, b []byte, bStart, bLen int) int {
+	readerA := NewBitReaderWithBits(a, aStart+aLen)
+	readerB := NewBitReaderWithBits(b, bStart+bLen)
+	readerA.position = aStart
+	readerB.position = bStart
+
+	n := min(aLen, bLen)
+	for off := 0; off < n; off += 32 {
+		k := min(32, n-off)
+		if diff := readerA.readWord(k) ^ readerB.readWord(k); diff != 0 {
+			return off + bits.LeadingZeros32(diff) - (32 - k)
+		}
+	}
+
+	if aLen != bLen {
+		return n
+	}
+	return -1
+}
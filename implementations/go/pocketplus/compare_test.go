@@ -0,0 +1,112 @@
+package pocketplus
+
+import "testing"
+
+func TestCompareCompressed(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(40, packetSize)
+	golden, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	packet, bit, equal, err := CompareCompressed(golden, append([]byte{}, golden...), packetSize, 1)
+	if err != nil || !equal || packet != -1 || bit != -1 {
+		t.Errorf("Identical streams: got (%d, %d, %v, %v)", packet, bit, equal, err)
+	}
+
+	// Locate every packet so bits can be flipped at known offsets
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	reader := NewBitReader(golden)
+	var starts, lengths []int
+	for reader.Remaining() > 0 {
+		starts = append(starts, reader.Position())
+		_, trace, err := decomp.DecompressPacketTraced(reader)
+		if err != nil {
+			t.Fatalf("DecompressPacketTraced failed: %v", err)
+		}
+		lengths = append(lengths, trace.Length)
+		reader.AlignByte()
+	}
+
+	// A flipped bit inside packet 25 is localized to that packet and offset;
+	// its last bit is an extracted input bit, so the packet still parses
+	target := 25
+	offset := lengths[target] - 1
+	changed := append([]byte{}, golden...)
+	pos := starts[target] + offset
+	changed[pos/8] ^= 1 << (7 - pos%8)
+
+	packet, bit, equal, err = CompareCompressed(golden, changed, packetSize, 1)
+	if err != nil || equal || packet != target || bit != offset {
+		t.Errorf("Flipped bit: got (%d, %d, %v, %v), want (%d, %d, false, nil)",
+			packet, bit, equal, err, target, offset)
+	}
+
+	// Padding bits are not part of the comparison
+	for i := range starts {
+		end := starts[i] + lengths[i]
+		if end%8 == 0 {
+			continue
+		}
+		padded := append([]byte{}, golden...)
+		padded[end/8] |= 1 << (7 - end%8)
+		packet, bit, equal, err = CompareCompressed(golden, padded, packetSize, 1)
+		if err != nil || !equal {
+			t.Errorf("Padding differs in packet %d: got (%d, %d, %v, %v)", i, packet, bit, equal, err)
+		}
+		break
+	}
+
+	// A longer stream differs at its first extra packet
+	shorter, _ := Compress(data[:30*packetSize], packetSize, 1, 10, 20, 50)
+	packet, bit, equal, err = CompareCompressed(shorter, golden, packetSize, 1)
+	if err != nil || equal || packet != 30 || bit != 0 {
+		t.Errorf("Extra packets: got (%d, %d, %v, %v), want (30, 0, false, nil)", packet, bit, equal, err)
+	}
+}
+
+func TestCompareCompressedScheduleChange(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(40, packetSize)
+	a, _ := Compress(data, packetSize, 1, 10, 20, 50)
+	b, _ := Compress(data, packetSize, 1, 10, 15, 50)
+
+	// The two schedules agree until ft fires at packet 15 in b
+	packet, _, equal, err := CompareCompressed(a, b, packetSize, 1)
+	if err != nil || equal || packet != 15 {
+		t.Errorf("Got packet %d, equal %v, err %v; want packet 15", packet, equal, err)
+	}
+}
+
+func TestCompareCompressedErrors(t *testing.T) {
+	if _, _, _, err := CompareCompressed(nil, nil, 0, 1); err == nil {
+		t.Error("Expected error for invalid packet size")
+	}
+
+	data := generateTestPackets(5, 8)
+	good, _ := Compress(data, 8, 1, 10, 20, 50)
+	if _, _, _, err := CompareCompressed(good, []byte{0xFF, 0xFF}, 8, 1); err == nil {
+		t.Error("Expected error for undecodable second stream")
+	}
+}
+
+func TestFirstBitDiff(t *testing.T) {
+	a := []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC}
+	b := []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBD}
+
+	if got := firstBitDiff(a, 0, 48, b, 0, 48); got != 47 {
+		t.Errorf("Last bit differs: got %d, want 47", got)
+	}
+	if got := firstBitDiff(a, 0, 40, b, 0, 40); got != -1 {
+		t.Errorf("Equal prefix: got %d, want -1", got)
+	}
+	if got := firstBitDiff(a, 0, 40, b, 0, 44); got != 40 {
+		t.Errorf("Prefix of longer run: got %d, want 40", got)
+	}
+	// 0x34 0x56 starting at bit 8 of a equals the same bytes at bit 0 of c
+	c := []byte{0x34, 0x56}
+	if got := firstBitDiff(a, 8, 16, c, 0, 16); got != -1 {
+		t.Errorf("Shifted start: got %d, want -1", got)
+	}
+	if got := firstBitDiff(a, 9, 15, c, 1, 15); got != -1 {
+		t.Errorf("Unaligned start: got %d, want -1", got)
+	}
+}
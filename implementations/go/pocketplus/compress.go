@@ -2,7 +2,10 @@ package pocketplus
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"io"
 )
 
 // Version is the library version.
@@ -11,39 +14,92 @@ const Version = "1.0.0"
 // ErrNotImplemented is returned when a function is not yet implemented.
 var ErrNotImplemented = errors.New("not implemented")
 
+// MaxPacketSize is the largest packet size in bytes accepted by the
+// stream-level functions. Their schedule sends the first packet
+// uncompressed, and COUNT(F) limits F to MaxCount bits. Larger packets
+// need a Compressor and Decompressor with SetExtendedCount.
+const MaxPacketSize = MaxCount / 8
+
 // Compress compresses the input data using POCKET+ algorithm.
 //
 // Parameters:
 //   - data: Input bytes to compress (must be multiple of packetSize)
-//   - packetSize: Size of each packet in bytes
+//   - packetSize: Size of each packet in bytes (at most MaxPacketSize)
 //   - robustness: Robustness parameter R (1-7)
 //   - ptLimit: Period limit for new_mask_flag (pt)
 //   - ftLimit: Period limit for send_mask_flag (ft)
 //   - rtLimit: Period limit for uncompressed_flag (rt)
 //
-// Returns compressed data or an error.
+// Returns compressed data or an error. Incompressible input expands; see
+// MaxCompressedSize for the worst case.
 func Compress(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int) ([]byte, error) {
+	return compress(data, packetSize, robustness, ptLimit, ftLimit, rtLimit, nil, nil)
+}
+
+// MaxCompressedPacketBits returns an upper bound on the length in bits of
+// one compressed packet of F bits, before byte alignment.
+//
+// POCKET+ has no fixed per-packet overhead: the ht header (RLE(Xt) and kt)
+// and a mask resend qt grow with the number of mask changes and are sent
+// even in uncompressed packets, so falling back to rt=1 cannot cap the
+// size. A COUNT code costs at most 4 bits per vector position (COUNT(2) is
+// 8 bits), which gives, per component:
+//
+//	RLE(Xt) <= 4F+2, BIT4(Vt) = 4, et+ct+dt <= 3, kt <= F
+//	qt <= 1 + 4F+2
+//	ut <= 1 + COUNT(F) + F
+//
+// for a total of 10F + 13 + len(COUNT(F)) bits. Real streams stay well
+// below this: high-entropy input peaks at roughly 5.5F bits in the second
+// packet, while the mask fills up, and then settles at F plus a few bits
+// per packet.
+func MaxCompressedPacketBits(F int) int {
+	return 10*F + 13 + CountEncodedBits(F)
+}
+
+// MaxCompressedSize returns an upper bound on the length of Compress output
+// for numPackets packets of packetSize bytes, e.g. for sizing buffers.
+func MaxCompressedSize(packetSize, numPackets int) int {
+	return numPackets * ((MaxCompressedPacketBits(packetSize*8) + 7) / 8)
+}
+
+// CompressReport lists the packets of a stream that were sent in one of the
+// expensive forms, for downlink accounting and rt/ft tuning.
+type CompressReport struct {
+	Uncompressed []int // Indices of packets sent uncompressed (rt=1)
+	MaskResends  []int // Indices of packets carrying the full mask (ft=1)
+
+	Stats StreamStats // Totals over the whole stream
+}
+
+// CompressWithReport compresses data like Compress and also reports which
+// packet indices were emitted uncompressed or with a mask resend, along
+// with the compressor's StreamStats for the whole stream.
+func CompressWithReport(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int) ([]byte, *CompressReport, error) {
+	report := &CompressReport{}
+	compressed, err := compress(data, packetSize, robustness, ptLimit, ftLimit, rtLimit, report, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return compressed, report, nil
+}
+
+// compress implements Compress, recording per-packet flag decisions in
+// report and reporting progress to onPacket if they are non-nil.
+func compress(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int, report *CompressReport,
+	onPacket func(index, total, outBytesSoFar int) bool) ([]byte, error) {
 	if len(data) == 0 {
 		return []byte{}, nil
 	}
-	if packetSize <= 0 {
-		return nil, errors.New("packet size must be positive")
-	}
-	if len(data)%packetSize != 0 {
-		return nil, errors.New("data length must be multiple of packet size")
-	}
-	if robustness < 1 || robustness > 7 {
-		return nil, errors.New("robustness must be between 1 and 7")
-	}
-
-	// Convert packet size from bytes to bits
-	F := packetSize * 8
 
 	// Create compressor
-	comp, err := NewCompressor(F, nil, robustness, ptLimit, ftLimit, rtLimit)
+	comp, err := newStreamCompressor(packetSize, robustness, ptLimit, ftLimit, rtLimit)
 	if err != nil {
 		return nil, err
 	}
+	if len(data)%packetSize != 0 {
+		return nil, errors.New("data length must be multiple of packet size")
+	}
 
 	// Output buffer
 	var output bytes.Buffer
@@ -57,58 +113,20 @@ func Compress(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int
 		packetData := data[i*packetSize : (i+1)*packetSize]
 
 		// Create bit vector from packet
-		input, err := NewBitVector(F)
+		input, err := NewBitVector(comp.F)
 		if err != nil {
 			return nil, err
 		}
 		input.FromBytes(packetData)
 
 		// Determine compression parameters (matching C implementation)
-		params := &CompressParams{
-			MinRobustness: robustness,
-		}
-
-		if i == 0 {
-			// First packet: fixed init values, counters not checked
-			params.SendMaskFlag = true
-			params.UncompressedFlag = true
-			params.NewMaskFlag = false
-		} else {
-			// Packets 1+: check and update countdown counters
-
-			// ft counter
-			if comp.ftCounter == 1 {
-				params.SendMaskFlag = true
-				comp.ftCounter = ftLimit
-			} else {
-				comp.ftCounter--
-				params.SendMaskFlag = false
+		params := comp.scheduleParams(i)
+		if report != nil {
+			if params.UncompressedFlag {
+				report.Uncompressed = append(report.Uncompressed, i)
 			}
-
-			// pt counter
-			if comp.ptCounter == 1 {
-				params.NewMaskFlag = true
-				comp.ptCounter = ptLimit
-			} else {
-				comp.ptCounter--
-				params.NewMaskFlag = false
-			}
-
-			// rt counter
-			if comp.rtCounter == 1 {
-				params.UncompressedFlag = true
-				comp.rtCounter = rtLimit
-			} else {
-				comp.rtCounter--
-				params.UncompressedFlag = false
-			}
-
-			// Override for remaining init packets: CCSDS requires first Rt+1 packets
-			// to have ft=1, rt=1, pt=0. In 0-indexed: if (i <= Rt)
-			if i <= robustness {
-				params.SendMaskFlag = true
-				params.UncompressedFlag = true
-				params.NewMaskFlag = false
+			if params.SendMaskFlag {
+				report.MaskResends = append(report.MaskResends, i)
 			}
 		}
 
@@ -120,7 +138,181 @@ func Compress(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int
 
 		// Append to output
 		output.Write(compressed)
+
+		if onPacket != nil && !onPacket(i, numPackets, output.Len()) {
+			break
+		}
+	}
+
+	if report != nil {
+		report.Stats = comp.Stats()
 	}
 
 	return output.Bytes(), nil
 }
+
+// CompressWithProgress compresses data like Compress, calling onPacket
+// after each packet with its index, the total number of packets and the
+// output size so far in bytes.
+//
+// If onPacket returns false, compression stops and the packets compressed
+// so far are returned without error. They form a valid stream of index+1
+// packets.
+func CompressWithProgress(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int,
+	onPacket func(index, total, outBytesSoFar int) bool) ([]byte, error) {
+	return compress(data, packetSize, robustness, ptLimit, ftLimit, rtLimit, nil, onPacket)
+}
+
+// CompressAudited compresses data like Compress, then decompresses the
+// result and checks that it reproduces data exactly. It returns the
+// compressed bytes and the SHA-256 of the recovered input, which can be
+// logged as proof that this compression was lossless. A round-trip
+// mismatch is an error.
+//
+// This costs a full decompression on top of compression.
+func CompressAudited(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int) (compressed []byte, roundtripHash [32]byte, err error) {
+	compressed, err = Compress(data, packetSize, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return nil, roundtripHash, err
+	}
+
+	recovered, err := Decompress(compressed, packetSize, robustness)
+	if err != nil {
+		return nil, roundtripHash, fmt.Errorf("round-trip decompression failed: %w", err)
+	}
+	if !bytes.Equal(recovered, data) {
+		return nil, roundtripHash, errors.New("round-trip mismatch: decompressed data differs from input")
+	}
+
+	return compressed, sha256.Sum256(recovered), nil
+}
+
+// CompressFrom compresses fixed-size packets read from r, writing each
+// compressed packet to w as soon as it is produced.
+//
+// Short reads from r are accumulated until a full packet is available, so
+// memory use is bounded by a single packet regardless of input size. The
+// output is identical to Compress over the same data. If r ends in the
+// middle of a packet an error is returned; packets completed before that
+// point have already been written to w.
+//
+// Returns the number of compressed bytes written to w.
+func CompressFrom(r io.Reader, w io.Writer, packetSize, robustness, ptLimit, ftLimit, rtLimit int) (int64, error) {
+	comp, err := newStreamCompressor(packetSize, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	packetData := make([]byte, packetSize)
+	input, _ := NewBitVector(comp.F)
+	var written int64
+
+	for i := 0; ; i++ {
+		// Accumulate a full packet (io.ReadFull retries short reads)
+		_, err := io.ReadFull(r, packetData)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			return written, fmt.Errorf("input ended mid-packet at packet %d", i)
+		}
+		if err != nil {
+			return written, err
+		}
+
+		input.FromBytes(packetData)
+		compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+		if err != nil {
+			return written, err
+		}
+
+		n, err := w.Write(compressed)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// newStreamCompressor validates top-level stream parameters and creates a
+// compressor for them.
+func newStreamCompressor(packetSize, robustness, ptLimit, ftLimit, rtLimit int) (*Compressor, error) {
+	if err := validatePacketSize(packetSize); err != nil {
+		return nil, err
+	}
+	if robustness < 1 || robustness > 7 {
+		return nil, errors.New("robustness must be between 1 and 7")
+	}
+
+	// Convert packet size from bytes to bits
+	return NewCompressor(packetSize*8, nil, robustness, ptLimit, ftLimit, rtLimit)
+}
+
+// validatePacketSize checks a packet size in bytes against the range
+// supported by standard streams (1 to MaxPacketSize).
+func validatePacketSize(packetSize int) error {
+	if packetSize <= 0 {
+		return errors.New("packet size must be positive")
+	}
+	if packetSize > MaxPacketSize {
+		return fmt.Errorf("packet size must not exceed %d bytes", MaxPacketSize)
+	}
+	return nil
+}
+
+// scheduleParams returns the compression parameters for the packet at
+// index i of a stream, advancing the pt/ft/rt countdown counters.
+func (comp *Compressor) scheduleParams(i int) *CompressParams {
+	params := &CompressParams{
+		MinRobustness: comp.robustness,
+	}
+
+	if i == 0 {
+		// First packet: fixed init values, counters not checked
+		params.SendMaskFlag = true
+		params.UncompressedFlag = true
+		params.NewMaskFlag = false
+		return params
+	}
+
+	// Packets 1+: check and update countdown counters
+
+	// ft counter
+	if comp.ftCounter == 1 {
+		params.SendMaskFlag = true
+		comp.ftCounter = comp.ftLimit
+	} else {
+		comp.ftCounter--
+		params.SendMaskFlag = false
+	}
+
+	// pt counter
+	if comp.ptCounter == 1 {
+		params.NewMaskFlag = true
+		comp.ptCounter = comp.ptLimit
+	} else {
+		comp.ptCounter--
+		params.NewMaskFlag = false
+	}
+
+	// rt counter
+	if comp.rtCounter == 1 {
+		params.UncompressedFlag = true
+		comp.rtCounter = comp.rtLimit
+	} else {
+		comp.rtCounter--
+		params.UncompressedFlag = false
+	}
+
+	// Override for remaining init packets: CCSDS requires first Rt+1 packets
+	// to have ft=1, rt=1, pt=0. In 0-indexed: if (i <= Rt)
+	if i <= comp.robustness {
+		params.SendMaskFlag = true
+		params.UncompressedFlag = true
+		params.NewMaskFlag = false
+	}
+
+	return params
+}
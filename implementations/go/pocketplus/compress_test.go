@@ -2,6 +2,13 @@ package pocketplus
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -199,6 +206,55 @@ func TestCompressorWithInitialMask(t *testing.T) {
 	}
 }
 
+func TestCompressorResetWith(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	data := generateTestPackets(30, packetSize)
+
+	initialMask, _ := NewBitVector(F)
+	initialMask.FromBytes([]byte{0x00, 0xFF, 0x00, 0x0F, 0x00, 0x00, 0xF0, 0x01})
+
+	compressAll := func(comp *Compressor) []byte {
+		input, _ := NewBitVector(F)
+		var out []byte
+		for i := 0; i < 30; i++ {
+			input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+			compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+			if err != nil {
+				t.Fatalf("CompressPacket failed: %v", err)
+			}
+			out = append(out, compressed...)
+		}
+		return out
+	}
+
+	fresh, _ := NewCompressor(F, initialMask, 2, 10, 20, 50)
+	expected := compressAll(fresh)
+
+	// Reuse a compressor that already ran a stream with another mask
+	reused, _ := NewCompressor(F, nil, 2, 10, 20, 50)
+	compressAll(reused)
+	if err := reused.ResetWith(initialMask); err != nil {
+		t.Fatalf("ResetWith failed: %v", err)
+	}
+
+	if !bytes.Equal(compressAll(reused), expected) {
+		t.Error("Output after ResetWith differs from a fresh compressor")
+	}
+}
+
+func TestCompressorResetWithInvalid(t *testing.T) {
+	comp, _ := NewCompressor(64, nil, 1, 10, 20, 50)
+	short, _ := NewBitVector(32)
+
+	if err := comp.ResetWith(short); err == nil {
+		t.Error("Expected error for mask length mismatch")
+	}
+	if err := comp.ResetWith(nil); err == nil {
+		t.Error("Expected error for nil mask")
+	}
+}
+
 func TestDecompressorWithInitialMask(t *testing.T) {
 	initialMask, _ := NewBitVector(64)
 	initialMask.FromBytes([]byte{0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00})
@@ -418,6 +474,28 @@ func TestCompressDecompressRoundTrip(t *testing.T) {
 	}
 }
 
+func TestCompressAudited(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(40, packetSize)
+
+	compressed, hash, err := CompressAudited(data, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressAudited failed: %v", err)
+	}
+
+	expected, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	if !bytes.Equal(compressed, expected) {
+		t.Error("CompressAudited output differs from Compress")
+	}
+	if hash != sha256.Sum256(data) {
+		t.Error("Round-trip hash does not match the input's SHA-256")
+	}
+
+	if _, _, err := CompressAudited(data[:packetSize+1], packetSize, 2, 10, 20, 50); err == nil {
+		t.Error("Expected error for data that is not a multiple of the packet size")
+	}
+}
+
 func TestPacketIterator(t *testing.T) {
 	// Compress multiple packets
 	data := make([]byte, 24) // 3 packets of 8 bytes
@@ -465,6 +543,69 @@ func TestPacketIteratorEmpty(t *testing.T) {
 	}
 }
 
+func TestPacketIteratorSubBytePackets(t *testing.T) {
+	// Packets shorter than a byte (and one just past it) decode to a
+	// single padded byte or two; every compressed packet is byte-aligned
+	for _, F := range []int{1, 7, 9} {
+		comp, _ := NewCompressor(F, nil, 1, 3, 5, 7)
+		input, _ := NewBitVector(F)
+
+		var compressed []byte
+		var expected [][]byte
+		for i := 0; i < 40; i++ {
+			for b := 0; b < F; b++ {
+				// Low bits toggle often, high bits rarely
+				input.SetBit(b, (i>>(F-1-b))&1)
+			}
+			out, err := comp.CompressPacket(input, comp.scheduleParams(i))
+			if err != nil {
+				t.Fatalf("F=%d packet %d: CompressPacket failed: %v", F, i, err)
+			}
+			compressed = append(compressed, out...)
+			expected = append(expected, input.ToBytes())
+		}
+
+		decomp, _ := NewDecompressor(F, nil, 1)
+		iter := decomp.NewPacketIterator(compressed, len(compressed)*8)
+		count := 0
+		for packet := iter.Next(); packet != nil; packet = iter.Next() {
+			if count < len(expected) && !bytes.Equal(packet, expected[count]) {
+				t.Errorf("F=%d iterator packet %d: got %x, want %x", F, count, packet, expected[count])
+			}
+			count++
+		}
+		if err := iter.Err(); err != nil {
+			t.Errorf("F=%d iterator error: %v", F, err)
+		}
+		if count != len(expected) {
+			t.Errorf("F=%d iterator: got %d packets, want %d", F, count, len(expected))
+		}
+
+		next := decomp.Packets(compressed, len(compressed)*8)
+		count = 0
+		for packet, ok := next(); ok; packet, ok = next() {
+			if count < len(expected) && !bytes.Equal(packet, expected[count]) {
+				t.Errorf("F=%d Packets packet %d: got %x, want %x", F, count, packet, expected[count])
+			}
+			count++
+		}
+		if count != len(expected) {
+			t.Errorf("F=%d Packets: got %d packets, want %d", F, count, len(expected))
+		}
+
+		count = 0
+		for packet := range decomp.StreamPackets(compressed, len(compressed)*8) {
+			if count < len(expected) && !bytes.Equal(packet, expected[count]) {
+				t.Errorf("F=%d StreamPackets packet %d: got %x, want %x", F, count, packet, expected[count])
+			}
+			count++
+		}
+		if count != len(expected) {
+			t.Errorf("F=%d StreamPackets: got %d packets, want %d", F, count, len(expected))
+		}
+	}
+}
+
 func TestStreamPackets(t *testing.T) {
 	// Compress multiple packets
 	data := make([]byte, 16) // 2 packets of 8 bytes
@@ -494,6 +635,58 @@ func TestStreamPackets(t *testing.T) {
 	}
 }
 
+func TestPackets(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(30, packetSize)
+	compressed, _ := Compress(data, packetSize, 2, 10, 20, 50)
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 2)
+	for pass := 0; pass < 2; pass++ {
+		// Calling Packets again restarts from the beginning
+		next := decomp.Packets(compressed, len(compressed)*8)
+
+		var first []byte
+		count := 0
+		for packet, ok := next(); ok; packet, ok = next() {
+			if !bytes.Equal(packet, data[count*packetSize:(count+1)*packetSize]) {
+				t.Errorf("Pass %d packet %d: mismatch", pass, count)
+			}
+			if first == nil {
+				first = packet
+			} else if &first[0] != &packet[0] {
+				t.Error("Expected the output buffer to be reused")
+			}
+			count++
+		}
+		if count != 30 {
+			t.Errorf("Pass %d: expected 30 packets, got %d", pass, count)
+		}
+		if _, ok := next(); ok {
+			t.Error("Exhausted iterator should keep returning false")
+		}
+	}
+}
+
+func TestPacketsStopsOnError(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(5, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	// Declare more bits than the packets use, padded with '1' bits that
+	// do not parse as a packet
+	padded := append(append([]byte{}, compressed...), 0xFF, 0xFF)
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	next := decomp.Packets(padded, len(padded)*8)
+
+	count := 0
+	for _, ok := next(); ok; _, ok = next() {
+		count++
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 packets before the error, got %d", count)
+	}
+}
+
 func TestDecompressStreamError(t *testing.T) {
 	decomp, _ := NewDecompressor(64, nil, 1)
 
@@ -504,6 +697,382 @@ func TestDecompressStreamError(t *testing.T) {
 	}
 }
 
+func TestDecompressStreamShortFinalPacket(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(5, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+
+	// Drop the final byte, or declare one bit less than the data holds;
+	// either way the last packet is short and must fail
+	for _, tc := range []struct {
+		data    []byte
+		numBits int
+	}{
+		{compressed[:len(compressed)-1], (len(compressed) - 1) * 8},
+		{compressed, decompressedBitsUsed(t, compressed, packetSize) - 1},
+	} {
+		packets, err := decomp.DecompressStream(tc.data, tc.numBits)
+		if err == nil {
+			t.Errorf("numBits %d: expected error for short final packet", tc.numBits)
+		}
+		if len(packets) != 4 {
+			t.Errorf("numBits %d: expected 4 packets before the error, got %d", tc.numBits, len(packets))
+		}
+		for i, packet := range packets {
+			if !bytes.Equal(packet, data[i*packetSize:(i+1)*packetSize]) {
+				t.Errorf("numBits %d: packet %d mismatch", tc.numBits, i)
+			}
+		}
+	}
+}
+
+// decompressedBitsUsed returns the bit position just past the last packet
+// of a compressed stream, before its final byte alignment.
+func decompressedBitsUsed(t *testing.T, compressed []byte, packetSize int) int {
+	t.Helper()
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	reader := NewBitReader(compressed)
+	end := 0
+	for reader.Remaining() > 0 {
+		if _, err := decomp.DecompressPacket(reader); err != nil {
+			t.Fatalf("DecompressPacket failed: %v", err)
+		}
+		end = reader.Position()
+		reader.AlignByte()
+	}
+	return end
+}
+
+func TestDecompressStreamN(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(15, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	// Trailing padding would be parsed as extra packets by DecompressStream
+	padded := append(append([]byte{}, compressed...), make([]byte, 16)...)
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	packets, err := decomp.DecompressStreamN(padded, len(padded)*8, 15)
+	if err != nil {
+		t.Fatalf("DecompressStreamN failed: %v", err)
+	}
+	if len(packets) != 15 {
+		t.Fatalf("Expected 15 packets, got %d", len(packets))
+	}
+	if !bytes.Equal(bytes.Join(packets, nil), data) {
+		t.Error("DecompressStreamN round-trip mismatch")
+	}
+}
+
+func TestDecompressStreamNShortData(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(5, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	packets, err := decomp.DecompressStreamN(compressed, len(compressed)*8, 6)
+	if err == nil {
+		t.Error("Expected error when data runs out early")
+	}
+	if len(packets) != 5 {
+		t.Errorf("Expected 5 packets decoded before the error, got %d", len(packets))
+	}
+}
+
+func TestCompressorPadBit(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	data := generateTestPackets(30, packetSize)
+
+	compressAll := func(padBit int) []byte {
+		comp, _ := NewCompressor(F, nil, 1, 10, 20, 50)
+		comp.SetPadBit(padBit)
+		input, _ := NewBitVector(F)
+		var out []byte
+		for i := 0; i < 30; i++ {
+			input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+			compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+			if err != nil {
+				t.Fatalf("CompressPacket failed: %v", err)
+			}
+			out = append(out, compressed...)
+		}
+		return out
+	}
+
+	zeros := compressAll(0)
+	ones := compressAll(1)
+	if bytes.Equal(zeros, ones) {
+		t.Fatal("Expected some packet to need padding")
+	}
+	if len(zeros) != len(ones) {
+		t.Fatalf("Padding changed the length: %d vs %d bytes", len(zeros), len(ones))
+	}
+
+	// Decoding is unaffected by the pad pattern
+	for _, compressed := range [][]byte{zeros, ones} {
+		decompressed, err := Decompress(compressed, packetSize, 1)
+		if err != nil {
+			t.Fatalf("Decompress failed: %v", err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Error("Round-trip mismatch")
+		}
+	}
+
+	// Strict trailing mode insists on zero padding
+	decomp, _ := NewDecompressor(F, nil, 1)
+	decomp.SetStrictTrailing(true)
+	if _, err := decomp.DecompressStream(ones, len(ones)*8); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("Strict decode of one-padded stream: got %v, want ErrTrailingData", err)
+	}
+}
+
+func TestDecompressCheckExhausted(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(15, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+	numBits := len(compressed) * 8
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	if _, err := decomp.DecompressStream(compressed, numBits); err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if decomp.BitsConsumed() != numBits {
+		t.Errorf("BitsConsumed = %d after DecompressStream, expected %d", decomp.BitsConsumed(), numBits)
+	}
+
+	// Every stream decoder counts the padding it skips
+	it := decomp.NewPacketIterator(compressed, numBits)
+	for it.Next() != nil {
+	}
+	if decomp.BitsConsumed() != numBits {
+		t.Errorf("BitsConsumed = %d after PacketIterator, expected %d", decomp.BitsConsumed(), numBits)
+	}
+	next := decomp.Packets(compressed, numBits)
+	for _, ok := next(); ok; _, ok = next() {
+	}
+	if decomp.BitsConsumed() != numBits {
+		t.Errorf("BitsConsumed = %d after Packets, expected %d", decomp.BitsConsumed(), numBits)
+	}
+	for range decomp.StreamPackets(compressed, numBits) {
+	}
+	if decomp.BitsConsumed() != numBits {
+		t.Errorf("BitsConsumed = %d after StreamPackets, expected %d", decomp.BitsConsumed(), numBits)
+	}
+
+	// A single packet counts its own bits, without alignment
+	decomp.Reset()
+	_, trace, _ := decomp.DecompressPacketTraced(NewBitReader(compressed))
+	if decomp.BitsConsumed() != trace.Length {
+		t.Errorf("BitsConsumed = %d after one packet, expected %d", decomp.BitsConsumed(), trace.Length)
+	}
+
+	// Stopping short is only an error with the check enabled
+	if _, err := decomp.DecompressStreamN(compressed, numBits, 10); err != nil {
+		t.Errorf("DecompressStreamN lenient: %v", err)
+	}
+	decomp.SetCheckExhausted(true)
+	if _, err := decomp.DecompressStreamN(compressed, numBits, 15); err != nil {
+		t.Errorf("DecompressStreamN with all packets: %v", err)
+	}
+	if decomp.BitsConsumed() != numBits {
+		t.Errorf("BitsConsumed = %d after DecompressStreamN, expected %d", decomp.BitsConsumed(), numBits)
+	}
+	packets, err := decomp.DecompressStreamN(compressed, numBits, 10)
+	if !errors.Is(err, ErrParameterMismatch) {
+		t.Errorf("DecompressStreamN short: got %v, want ErrParameterMismatch", err)
+	}
+	if len(packets) != 10 {
+		t.Errorf("Expected 10 packets before the error, got %d", len(packets))
+	}
+
+	// Bits short of a byte are padding and allowed
+	padded := append(append([]byte{}, compressed...), 0x5A)
+	if _, err := decomp.DecompressStreamN(padded, numBits+7, 15); err != nil {
+		t.Errorf("DecompressStreamN with 7 extra bits: %v", err)
+	}
+	if _, err := decomp.DecompressStreamN(padded, numBits+8, 15); !errors.Is(err, ErrParameterMismatch) {
+		t.Errorf("DecompressStreamN with a trailing byte: got %v, want ErrParameterMismatch", err)
+	}
+}
+
+func TestDecompressStrictTrailing(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(15, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	decomp.SetStrictTrailing(true)
+
+	if _, err := decomp.DecompressStream(compressed, len(compressed)*8); err != nil {
+		t.Errorf("DecompressStream strict on clean data: %v", err)
+	}
+	if _, err := decomp.DecompressStreamN(compressed, len(compressed)*8, 15); err != nil {
+		t.Errorf("DecompressStreamN strict on clean data: %v", err)
+	}
+
+	// Data after the expected packets is rejected only in strict mode
+	padded := append(append([]byte{}, compressed...), 0x00, 0x5A)
+	packets, err := decomp.DecompressStreamN(padded, len(padded)*8, 15)
+	if !errors.Is(err, ErrTrailingData) {
+		t.Errorf("DecompressStreamN strict with trailing data: got %v, want ErrTrailingData", err)
+	}
+	if len(packets) != 15 {
+		t.Errorf("Expected 15 packets before the error, got %d", len(packets))
+	}
+
+	// Find a packet that does not end on a byte boundary and set one of
+	// its padding bits
+	decomp.Reset()
+	reader := NewBitReader(compressed)
+	var paddingBit, index int
+	for index = 0; reader.Remaining() > 0; index++ {
+		start := reader.Position()
+		_, trace, err := decomp.DecompressPacketTraced(reader)
+		if err != nil {
+			t.Fatalf("DecompressPacketTraced failed: %v", err)
+		}
+		if end := start + trace.Length; end%8 != 0 {
+			paddingBit = end
+			break
+		}
+		reader.AlignByte()
+	}
+	if reader.Remaining() == 0 {
+		t.Fatal("No packet with alignment padding found")
+	}
+	corrupt := append([]byte{}, compressed...)
+	corrupt[paddingBit/8] |= 1 << (7 - paddingBit%8)
+
+	packets, err = decomp.DecompressStream(corrupt, len(corrupt)*8)
+	if !errors.Is(err, ErrTrailingData) {
+		t.Errorf("DecompressStream strict with non-zero padding: got %v, want ErrTrailingData", err)
+	}
+	if len(packets) != index+1 {
+		t.Errorf("Expected %d packets before the error, got %d", index+1, len(packets))
+	}
+
+	// The iterators stop after the same packet; only NewPacketIterator
+	// can report why
+	it := decomp.NewPacketIterator(corrupt, len(corrupt)*8)
+	n := 0
+	for it.Next() != nil {
+		n++
+	}
+	if !errors.Is(it.Err(), ErrTrailingData) || n != index+1 {
+		t.Errorf("PacketIterator strict: %d packets, err %v; want %d, ErrTrailingData", n, it.Err(), index+1)
+	}
+	next := decomp.Packets(corrupt, len(corrupt)*8)
+	n = 0
+	for _, ok := next(); ok; _, ok = next() {
+		n++
+	}
+	if n != index+1 {
+		t.Errorf("Packets strict: %d packets, want %d", n, index+1)
+	}
+	n = 0
+	for range decomp.StreamPackets(corrupt, len(corrupt)*8) {
+		n++
+	}
+	if n != index+1 {
+		t.Errorf("StreamPackets strict: %d packets, want %d", n, index+1)
+	}
+
+	// The lenient default ignores both
+	decomp.SetStrictTrailing(false)
+	if _, err := decomp.DecompressStream(corrupt, len(corrupt)*8); err != nil {
+		t.Errorf("DecompressStream lenient with non-zero padding: %v", err)
+	}
+	if _, err := decomp.DecompressStreamN(padded, len(padded)*8, 15); err != nil {
+		t.Errorf("DecompressStreamN lenient with trailing data: %v", err)
+	}
+}
+
+func TestDecompressPacketInto(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	data := generateTestPackets(40, packetSize)
+	compressed, _ := Compress(data, packetSize, 2, 3, 5, 20)
+
+	decomp, _ := NewDecompressor(F, nil, 2)
+	output, _ := NewBitVector(F)
+	reader := NewBitReader(compressed)
+	var decoded []byte
+	for reader.Remaining() > 0 {
+		if err := decomp.DecompressPacketInto(reader, output); err != nil {
+			t.Fatalf("DecompressPacketInto failed: %v", err)
+		}
+		decoded = append(decoded, output.ToBytes()...)
+		reader.AlignByte()
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Error("DecompressPacketInto round-trip mismatch")
+	}
+
+	// Decoding the whole stream into one vector allocates nothing
+	allocs := testing.AllocsPerRun(5, func() {
+		decomp.Reset()
+		reader.position = 0
+		for reader.Remaining() > 0 {
+			if err := decomp.DecompressPacketInto(reader, output); err != nil {
+				t.Fatalf("DecompressPacketInto failed: %v", err)
+			}
+			reader.AlignByte()
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations per stream, got %v", allocs)
+	}
+
+	short, _ := NewBitVector(F - 1)
+	if err := decomp.DecompressPacketInto(NewBitReader(compressed), short); err == nil {
+		t.Error("Expected error for output of wrong length")
+	}
+	if err := decomp.DecompressPacketInto(NewBitReader(compressed), nil); err == nil {
+		t.Error("Expected error for nil output")
+	}
+	if err := decomp.DecompressPacketInto(nil, output); err == nil {
+		t.Error("Expected error for nil reader")
+	}
+}
+
+func TestStreamingDecodeAllocs(t *testing.T) {
+	packetSize := 90
+	numPackets := 200
+	data := generateTelemetry(numPackets, packetSize, 0.001, 1)
+	compressed, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	numBits := len(compressed) * 8
+	decomp, _ := NewDecompressor(packetSize*8, nil, 2)
+
+	// The channel and iterator allocate one slice per packet, the pull
+	// closure only its setup; none may allocate inside the decoder
+	perStream := map[string]func(){
+		"channel": func() {
+			for range decomp.StreamPackets(compressed, numBits) {
+			}
+		},
+		"iterator": func() {
+			it := decomp.NewPacketIterator(compressed, numBits)
+			for it.Next() != nil {
+			}
+		},
+		"pull": func() {
+			next := decomp.Packets(compressed, numBits)
+			for _, ok := next(); ok; _, ok = next() {
+			}
+		},
+	}
+	limits := map[string]float64{"channel": float64(numPackets) + 10, "iterator": float64(numPackets) + 10, "pull": 10}
+
+	for name, decode := range perStream {
+		if allocs := testing.AllocsPerRun(3, decode); allocs > limits[name] {
+			t.Errorf("%s: %v allocations for %d packets, expected at most %v", name, allocs, numPackets, limits[name])
+		}
+	}
+}
+
 func TestDecompressPacketNilReader(t *testing.T) {
 	decomp, _ := NewDecompressor(64, nil, 1)
 
@@ -521,3 +1090,1098 @@ func TestCompressPacketNilInput(t *testing.T) {
 		t.Error("Expected error for nil input")
 	}
 }
+
+func TestCompressPacketSizeTooLarge(t *testing.T) {
+	// An absurd packet size must be rejected before anything is allocated
+	data := make([]byte, 16)
+	hugeSizes := []int{MaxVectorBits/8 + 1, math.MaxInt32, int(^uint(0) >> 1)}
+
+	for _, size := range hugeSizes {
+		if _, err := Compress(data, size, 1, 10, 20, 50); err == nil {
+			t.Errorf("Expected error for packet size %d", size)
+		}
+		if _, err := Decompress(data, size, 1); err == nil {
+			t.Errorf("Expected decompress error for packet size %d", size)
+		}
+	}
+
+	if _, err := NewCompressor(MaxVectorBits+1, nil, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for F > MaxVectorBits")
+	}
+	if _, err := NewDecompressor(MaxVectorBits+1, nil, 1); err == nil {
+		t.Error("Expected error for F > MaxVectorBits")
+	}
+}
+
+// generateTestPackets builds a slowly varying packet stream: a static
+// payload with a counter in the first two bytes and a flag bit that
+// toggles every five packets.
+func generateTestPackets(numPackets, packetSize int) []byte {
+	data := make([]byte, numPackets*packetSize)
+	for i := 0; i < numPackets; i++ {
+		packet := data[i*packetSize : (i+1)*packetSize]
+		for j := range packet {
+			packet[j] = byte(j * 7)
+		}
+		packet[0] = byte(i >> 8)
+		packet[1] = byte(i)
+		if (i/5)%2 == 1 {
+			packet[packetSize-1] ^= 0x10
+		}
+	}
+	return data
+}
+
+func TestRequestMaskResend(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	numPackets := 50
+	resendAt := 7
+	data := generateTestPackets(numPackets, packetSize)
+
+	// maskPackets compresses the data and returns the indices of packets
+	// that carry the mask
+	maskPackets := func(request bool) []int {
+		comp, _ := NewCompressor(F, nil, 1, 10, 20, 50)
+		decomp, _ := NewDecompressor(F, nil, 1)
+		input, _ := NewBitVector(F)
+
+		var indices []int
+		for i := 0; i < numPackets; i++ {
+			input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+			params := comp.scheduleParams(i)
+			if request && i == resendAt {
+				comp.RequestMaskResend()
+				// A dry run does not consume the request
+				if _, err := comp.SizeOfNext(input, params); err != nil {
+					t.Fatalf("SizeOfNext failed: %v", err)
+				}
+			}
+			compressed, err := comp.CompressPacket(input, params)
+			if err != nil {
+				t.Fatalf("Packet %d: %v", i, err)
+			}
+
+			output, trace, err := decomp.DecompressPacketTraced(NewBitReader(compressed))
+			if err != nil {
+				t.Fatalf("Packet %d: decode failed: %v", i, err)
+			}
+			if !bytes.Equal(output.ToBytes(), data[i*packetSize:(i+1)*packetSize]) {
+				t.Errorf("Packet %d: round-trip mismatch", i)
+			}
+			for _, c := range trace.Components {
+				if c.Name == "RLE(mask)" {
+					indices = append(indices, i)
+				}
+			}
+		}
+		return indices
+	}
+
+	baseline := maskPackets(false)
+	requested := maskPackets(true)
+
+	// Same schedule as the baseline plus the requested packet
+	expected := append([]int{}, baseline...)
+	expected = append(expected, resendAt)
+	slices.Sort(expected)
+	if slices.Contains(baseline, resendAt) || slices.Contains(baseline, resendAt+1) {
+		t.Fatalf("Test setup: baseline %v already sends the mask near packet %d", baseline, resendAt)
+	}
+	if !slices.Equal(requested, expected) {
+		t.Errorf("Mask packets %v, expected %v", requested, expected)
+	}
+}
+
+func TestNoBufferReuseIdentical(t *testing.T) {
+	packetSize := 90
+	for _, density := range []float64{0.001, 0.01, 0.2} {
+		data := generateTelemetry(300, packetSize, density, 7)
+
+		var outputs [2][]byte
+		for mode, noReuse := range []bool{false, true} {
+			comp, _ := newStreamCompressor(packetSize, 2, 10, 20, 50)
+			comp.SetNoBufferReuse(noReuse)
+			input, _ := NewBitVector(comp.F)
+
+			for i := 0; i*packetSize < len(data); i++ {
+				input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+				compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+				if err != nil {
+					t.Fatalf("Density %g packet %d: %v", density, i, err)
+				}
+				outputs[mode] = append(outputs[mode], compressed...)
+			}
+		}
+
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Errorf("Density %g: output differs without buffer reuse", density)
+		}
+		reference, _ := Compress(data, packetSize, 2, 10, 20, 50)
+		if !bytes.Equal(outputs[1], reference) {
+			t.Errorf("Density %g: output differs from Compress", density)
+		}
+	}
+}
+
+func TestEmitSync(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	robustness := 2
+	numPackets := 30
+	syncAt := 12
+	data := generateTestPackets(numPackets, packetSize)
+
+	comp, _ := NewCompressor(F, nil, robustness, 10, 20, 50)
+
+	var afterSync []byte
+	for i := 0; i < numPackets; i++ {
+		input, _ := NewBitVector(F)
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		var compressed []byte
+		var err error
+		switch {
+		case i <= robustness:
+			compressed, err = comp.CompressPacket(input, &CompressParams{
+				SendMaskFlag: true, UncompressedFlag: true,
+			})
+		case i == syncAt:
+			compressed, err = comp.EmitSync(input)
+		default:
+			compressed, err = comp.CompressPacket(input, nil)
+		}
+		if err != nil {
+			t.Fatalf("Packet %d: compression failed: %v", i, err)
+		}
+		if i >= syncAt {
+			afterSync = append(afterSync, compressed...)
+		}
+	}
+
+	// A fresh decompressor must be able to start at the sync packet
+	decomp, _ := NewDecompressor(F, nil, robustness)
+	packets, err := decomp.DecompressStream(afterSync, len(afterSync)*8)
+	if err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if len(packets) != numPackets-syncAt {
+		t.Fatalf("Expected %d packets, got %d", numPackets-syncAt, len(packets))
+	}
+	for i, packet := range packets {
+		expected := data[(syncAt+i)*packetSize : (syncAt+i+1)*packetSize]
+		if !bytes.Equal(packet, expected) {
+			t.Errorf("Packet %d: expected %v, got %v", syncAt+i, expected, packet)
+		}
+	}
+}
+
+func TestChangeRobustness(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	numPackets := 60
+	changeAt := 25
+	data := generateTestPackets(numPackets, packetSize)
+
+	for _, tc := range []struct{ from, to int }{{1, 4}, {6, 1}, {2, 7}, {3, 0}} {
+		comp, _ := NewCompressor(F, nil, tc.from, 10, 20, 50)
+		input, _ := NewBitVector(F)
+
+		var stream, afterChange []byte
+		for i := 0; i < numPackets; i++ {
+			input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+			var compressed []byte
+			var err error
+			if i == changeAt {
+				compressed, err = comp.ChangeRobustness(tc.to, input)
+			} else {
+				compressed, err = comp.CompressPacket(input, comp.scheduleParams(i))
+			}
+			if err != nil {
+				t.Fatalf("R %d->%d packet %d: %v", tc.from, tc.to, i, err)
+			}
+			stream = append(stream, compressed...)
+			if i >= changeAt {
+				afterChange = append(afterChange, compressed...)
+			}
+		}
+
+		// The decompressor follows the change in-band
+		decompressed, err := Decompress(stream, packetSize, max(tc.from, 1))
+		if err != nil {
+			t.Fatalf("R %d->%d: Decompress failed: %v", tc.from, tc.to, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Errorf("R %d->%d: round-trip mismatch", tc.from, tc.to)
+		}
+
+		// The change packet is a sync point
+		decomp, _ := NewDecompressor(F, nil, tc.to)
+		packets, err := decomp.DecompressStream(afterChange, len(afterChange)*8)
+		if err != nil {
+			t.Fatalf("R %d->%d: decoding from the change failed: %v", tc.from, tc.to, err)
+		}
+		if !bytes.Equal(bytes.Join(packets, nil), data[changeAt*packetSize:]) {
+			t.Errorf("R %d->%d: mismatch decoding from the change", tc.from, tc.to)
+		}
+	}
+}
+
+func TestChangeRobustnessInvalid(t *testing.T) {
+	comp, _ := NewCompressor(64, nil, 2, 10, 20, 50)
+	input, _ := NewBitVector(64)
+	short, _ := NewBitVector(32)
+
+	if _, err := comp.ChangeRobustness(8, input); err == nil {
+		t.Error("Expected error for robustness 8")
+	}
+	if _, err := comp.ChangeRobustness(3, short); err == nil {
+		t.Error("Expected error for wrong input length")
+	}
+	if comp.robustness != 2 || len(comp.changeHistory) != 3 || comp.t != 0 {
+		t.Error("Compressor should be unchanged after an error")
+	}
+}
+
+func TestDecompressTo(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(40, packetSize)
+
+	compressed, err := Compress(data, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	var output bytes.Buffer
+	n, err := DecompressTo(&output, compressed, packetSize, 2)
+	if err != nil {
+		t.Fatalf("DecompressTo failed: %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("Expected %d bytes written, got %d", len(data), n)
+	}
+	if !bytes.Equal(output.Bytes(), data) {
+		t.Error("DecompressTo output does not match original data")
+	}
+}
+
+func TestDecompressToEmpty(t *testing.T) {
+	var output bytes.Buffer
+	n, err := DecompressTo(&output, []byte{}, 8, 1)
+	if err != nil || n != 0 {
+		t.Errorf("Expected (0, nil) for empty input, got (%d, %v)", n, err)
+	}
+}
+
+// oneByteReader returns at most one byte per Read to exercise short reads.
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestCompressFrom(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(50, packetSize)
+
+	expected, err := Compress(data, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	var output bytes.Buffer
+	n, err := CompressFrom(&oneByteReader{data: data}, &output, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressFrom failed: %v", err)
+	}
+	if n != int64(len(expected)) {
+		t.Errorf("Expected %d bytes written, got %d", len(expected), n)
+	}
+	if !bytes.Equal(output.Bytes(), expected) {
+		t.Error("CompressFrom output differs from Compress")
+	}
+}
+
+func TestCompressFromPartialPacket(t *testing.T) {
+	data := generateTestPackets(3, 8)
+	data = data[:len(data)-3] // Truncate mid-packet
+
+	var output bytes.Buffer
+	_, err := CompressFrom(bytes.NewReader(data), &output, 8, 1, 10, 20, 50)
+	if err == nil {
+		t.Error("Expected error for stream ending mid-packet")
+	}
+}
+
+func TestCompressFromEmpty(t *testing.T) {
+	var output bytes.Buffer
+	n, err := CompressFrom(bytes.NewReader(nil), &output, 8, 1, 10, 20, 50)
+	if err != nil || n != 0 {
+		t.Errorf("Expected (0, nil) for empty input, got (%d, %v)", n, err)
+	}
+}
+
+func TestMaskSaturatedRandomData(t *testing.T) {
+	// Random data saturates the mask; from then on every packet is
+	// essentially passthrough plus a small header
+	const maxOverheadBytes = 6
+	rng := rand.New(rand.NewSource(1))
+
+	for _, packetSize := range []int{1, 8, 90, 256} {
+		for r := 1; r <= MaxRobustness; r++ {
+			numPackets := 500
+			data := make([]byte, packetSize*numPackets)
+			rng.Read(data)
+
+			F := packetSize * 8
+			comp, _ := NewCompressor(F, nil, r, 10, 20, 50)
+			input, _ := NewBitVector(F)
+
+			saturatedRun := 0
+			saturatedPackets := 0
+			for i := 0; i < numPackets; i++ {
+				input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+				out, err := comp.CompressPacket(input, comp.scheduleParams(i))
+				if err != nil {
+					t.Fatalf("CompressPacket failed: %v", err)
+				}
+
+				if !comp.MaskSaturated() {
+					saturatedRun = 0
+					continue
+				}
+				saturatedRun++
+				saturatedPackets++
+
+				// Once the robustness window no longer holds pre-saturation changes
+				if saturatedRun > r+1 && len(out) > packetSize+maxOverheadBytes {
+					t.Errorf("size=%d R=%d packet %d: %d bytes for %d-byte input",
+						packetSize, r, i, len(out), packetSize)
+				}
+			}
+
+			if saturatedPackets < numPackets/2 {
+				t.Errorf("size=%d R=%d: mask saturated for only %d of %d packets",
+					packetSize, r, saturatedPackets, numPackets)
+			}
+		}
+	}
+}
+
+func TestAllowRawMask(t *testing.T) {
+	packetSize := 16
+	F := packetSize * 8
+	numPackets := 12
+
+	// Alternating 0x00/0xAA packets drive the mask to 1010..., whose
+	// horizontal XOR is all ones and whose RLE exceeds F bits
+	data := make([]byte, numPackets*packetSize)
+	for i := packetSize; i < len(data); i += 2 * packetSize {
+		for j := 0; j < packetSize; j++ {
+			data[i+j] = 0xAA
+		}
+	}
+
+	compressStream := func(allowRaw bool) []byte {
+		comp, err := NewCompressor(F, nil, 1, 10, 20, 50)
+		if err != nil {
+			t.Fatalf("NewCompressor failed: %v", err)
+		}
+		input, _ := NewBitVector(F)
+		var out []byte
+		for i := 0; i < numPackets; i++ {
+			input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+			params := comp.scheduleParams(i)
+			params.SendMaskFlag = params.SendMaskFlag || i >= 4
+			params.AllowRawMask = allowRaw
+			compressed, err := comp.CompressPacket(input, params)
+			if err != nil {
+				t.Fatalf("CompressPacket failed: %v", err)
+			}
+			out = append(out, compressed...)
+		}
+		return out
+	}
+
+	standard := compressStream(false)
+	extended := compressStream(true)
+	if len(extended) >= len(standard) {
+		t.Errorf("Expected raw mask to shrink output: %d >= %d bytes", len(extended), len(standard))
+	}
+
+	decomp, err := NewDecompressor(F, nil, 1)
+	if err != nil {
+		t.Fatalf("NewDecompressor failed: %v", err)
+	}
+	decomp.SetAllowRawMask(true)
+
+	decompressed, err := decomp.DecompressStream(extended, len(extended)*8)
+	if err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if !bytes.Equal(bytes.Join(decompressed, nil), data) {
+		t.Error("Raw mask round-trip mismatch")
+	}
+}
+
+func TestAllowRawMaskKeepsShortRLE(t *testing.T) {
+	// A sparse mask keeps RLE, so the extension costs exactly one flag bit
+	// per mask resend and still round-trips
+	packetSize := 8
+	data := generateTestPackets(30, packetSize)
+	F := packetSize * 8
+
+	comp, _ := NewCompressor(F, nil, 2, 10, 20, 50)
+	input, _ := NewBitVector(F)
+	var out []byte
+	for i := 0; i < 30; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		params := comp.scheduleParams(i)
+		params.AllowRawMask = true
+		compressed, err := comp.CompressPacket(input, params)
+		if err != nil {
+			t.Fatalf("CompressPacket failed: %v", err)
+		}
+		out = append(out, compressed...)
+	}
+
+	decomp, _ := NewDecompressor(F, nil, 2)
+	decomp.SetAllowRawMask(true)
+	decompressed, err := decomp.DecompressStream(out, len(out)*8)
+	if err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if !bytes.Equal(bytes.Join(decompressed, nil), data) {
+		t.Error("Round-trip mismatch with AllowRawMask")
+	}
+}
+
+func TestForcedChanges(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	numPackets := 40
+	data := generateTestPackets(numPackets, packetSize)
+
+	// Force the last byte unpredictable from packet 10 on, as if a mode
+	// command made it volatile
+	forced, _ := NewBitVector(F)
+	for i := F - 8; i < F; i++ {
+		forced.SetBit(i, 1)
+	}
+
+	comp, _ := NewCompressor(F, nil, 2, 10, 20, 50)
+	input, _ := NewBitVector(F)
+	var out []byte
+	for i := 0; i < numPackets; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		params := comp.scheduleParams(i)
+		if i >= 10 {
+			params.ForcedChanges = forced
+		}
+		compressed, err := comp.CompressPacket(input, params)
+		if err != nil {
+			t.Fatalf("CompressPacket failed: %v", err)
+		}
+		out = append(out, compressed...)
+
+		if i >= 10 {
+			covered, _ := forced.EqualsWhere(comp.mask, forced)
+			if !covered {
+				t.Errorf("Packet %d: forced positions missing from mask", i)
+			}
+		}
+	}
+
+	decompressed, err := Decompress(out, packetSize, 2)
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Round-trip mismatch with ForcedChanges")
+	}
+}
+
+func TestForcedChangesLengthMismatch(t *testing.T) {
+	comp, _ := NewCompressor(64, nil, 1, 10, 20, 50)
+	input, _ := NewBitVector(64)
+	forced, _ := NewBitVector(32)
+
+	_, err := comp.CompressPacket(input, &CompressParams{ForcedChanges: forced})
+	if err == nil {
+		t.Error("Expected error for ForcedChanges length mismatch")
+	}
+}
+
+func TestCompressWithReport(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(30, packetSize)
+
+	compressed, report, err := CompressWithReport(data, packetSize, 2, 5, 7, 10)
+	if err != nil {
+		t.Fatalf("CompressWithReport failed: %v", err)
+	}
+
+	plain, _ := Compress(data, packetSize, 2, 5, 7, 10)
+	if !bytes.Equal(compressed, plain) {
+		t.Error("CompressWithReport output differs from Compress")
+	}
+
+	// Packets 0..R are forced ft=rt=1; after that the countdowns fire
+	// every rtLimit/ftLimit packets
+	expectedUncompressed := []int{0, 1, 2, 10, 20}
+	expectedMask := []int{0, 1, 2, 7, 14, 21, 28}
+
+	if !slices.Equal(report.Uncompressed, expectedUncompressed) {
+		t.Errorf("Uncompressed: expected %v, got %v", expectedUncompressed, report.Uncompressed)
+	}
+	if !slices.Equal(report.MaskResends, expectedMask) {
+		t.Errorf("MaskResends: expected %v, got %v", expectedMask, report.MaskResends)
+	}
+}
+
+func TestCompressPacketAgainst(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	numPackets := 30
+	data := generateTestPackets(numPackets, packetSize)
+	packet := func(i int) *BitVector {
+		bv, _ := NewBitVector(F)
+		bv.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		return bv
+	}
+
+	// Packet 15 is sent relative to packet 3, which the ground already has
+	const against, reference = 15, 3
+
+	comp, _ := NewCompressor(F, nil, 2, 10, 20, 50)
+	var compressed []byte
+	for i := 0; i < numPackets; i++ {
+		params := comp.scheduleParams(i)
+		var out []byte
+		var err error
+		if i == against {
+			out, err = comp.CompressPacketAgainst(packet(i), packet(reference), params)
+		} else {
+			out, err = comp.CompressPacket(packet(i), params)
+		}
+		if err != nil {
+			t.Fatalf("Packet %d: compress failed: %v", i, err)
+		}
+		compressed = append(compressed, out...)
+	}
+
+	decomp, _ := NewDecompressor(F, nil, 2)
+	reader := NewBitReader(compressed)
+	for i := 0; i < numPackets; i++ {
+		var out *BitVector
+		var err error
+		if i == against {
+			out, err = decomp.DecompressPacketAgainst(reader, packet(reference))
+		} else {
+			out, err = decomp.DecompressPacket(reader)
+		}
+		if err != nil {
+			t.Fatalf("Packet %d: decompress failed: %v", i, err)
+		}
+		if !out.Equals(packet(i)) {
+			t.Errorf("Packet %d: mismatch", i)
+		}
+		reader.AlignByte()
+	}
+}
+
+func TestCompressPacketAgainstInvalid(t *testing.T) {
+	comp, _ := NewCompressor(64, nil, 1, 10, 20, 50)
+	input, _ := NewBitVector(64)
+	short, _ := NewBitVector(32)
+
+	if _, err := comp.CompressPacketAgainst(input, short, nil); err == nil {
+		t.Error("Expected error for reference length mismatch")
+	}
+	if _, err := comp.CompressPacketAgainst(short, input, nil); err == nil {
+		t.Error("Expected error for input length mismatch")
+	}
+
+	decomp, _ := NewDecompressor(64, nil, 1)
+	if _, err := decomp.DecompressPacketAgainst(NewBitReader([]byte{0}), short); err == nil {
+		t.Error("Expected error for decoder reference length mismatch")
+	}
+}
+
+func TestCompressPacketTooLargeForCount(t *testing.T) {
+	// F = 65536 bits is one past the COUNT range, so COUNT(F) cannot be sent
+	comp, err := NewCompressor(65536, nil, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("NewCompressor failed: %v", err)
+	}
+	input, _ := NewBitVector(65536)
+
+	_, err = comp.CompressPacket(input, &CompressParams{UncompressedFlag: true})
+	if err == nil || !strings.Contains(err.Error(), "COUNT") {
+		t.Errorf("Expected COUNT range error, got %v", err)
+	}
+	if comp.t != 0 {
+		t.Error("Compressor state advanced on rejected packet")
+	}
+
+	// Compressed packets do not carry COUNT(F) and still work
+	if _, err := comp.CompressPacket(input, &CompressParams{}); err != nil {
+		t.Errorf("Compressed packet failed: %v", err)
+	}
+
+	if _, err := Compress(make([]byte, 8192), 8192, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for 8192-byte packets")
+	}
+
+	// 8191 bytes is the largest packet size that round-trips
+	data := make([]byte, 2*8191)
+	data[100] = 0xA5
+	compressed, err := Compress(data, 8191, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("Compress failed for 8191-byte packets: %v", err)
+	}
+	decompressed, err := Decompress(compressed, 8191, 1)
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Round-trip mismatch for 8191-byte packets")
+	}
+}
+
+func TestStreamPacketSizeLimit(t *testing.T) {
+	// Every stream function rejects packets that do not fit COUNT(F)
+	size := MaxPacketSize + 1
+	data := make([]byte, size)
+
+	if _, err := Compress(data, size, 1, 10, 20, 50); err == nil {
+		t.Error("Compress accepted an oversized packet")
+	}
+	if _, err := CompressFrom(bytes.NewReader(data), io.Discard, size, 1, 10, 20, 50); err == nil {
+		t.Error("CompressFrom accepted an oversized packet")
+	}
+	if _, err := Decompress(data, size, 1); err == nil {
+		t.Error("Decompress accepted an oversized packet")
+	}
+	if _, err := NewSession(size, 1, 10, 20, 50); err == nil {
+		t.Error("NewSession accepted an oversized packet")
+	}
+	if _, err := NewSession(MaxPacketSize, 1, 10, 20, 50); err != nil {
+		t.Errorf("NewSession rejected MaxPacketSize: %v", err)
+	}
+
+	// The extension is requested on a Compressor, which takes larger packets
+	comp, err := NewCompressor(size*8, nil, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("NewCompressor failed: %v", err)
+	}
+	comp.SetExtendedCount(true)
+	compressed, err := comp.compressAppend(nil, data, size)
+	if err != nil {
+		t.Fatalf("Extended compression failed: %v", err)
+	}
+	decomp, _ := NewDecompressor(size*8, nil, 1)
+	decomp.SetExtendedCount(true)
+	decompressed, err := decomp.DecompressStream(compressed, len(compressed)*8)
+	if err != nil {
+		t.Fatalf("Extended decompression failed: %v", err)
+	}
+	if len(decompressed) != 1 || !bytes.Equal(decompressed[0], data) {
+		t.Error("Round-trip mismatch for extended packets")
+	}
+}
+
+func TestCompressPacketEncodingErrors(t *testing.T) {
+	// With F > 65535, RLE runs can exceed the COUNT range
+	const F = 70000
+
+	// RLE(Xt): a single change at position 0 is a run of F
+	comp, _ := NewCompressor(F, nil, 1, 10, 20, 50)
+	input, _ := NewBitVector(F)
+	if _, err := comp.CompressPacket(input, &CompressParams{}); err != nil {
+		t.Fatalf("First packet failed: %v", err)
+	}
+	input.SetBit(0, 1)
+	_, err := comp.CompressPacket(input, &CompressParams{})
+	if err == nil || !strings.Contains(err.Error(), "RLE(Xt)") {
+		t.Errorf("Expected RLE(Xt) error, got %v", err)
+	}
+
+	// RLE(mask): mask bits 1..F-1 give a dense Xt but a two-bit mask diff
+	// F-1 positions apart
+	initialMask, _ := NewBitVector(F)
+	for i := 1; i < F; i++ {
+		initialMask.SetBit(i, 1)
+	}
+	comp, _ = NewCompressor(F, initialMask, 1, 10, 20, 50)
+	input.Zero()
+	_, err = comp.CompressPacket(input, &CompressParams{SendMaskFlag: true})
+	if err == nil || !strings.Contains(err.Error(), "RLE(mask)") {
+		t.Errorf("Expected RLE(mask) error, got %v", err)
+	}
+
+	// The raw mask extension falls back to sending the mask verbatim
+	comp, _ = NewCompressor(F, initialMask, 1, 10, 20, 50)
+	if _, err := comp.CompressPacket(input, &CompressParams{SendMaskFlag: true, AllowRawMask: true}); err != nil {
+		t.Errorf("Raw mask fallback failed: %v", err)
+	}
+
+	// COUNT(F) for an uncompressed packet
+	comp, _ = NewCompressor(F, nil, 1, 10, 20, 50)
+	_, err = comp.CompressPacket(input, &CompressParams{UncompressedFlag: true})
+	if err == nil || !strings.Contains(err.Error(), "COUNT") {
+		t.Errorf("Expected COUNT error, got %v", err)
+	}
+}
+
+func TestCompressExpansionBound(t *testing.T) {
+	rng := rand.New(rand.NewSource(17))
+
+	// High-entropy input plus patterns that make the RLE counts as
+	// expensive as possible (COUNT(2) for every other position)
+	patterns := []struct {
+		name string
+		fill func(i, packet int) byte
+	}{
+		{"random", func(i, packet int) byte { return byte(rng.Intn(256)) }},
+		{"alternating", func(i, packet int) byte { return []byte{0x00, 0xAA, 0x55, 0xFF}[packet%4] }},
+		{"pairs", func(i, packet int) byte { return []byte{0x00, 0xCC}[packet%2] }},
+	}
+
+	for _, pattern := range patterns {
+		name := pattern.name
+		for _, packetSize := range []int{1, 2, 8, 90, 512} {
+			for _, robustness := range []int{1, 3, 7} {
+				numPackets := 60
+				data := make([]byte, numPackets*packetSize)
+				for p := 0; p < numPackets; p++ {
+					for i := 0; i < packetSize; i++ {
+						data[p*packetSize+i] = pattern.fill(i, p)
+					}
+				}
+
+				comp, _ := newStreamCompressor(packetSize, robustness, 10, 20, 50)
+				input, _ := NewBitVector(packetSize * 8)
+				total := 0
+				for p := 0; p < numPackets; p++ {
+					input.FromBytes(data[p*packetSize : (p+1)*packetSize])
+					out, err := comp.CompressPacket(input, comp.scheduleParams(p))
+					if err != nil {
+						t.Fatalf("%s size %d R=%d packet %d: %v", name, packetSize, robustness, p, err)
+					}
+					if bits := comp.workOutput.NumBits(); bits > MaxCompressedPacketBits(packetSize*8) {
+						t.Errorf("%s size %d R=%d packet %d: %d bits exceeds bound %d",
+							name, packetSize, robustness, p, bits, MaxCompressedPacketBits(packetSize*8))
+					}
+					total += len(out)
+				}
+
+				compressed, _ := Compress(data, packetSize, robustness, 10, 20, 50)
+				if len(compressed) != total {
+					t.Errorf("%s size %d R=%d: stream length %d, packets sum to %d",
+						name, packetSize, robustness, len(compressed), total)
+				}
+				if len(compressed) > MaxCompressedSize(packetSize, numPackets) {
+					t.Errorf("%s size %d R=%d: %d bytes exceeds bound %d",
+						name, packetSize, robustness, len(compressed), MaxCompressedSize(packetSize, numPackets))
+				}
+			}
+		}
+	}
+}
+
+func TestCompressWithProgress(t *testing.T) {
+	data := generateTestPackets(20, 90)
+	expected, _ := Compress(data, 90, 1, 10, 20, 50)
+
+	calls := 0
+	compressed, err := CompressWithProgress(data, 90, 1, 10, 20, 50, func(index, total, outBytesSoFar int) bool {
+		if index != calls || total != 20 {
+			t.Errorf("Call %d: got index %d, total %d", calls, index, total)
+		}
+		if outBytesSoFar <= 0 || outBytesSoFar > len(expected) {
+			t.Errorf("Call %d: implausible output size %d", calls, outBytesSoFar)
+		}
+		calls++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("CompressWithProgress failed: %v", err)
+	}
+	if calls != 20 {
+		t.Errorf("Expected 20 callbacks, got %d", calls)
+	}
+	if !bytes.Equal(compressed, expected) {
+		t.Error("Output differs from Compress")
+	}
+
+	// Abort after packet 7: the partial output is a valid 8-packet stream
+	partial, err := CompressWithProgress(data, 90, 1, 10, 20, 50, func(index, total, outBytesSoFar int) bool {
+		return index < 7
+	})
+	if err != nil {
+		t.Fatalf("Aborted CompressWithProgress failed: %v", err)
+	}
+	decompressed, err := Decompress(partial, 90, 1)
+	if err != nil {
+		t.Fatalf("Decompress of partial output failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data[:8*90]) {
+		t.Error("Partial output does not decode to the first 8 packets")
+	}
+}
+
+func TestLastRobustnessWindow(t *testing.T) {
+	const F = 64
+	robustness := 2
+	comp, _ := NewCompressor(F, nil, robustness, 0, 0, 0)
+	if comp.LastRobustnessWindow() != nil {
+		t.Error("Expected nil before the first packet")
+	}
+
+	input, _ := NewBitVector(F)
+	var masks []*BitVector
+	for i := 0; i < 12; i++ {
+		// A new bit starts toggling every packet, so the mask keeps changing
+		for j := 0; j <= i; j++ {
+			input.SetBit(j*5, (i+j)&1)
+		}
+		params := comp.scheduleParams(i)
+		if _, err := comp.CompressPacket(input, params); err != nil {
+			t.Fatalf("Packet %d: %v", i, err)
+		}
+		masks = append(masks, comp.mask.Copy())
+
+		window := comp.LastRobustnessWindow()
+		if i <= robustness {
+			continue
+		}
+
+		// Xt is the OR of the changes of this packet and the Rt before it
+		expected, _ := NewBitVector(F)
+		change, _ := NewBitVector(F)
+		for k := i - robustness; k <= i; k++ {
+			change.XORInto(masks[k], masks[k-1])
+			expected.ORInto(expected, change)
+		}
+		if expected.HammingWeight() == 0 {
+			t.Fatalf("Packet %d: test data should change the mask", i)
+		}
+		if !window.Equals(expected) {
+			t.Errorf("Packet %d: window %s, expected %s", i, window, expected)
+		}
+
+		// The copy is independent of the compressor, and a dry run does
+		// not disturb it
+		window.Zero()
+		if _, err := comp.SizeOfNext(input, nil); err != nil {
+			t.Fatalf("SizeOfNext failed: %v", err)
+		}
+		if !comp.LastRobustnessWindow().Equals(expected) {
+			t.Errorf("Packet %d: window changed by copy or SizeOfNext", i)
+		}
+	}
+
+	comp.Reset()
+	if comp.LastRobustnessWindow() != nil {
+		t.Error("Expected nil after Reset")
+	}
+}
+
+func TestSizeOfNext(t *testing.T) {
+	packetSize := 90
+	data := generateTestPackets(60, packetSize)
+
+	comp, _ := newStreamCompressor(packetSize, 2, 10, 20, 50)
+	sink := &countingSink{}
+	comp.SetMetrics(sink)
+	input, _ := NewBitVector(packetSize * 8)
+
+	for i := 0; i < 60; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		params := comp.scheduleParams(i)
+
+		before := comp.MarshalState()
+		size, err := comp.SizeOfNext(input, params)
+		if err != nil {
+			t.Fatalf("Packet %d: SizeOfNext failed: %v", i, err)
+		}
+		if !bytes.Equal(comp.MarshalState(), before) {
+			t.Fatalf("Packet %d: SizeOfNext changed compressor state", i)
+		}
+
+		// A dry run of a sync packet must not disturb the stream either
+		if _, err := comp.SizeOfNext(input, &CompressParams{SendMaskFlag: true, UncompressedFlag: true}); err != nil {
+			t.Fatalf("Packet %d: SizeOfNext (sync) failed: %v", i, err)
+		}
+
+		if _, err := comp.CompressPacket(input, params); err != nil {
+			t.Fatalf("Packet %d: CompressPacket failed: %v", i, err)
+		}
+		if size != comp.workOutput.NumBits() {
+			t.Errorf("Packet %d: SizeOfNext %d, actual %d bits", i, size, comp.workOutput.NumBits())
+		}
+	}
+
+	if sink.packets != 60 {
+		t.Errorf("Expected 60 metrics observations, got %d", sink.packets)
+	}
+
+	// The stream is unaffected by the dry runs
+	var stream []byte
+	comp.Reset()
+	for i := 0; i < 60; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		params := comp.scheduleParams(i)
+		comp.SizeOfNext(input, params)
+		out, _ := comp.CompressPacket(input, params)
+		stream = append(stream, out...)
+	}
+	expected, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	if !bytes.Equal(stream, expected) {
+		t.Error("Stream with dry runs differs from Compress")
+	}
+
+	if _, err := comp.SizeOfNext(nil, nil); err == nil {
+		t.Error("Expected error for nil input")
+	}
+}
+
+func TestCompressScheduleRoundTrip(t *testing.T) {
+	packetSize := 32
+	numPackets := 400
+
+	// Mix a slow counter, a random byte and occasional bursts, so masks
+	// grow, shrink on pt refreshes and change between ft/rt packets
+	rng := rand.New(rand.NewSource(1425))
+	data := make([]byte, numPackets*packetSize)
+	for i := 0; i < numPackets; i++ {
+		packet := data[i*packetSize : (i+1)*packetSize]
+		packet[0] = byte(i >> 3)
+		packet[1] = byte(i)
+		packet[5] = byte(rng.Intn(256))
+		if i%37 < 4 {
+			rng.Read(packet[10:20])
+		}
+		packet[31] = byte((i / 50) % 2)
+	}
+
+	schedules := []struct{ pt, ft, rt, robustness int }{
+		{10, 20, 50, 1},
+		{20, 50, 100, 2},
+		{1, 1, 1, 1},
+		{1, 2, 3, 3},
+		{5, 7, 11, 7},
+		{3, 100, 400, 4},
+		{400, 400, 400, 1},
+		{13, 6, 9, 5},
+	}
+
+	for _, s := range schedules {
+		compressed, report, err := CompressWithReport(data, packetSize, s.robustness, s.pt, s.ft, s.rt)
+		if err != nil {
+			t.Fatalf("%+v: compression failed: %v", s, err)
+		}
+
+		// The first R+1 packets are always uncompressed with the mask
+		for i := 0; i <= s.robustness; i++ {
+			if !slices.Contains(report.Uncompressed, i) || !slices.Contains(report.MaskResends, i) {
+				t.Errorf("%+v: packet %d is not an init packet", s, i)
+			}
+		}
+
+		decompressed, err := Decompress(compressed, packetSize, s.robustness)
+		if err != nil {
+			t.Fatalf("%+v: decompression failed: %v", s, err)
+		}
+		if len(decompressed) != len(data) {
+			t.Fatalf("%+v: decompressed %d bytes, expected %d", s, len(decompressed), len(data))
+		}
+		if !bytes.Equal(decompressed, data) {
+			for i := 0; i < numPackets; i++ {
+				if !bytes.Equal(decompressed[i*packetSize:(i+1)*packetSize], data[i*packetSize:(i+1)*packetSize]) {
+					t.Errorf("%+v: first mismatch at packet %d", s, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestParameterSchedule(t *testing.T) {
+	// Expected flags per packet, one character per packet in groups of 10.
+	// Packets 0..R are init packets (ft=1, pt=0, rt=1); after that each
+	// flag is set on every packet index that is a multiple of its limit,
+	// since the counters start at the limit and fire on reaching 1.
+	cases := []struct {
+		pt, ft, rt, robustness int
+		send, newMask, uncomp  string
+	}{
+		{
+			pt: 10, ft: 20, rt: 50, robustness: 1,
+			send:    "1100000000 0000000000 1000000000 0000000000 1000000000 0000000000",
+			newMask: "0000000000 1000000000 1000000000 1000000000 1000000000 1000000000",
+			uncomp:  "1100000000 0000000000 0000000000 0000000000 0000000000 1000000000",
+		},
+		{
+			pt: 3, ft: 5, rt: 7, robustness: 2,
+			send:    "1110010000 1000010000 1000010000 1000010000 1000010000 1000010000",
+			newMask: "0001001001 0010010010 0100100100 1001001001 0010010010 0100100100",
+			uncomp:  "1110000100 0000100000 0100000010 0000010000 0010000001 0000001000",
+		},
+		{
+			pt: 4, ft: 4, rt: 4, robustness: 0,
+			send:    "1000100010 0010001000 1000100010 0010001000 1000100010 0010001000",
+			newMask: "0000100010 0010001000 1000100010 0010001000 1000100010 0010001000",
+			uncomp:  "1000100010 0010001000 1000100010 0010001000 1000100010 0010001000",
+		},
+	}
+
+	flag := func(b bool) byte {
+		if b {
+			return '1'
+		}
+		return '0'
+	}
+
+	for _, c := range cases {
+		comp, _ := NewCompressor(64, nil, c.robustness, c.pt, c.ft, c.rt)
+
+		var send, newMask, uncomp []byte
+		for i := 0; i < 60; i++ {
+			if i > 0 && i%10 == 0 {
+				send = append(send, ' ')
+				newMask = append(newMask, ' ')
+				uncomp = append(uncomp, ' ')
+			}
+			params := comp.scheduleParams(i)
+			send = append(send, flag(params.SendMaskFlag))
+			newMask = append(newMask, flag(params.NewMaskFlag))
+			uncomp = append(uncomp, flag(params.UncompressedFlag))
+		}
+
+		if string(send) != c.send {
+			t.Errorf("pt=%d ft=%d rt=%d R=%d SendMaskFlag:\n got  %s\n want %s",
+				c.pt, c.ft, c.rt, c.robustness, send, c.send)
+		}
+		if string(newMask) != c.newMask {
+			t.Errorf("pt=%d ft=%d rt=%d R=%d NewMaskFlag:\n got  %s\n want %s",
+				c.pt, c.ft, c.rt, c.robustness, newMask, c.newMask)
+		}
+		if string(uncomp) != c.uncomp {
+			t.Errorf("pt=%d ft=%d rt=%d R=%d UncompressedFlag:\n got  %s\n want %s",
+				c.pt, c.ft, c.rt, c.robustness, uncomp, c.uncomp)
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 
 // Constants
 const (
-	MaxHistory    = 16 // History depth for change vectors
+	MaxHistory    = 16 // History depth for Ct change tracking
 	MaxVtHistory  = 16 // History size for Vt calculation
 	MaxRobustness = 7  // Maximum robustness level
 )
@@ -18,6 +18,23 @@ type CompressParams struct {
 	NewMaskFlag      bool // pt: Update mask from build vector
 	SendMaskFlag     bool // ft: Include mask in output
 	UncompressedFlag bool // rt: Send uncompressed
+
+	// AllowRawMask enables a NON-STANDARD extension of the qt component:
+	// when ft=1, a flag bit follows the '1' and selects between the usual
+	// RLE(Mt XOR (Mt<<)) ('0') and the F raw mask bits ('1'), whichever is
+	// shorter. Output is only decodable by a Decompressor with
+	// SetAllowRawMask(true), so every packet of a stream must agree.
+	AllowRawMask bool
+
+	// ForcedChanges, if non-nil, marks positions that become unpredictable
+	// regardless of the observed data. They are ORed into the updated mask
+	// Mt, so they appear in the change vector and robustness window like
+	// any observed change and need no decoder support. Must be F bits long.
+	//
+	// Each forced position costs one raw bit per packet for as long as it
+	// stays in the mask (until a pt=1 mask refresh drops it, if it has not
+	// actually changed), plus its share of RLE(Xt) when it first appears.
+	ForcedChanges *BitVector
 }
 
 // Compressor maintains state for POCKET+ compression.
@@ -38,10 +55,16 @@ type Compressor struct {
 	prevInput   *BitVector
 	initialMask *BitVector
 
-	// Change history (circular buffer)
-	changeHistory [MaxHistory]*BitVector
+	// Change history (circular buffer of robustness+1 entries: the current
+	// change plus the Rt previous ones consulted by the robustness window)
+	changeHistory []*BitVector
 	historyIndex  int
 
+	// Run lengths of consecutive empty change vectors (circular buffer),
+	// used for Ct without keeping the full change vectors around
+	zeroRunHistory [MaxHistory]int
+	zeroRunIndex   int
+
 	// Flag history for ct calculation
 	newMaskFlagHistory [MaxVtHistory]int
 	flagHistoryIndex   int
@@ -49,6 +72,9 @@ type Compressor struct {
 	// Cycle counter
 	t int
 
+	// Set when the last packet's mask had every bit unpredictable
+	saturated bool
+
 	// Countdown counters for automatic mode
 	ptCounter int
 	ftCounter int
@@ -64,13 +90,55 @@ type Compressor struct {
 	workMaskDiff    *BitVector // For mask XOR
 	workChanges     *BitVector // For input XOR prevInput
 	workOutput      *BitBuffer // For output buffer
+	workMaskRLE     *BitBuffer // For measuring the RLE mask (AllowRawMask)
+
+	// Optional per-packet metrics (nil = disabled)
+	metrics MetricsSink
+
+	// Totals since the last Reset (see Stats)
+	stats StreamStats
+
+	// State saved around SizeOfNext dry runs (allocated on first use)
+	dryRun *compressorSnapshot
+
+	// Non-standard mask check extension (0 = disabled)
+	maskCheckInterval int
+
+	// One-shot request to send the mask with the next packet
+	maskResend bool
+
+	// Debug: allocate workOutput per packet instead of reusing it
+	noBufferReuse bool
+
+	// Value of the byte-alignment padding bits of each packet
+	padBit int
+
+	// Non-standard chained COUNT extension (see SetExtendedCount)
+	extendedCount bool
+
+	// Keep the mask equal to the initial mask (see SetFixedMask)
+	fixedMask bool
+
+	// Bit extraction path (see NewCompressorTuned)
+	workload    WorkloadHint
+	denseWords  bool // BitExtract copies all-ones mask words whole
+	tuneChanges int  // Changed bits seen while WorkloadAuto measures
 }
 
 // NewCompressor creates a new compressor.
+//
+// Uncompressed packets (rt=1) carry COUNT(F), which limits F to MaxCount
+// (65535) bits. Larger F is accepted, but any packet with UncompressedFlag
+// set is then rejected; since every stream starts uncompressed, the stream functions
+// effectively support packets of at most 8191 bytes. SetExtendedCount lifts
+// the limit at the cost of CCSDS compliance.
 func NewCompressor(F int, initialMask *BitVector, robustness, ptLimit, ftLimit, rtLimit int) (*Compressor, error) {
 	if F <= 0 {
 		return nil, errors.New("F must be positive")
 	}
+	if F > MaxVectorBits {
+		return nil, fmt.Errorf("F must not exceed %d bits", MaxVectorBits)
+	}
 	if robustness < 0 || robustness > MaxRobustness {
 		return nil, fmt.Errorf("robustness must be between 0 and %d", MaxRobustness)
 	}
@@ -81,6 +149,7 @@ func NewCompressor(F int, initialMask *BitVector, robustness, ptLimit, ftLimit,
 		ptLimit:    ptLimit,
 		ftLimit:    ftLimit,
 		rtLimit:    rtLimit,
+		workload:   WorkloadDense,
 	}
 
 	// Initialize bit vectors
@@ -95,7 +164,8 @@ func NewCompressor(F int, initialMask *BitVector, robustness, ptLimit, ftLimit,
 	comp.initialMask, _ = NewBitVector(F)
 
 	// Initialize change history
-	for i := 0; i < MaxHistory; i++ {
+	comp.changeHistory = make([]*BitVector, robustness+1)
+	for i := range comp.changeHistory {
 		comp.changeHistory[i], _ = NewBitVector(F)
 	}
 
@@ -109,6 +179,7 @@ func NewCompressor(F int, initialMask *BitVector, robustness, ptLimit, ftLimit,
 	comp.workMaskDiff, _ = NewBitVector(F)
 	comp.workChanges, _ = NewBitVector(F)
 	comp.workOutput = NewBitBuffer()
+	comp.workMaskRLE = NewBitBuffer()
 
 	// Set initial mask if provided
 	if initialMask != nil {
@@ -136,15 +207,23 @@ func (comp *Compressor) Reset() {
 	comp.prevInput.Zero()
 
 	// Clear change history
-	for i := 0; i < MaxHistory; i++ {
+	for i := range comp.changeHistory {
 		comp.changeHistory[i].Zero()
 	}
+	for i := 0; i < MaxHistory; i++ {
+		comp.zeroRunHistory[i] = 0
+	}
+	comp.zeroRunIndex = 0
 
 	// Clear flag history
 	for i := 0; i < MaxVtHistory; i++ {
 		comp.newMaskFlagHistory[i] = 0
 	}
 	comp.flagHistoryIndex = 0
+	comp.saturated = false
+	comp.maskResend = false
+	comp.stats = StreamStats{}
+	comp.resetWorkload()
 
 	// Reset countdown counters
 	comp.ptCounter = comp.ptLimit
@@ -152,19 +231,89 @@ func (comp *Compressor) Reset() {
 	comp.rtCounter = comp.rtLimit
 }
 
+// ResetWith resets the compressor like Reset, but first replaces the
+// initial mask, so one compressor can be reused across streams that each
+// start from their own mask. The mask must be F bits long.
+func (comp *Compressor) ResetWith(initialMask *BitVector) error {
+	if initialMask == nil || initialMask.length != comp.F {
+		return errors.New("initial mask must be non-nil and match F length")
+	}
+
+	comp.initialMask.CopyFrom(initialMask)
+	comp.Reset()
+
+	return nil
+}
+
+// validatePacket checks CompressPacket arguments before any state changes.
+func (comp *Compressor) validatePacket(input *BitVector, params *CompressParams) error {
+	if input == nil || input.length != comp.F {
+		return errors.New("input must be non-nil and match F length")
+	}
+	if params != nil && params.ForcedChanges != nil && params.ForcedChanges.length != comp.F {
+		return errors.New("ForcedChanges must match F length")
+	}
+	if params != nil && params.ForcedChanges != nil && comp.fixedMask {
+		return errors.New("ForcedChanges cannot be used with a fixed mask")
+	}
+	if params != nil && params.UncompressedFlag && !comp.extendedCount && CountEncodedBits(comp.F) == 0 {
+		return fmt.Errorf("F=%d bits is too large for COUNT(F) in an uncompressed packet (max %d bits)", comp.F, MaxCount)
+	}
+	return nil
+}
+
+// CompressPacketAgainst compresses input relative to a caller-supplied
+// reference packet instead of the previous input, e.g. to re-transmit a
+// single packet when the ground already holds a recent one. The reference
+// replaces It-1 for this packet only; the stream continues from input.
+//
+// The receiver must decode with DecompressPacketAgainst and the same
+// reference.
+func (comp *Compressor) CompressPacketAgainst(input, reference *BitVector, params *CompressParams) ([]byte, error) {
+	if reference == nil || reference.length != comp.F {
+		return nil, errors.New("reference must be non-nil and match F length")
+	}
+	if err := comp.validatePacket(input, params); err != nil {
+		return nil, err
+	}
+
+	comp.prevInput.CopyFrom(reference)
+	return comp.CompressPacket(input, params)
+}
+
 // CompressPacket compresses a single input packet.
+//
+// Encoding errors are returned rather than emitting a corrupt packet. For
+// F <= 65535 they cannot occur once the input has been validated; for
+// larger F an RLE run beyond the COUNT range fails after the compressor
+// state has been updated, and the compressor must be Reset before reuse.
 func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams) ([]byte, error) {
-	if input == nil || input.length != comp.F {
-		return nil, errors.New("input must be non-nil and match F length")
+	if err := comp.validatePacket(input, params); err != nil {
+		return nil, err
 	}
 
 	// Use default params if none provided
 	if params == nil {
 		params = &CompressParams{MinRobustness: comp.robustness}
 	}
+	if comp.maskResend && !params.SendMaskFlag {
+		forced := *params
+		forced.SendMaskFlag = true
+		params = &forced
+	}
+	if comp.fixedMask {
+		var err error
+		if params, err = comp.fixedMaskParams(input, params); err != nil {
+			return nil, err
+		}
+	}
 
 	// Reuse pre-allocated output buffer
-	comp.workOutput.Clear()
+	if comp.noBufferReuse {
+		comp.workOutput = NewBitBuffer()
+	} else {
+		comp.workOutput.Clear()
+	}
 	output := comp.workOutput
 
 	// ================================================================
@@ -180,15 +329,20 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 	prevBuild := comp.workCombined
 
 	// Update build vector (Equation 6)
-	if comp.t > 0 {
+	if comp.t > 0 && !comp.fixedMask {
 		updateBuildInternal(comp.build, input, comp.prevInput, comp.workChanges, params.NewMaskFlag, comp.t)
 	}
 
 	// Update mask vector (Equation 7)
-	if comp.t > 0 {
+	if comp.t > 0 && !comp.fixedMask {
 		updateMaskInternal(comp.mask, input, comp.prevInput, prevBuild, comp.workChanges, params.NewMaskFlag)
 	}
 
+	// Apply externally forced unpredictable positions
+	if params.ForcedChanges != nil {
+		comp.mask.ORInto(comp.mask, params.ForcedChanges)
+	}
+
 	// Compute change vector (Equation 8) - reuse workChange
 	change := comp.workChange
 	computeChangeInternal(change, comp.mask, prevMask, comp.t)
@@ -219,7 +373,9 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 	// ================================================================
 
 	// 1. RLE(Xt) - Run-length encode the robustness window
-	RLEEncode(output, Xt)
+	if err := rleEncode(output, Xt, comp.extendedCount); err != nil {
+		return nil, fmt.Errorf("RLE(Xt): %w", err)
+	}
 
 	// 2. BIT4(Vt) - 4-bit effective robustness level
 	output.AppendValue(uint64(Vt), 4)
@@ -231,18 +387,11 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 		output.AppendBit(et)
 
 		if et != 0 {
-			// kt - Output '1' for positive updates (mask=0), '0' for negative
-			// Extract INVERTED mask values in forward order - reuse workInvMask
-			invertedMask := comp.workInvMask
-			for j := 0; j < comp.mask.length; j++ {
-				maskBit := comp.mask.GetBit(j)
-				if maskBit == 0 {
-					invertedMask.SetBit(j, 1)
-				} else {
-					invertedMask.SetBit(j, 0)
-				}
+			// kt - '1' for positive updates (mask=0), '0' for negative,
+			// see ComputeKt - reuse workInvMask
+			if err := computeKtInto(output, comp.mask, Xt, comp.workInvMask); err != nil {
+				return nil, fmt.Errorf("kt: %w", err)
 			}
-			BitExtractForward(output, invertedMask, Xt)
 
 			// Calculate and encode ct
 			ct := comp.computeCtFlag(Vt, params.NewMaskFlag)
@@ -263,9 +412,11 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 			output.AppendBit(1) // Flag: mask follows
 
 			// Encode mask as RLE(M XOR (M<<)) - reuse working buffers
-			leftShiftInto(comp.workMaskShifted, comp.mask)
-			comp.workMaskDiff.XORInto(comp.mask, comp.workMaskShifted)
-			RLEEncode(output, comp.workMaskDiff)
+			if params.AllowRawMask {
+				comp.encodeMaskShortest(output)
+			} else if err := encodeMaskDiffInto(output, comp.mask, comp.workMaskShifted, comp.workMaskDiff, comp.extendedCount); err != nil {
+				return nil, fmt.Errorf("RLE(mask): %w", err)
+			}
 		} else {
 			output.AppendBit(0) // Flag: no mask
 		}
@@ -278,7 +429,9 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 	if params.UncompressedFlag {
 		// '1' || COUNT(F) || It
 		output.AppendBit(1) // Flag: full input follows
-		CountEncode(output, comp.F)
+		if err := comp.countEncode(output, comp.F); err != nil {
+			return nil, fmt.Errorf("COUNT(F): %w", err)
+		}
 		output.AppendBitVector(input)
 	} else {
 		if dt == 0 {
@@ -289,20 +442,29 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 		// Determine extraction mask based on ct
 		ct := comp.computeCtFlag(Vt, params.NewMaskFlag)
 
+		extractMask := comp.mask
 		if ct != 0 && Vt > 0 {
 			// BE(It, (Xt OR Mt)) - extract bits where mask OR changes are set
 			comp.workExtractMask.ORInto(comp.mask, Xt)
-			BitExtract(output, input, comp.workExtractMask)
-		} else {
-			// BE(It, Mt) - extract only unpredictable bits
-			BitExtract(output, input, comp.mask)
+			extractMask = comp.workExtractMask
+		}
+		// Otherwise BE(It, Mt) - extract only unpredictable bits
+		if err := bitExtract(output, input, extractMask, comp.denseWords); err != nil {
+			return nil, fmt.Errorf("BE: %w", err)
 		}
 	}
 
+	if comp.maskCheckInterval > 0 {
+		comp.encodeMaskCheck(output)
+	}
+
 	// ================================================================
 	// STEP 3: Update State for Next Cycle
 	// ================================================================
 
+	comp.maskResend = false
+	comp.observeWorkload(input)
+
 	// Save current input and mask as previous for next iteration
 	comp.prevInput.CopyFrom(input)
 	comp.prevMask.CopyFrom(comp.mask)
@@ -315,13 +477,274 @@ func (comp *Compressor) CompressPacket(input *BitVector, params *CompressParams)
 	}
 	comp.flagHistoryIndex = (comp.flagHistoryIndex + 1) % MaxVtHistory
 
+	// Record the empty-change run length ending at this packet
+	prevRun := comp.zeroRunHistory[(comp.zeroRunIndex+MaxHistory-1)%MaxHistory]
+	run := 0
+	if change.HammingWeight() == 0 {
+		run = prevRun + 1
+		if run > MaxHistory {
+			run = MaxHistory
+		}
+	}
+	comp.zeroRunHistory[comp.zeroRunIndex] = run
+	comp.zeroRunIndex = (comp.zeroRunIndex + 1) % MaxHistory
+
+	// A saturated mask means every bit is sent verbatim
+	comp.saturated = comp.mask.HammingWeight() == comp.F
+
 	// Advance time
 	comp.t++
 
 	// Advance history index (circular buffer)
-	comp.historyIndex = (comp.historyIndex + 1) % MaxHistory
+	comp.historyIndex = (comp.historyIndex + 1) % len(comp.changeHistory)
+
+	if comp.metrics != nil {
+		comp.metrics.ObservePacket(comp.F, output.NumBits(), params.UncompressedFlag)
+	}
+
+	result := output.ToBytesPadded(comp.padBit)
+	comp.stats.observe(comp.F, len(result)*8, params, Vt)
+
+	return result, nil
+}
+
+// CompressPacketTo compresses input like CompressPacket and appends the
+// byte-padded packet to dst, e.g. the BitBuffer of a CRCSink so the
+// stream's checksum is kept as it is written. dst is left unchanged on
+// error.
+func (comp *Compressor) CompressPacketTo(dst *BitBuffer, input *BitVector, params *CompressParams) error {
+	if dst == nil {
+		return errors.New("dst must not be nil")
+	}
+	packet, err := comp.CompressPacket(input, params)
+	if err != nil {
+		return err
+	}
+	dst.AppendBits(packet, len(packet)*8)
+	return nil
+}
+
+// encodeMaskShortest writes the non-standard AllowRawMask form of the mask:
+// '0' || RLE(Mt XOR (Mt<<)), or '1' || Mt when the RLE would exceed F bits
+// or cannot be encoded.
+func (comp *Compressor) encodeMaskShortest(output *BitBuffer) {
+	comp.workMaskRLE.Clear()
+	err := encodeMaskDiffInto(comp.workMaskRLE, comp.mask, comp.workMaskShifted, comp.workMaskDiff, comp.extendedCount)
+
+	// A run beyond the COUNT range can only be sent raw
+	if err != nil || comp.workMaskRLE.NumBits() > comp.F {
+		output.AppendBit(1)
+		output.AppendBitVector(comp.mask)
+		return
+	}
+
+	output.AppendBit(0)
+	output.AppendBits(comp.workMaskRLE.ToBytes(), comp.workMaskRLE.NumBits())
+}
+
+// MaskSaturated reports whether the mask after the last CompressPacket call
+// had every bit marked unpredictable, as happens with noisy or random data.
+//
+// In this state POCKET+ already degrades to passthrough: BE(It, Mt) carries
+// all F input bits and the mask change header shrinks to a few bits once the
+// mask stops changing. The compressor deliberately does not switch such
+// packets to uncompressed (rt=1), since that would add COUNT(F) and the
+// ft/rt flags on top of the same F bits and increase the expansion.
+func (comp *Compressor) MaskSaturated() bool {
+	return comp.saturated
+}
 
-	return output.ToBytes(), nil
+// SetNoBufferReuse is a debugging aid: when enabled, every CompressPacket
+// call builds its output in a newly allocated buffer instead of reusing
+// one. Output must be identical either way; a difference points to a
+// buffer reuse bug rather than an algorithm bug.
+func (comp *Compressor) SetNoBufferReuse(enabled bool) {
+	comp.noBufferReuse = enabled
+}
+
+// SetPadBit sets the value (0 or 1) of the bits that pad each compressed
+// packet to a whole byte. The default is 0. Decompressors skip the padding,
+// so either value decodes the same, except with
+// Decompressor.SetStrictTrailing, which requires zero padding.
+func (comp *Compressor) SetPadBit(bit int) {
+	comp.padBit = bit & 1
+}
+
+// RequestMaskResend makes the next compressed packet carry the full mask
+// (ft=1) whatever its params say, e.g. right after an onboard
+// reconfiguration. The request is one-shot and does not affect the ft
+// period counter used by the stream functions. Reset cancels it; a pending
+// request is saved by MarshalState.
+func (comp *Compressor) RequestMaskResend() {
+	comp.maskResend = true
+}
+
+// LastRobustnessWindow returns a copy of the robustness window Xt (the OR
+// of the last Rt+1 mask changes) encoded by the last CompressPacket call,
+// or nil if no packet has been compressed since creation or Reset. Its
+// Hamming weight compared with that of the packet's own change shows how
+// much the window widens the RLE(Xt) region at a given robustness.
+func (comp *Compressor) LastRobustnessWindow() *BitVector {
+	if comp.t == 0 {
+		return nil
+	}
+	return comp.workXt.Copy()
+}
+
+// EmitSync compresses input as a self-contained sync packet.
+//
+// The packet carries the full mask (ft=1) and the full input (rt=1), so a
+// freshly reset decompressor can start decoding at this packet and stay in
+// step with the rest of the stream. Compressor state advances as for any
+// other packet.
+//
+// A sync packet costs roughly F + COUNT(F) bits plus the RLE of the mask,
+// the same as a periodic uncompressed packet. Emitting one every N packets
+// therefore adds about F/N bits per packet; frequent flushing of a
+// streaming writer quickly erodes the compression ratio.
+func (comp *Compressor) EmitSync(input *BitVector) ([]byte, error) {
+	return comp.CompressPacket(input, &CompressParams{
+		MinRobustness:    comp.robustness,
+		SendMaskFlag:     true,
+		UncompressedFlag: true,
+	})
+}
+
+// ChangeRobustness switches the compressor to robustness newR (0-7) and
+// compresses input as a sync packet (see EmitSync) under the new level.
+//
+// The robustness window and the Ct history are cleared first, so the sync
+// packet and the ones after it only cover changes from this point on; the
+// sync packet itself carries the full mask and input, so nothing earlier
+// is needed to decode them. Robustness is signalled in-band through Vt, so
+// a Decompressor decodes the stream across the change without being told:
+// Decompress and friends keep working with the robustness the stream was
+// started with. Unlike a stream start, the packets following the change
+// are not forced to be uncompressed. Reset keeps the new robustness.
+//
+// On an error the compressor is unchanged.
+func (comp *Compressor) ChangeRobustness(newR int, input *BitVector) ([]byte, error) {
+	if newR < 0 || newR > MaxRobustness {
+		return nil, fmt.Errorf("robustness must be between 0 and %d", MaxRobustness)
+	}
+	if err := comp.validatePacket(input, &CompressParams{UncompressedFlag: true}); err != nil {
+		return nil, err
+	}
+
+	// Resize the window, reusing the existing vectors
+	history := comp.changeHistory
+	for len(history) < newR+1 {
+		bv, _ := NewBitVector(comp.F)
+		history = append(history, bv)
+	}
+	comp.changeHistory = history[:newR+1]
+	for _, change := range comp.changeHistory {
+		change.Zero()
+	}
+	comp.historyIndex = 0
+
+	for i := range comp.zeroRunHistory {
+		comp.zeroRunHistory[i] = 0
+	}
+	comp.zeroRunIndex = 0
+
+	comp.robustness = newR
+	return comp.EmitSync(input)
+}
+
+// SizeOfNext returns the length in bits (before byte alignment) of the
+// packet CompressPacket would produce for input and params, without
+// advancing the compressor, e.g. to decide whether the packet still fits a
+// frame. Metrics sinks are not notified.
+//
+// The packet is fully encoded and then the state is restored, so this
+// costs about as much as compressing it.
+func (comp *Compressor) SizeOfNext(input *BitVector, params *CompressParams) (int, error) {
+	if comp.dryRun == nil {
+		comp.dryRun = newCompressorSnapshot(comp.F)
+	}
+	comp.dryRun.save(comp)
+
+	metrics := comp.metrics
+	comp.metrics = nil
+	_, err := comp.CompressPacket(input, params)
+	comp.metrics = metrics
+	numBits := comp.workOutput.NumBits()
+
+	comp.dryRun.restore(comp)
+	if err != nil {
+		return 0, err
+	}
+	return numBits, nil
+}
+
+// compressorSnapshot holds the compressor state that one CompressPacket
+// call can modify.
+type compressorSnapshot struct {
+	mask, prevMask, build, prevInput, change, xt *BitVector
+
+	historyIndex       int
+	zeroRunHistory     [MaxHistory]int
+	zeroRunIndex       int
+	newMaskFlagHistory [MaxVtHistory]int
+	flagHistoryIndex   int
+	t                  int
+	saturated          bool
+	maskResend         bool
+	stats              StreamStats
+	denseWords         bool
+	tuneChanges        int
+}
+
+func newCompressorSnapshot(F int) *compressorSnapshot {
+	snap := &compressorSnapshot{}
+	snap.mask, _ = NewBitVector(F)
+	snap.prevMask, _ = NewBitVector(F)
+	snap.build, _ = NewBitVector(F)
+	snap.prevInput, _ = NewBitVector(F)
+	snap.change, _ = NewBitVector(F)
+	snap.xt, _ = NewBitVector(F)
+	return snap
+}
+
+func (snap *compressorSnapshot) save(comp *Compressor) {
+	snap.mask.CopyFrom(comp.mask)
+	snap.prevMask.CopyFrom(comp.prevMask)
+	snap.build.CopyFrom(comp.build)
+	snap.prevInput.CopyFrom(comp.prevInput)
+	snap.change.CopyFrom(comp.changeHistory[comp.historyIndex])
+	snap.xt.CopyFrom(comp.workXt)
+	snap.historyIndex = comp.historyIndex
+	snap.zeroRunHistory = comp.zeroRunHistory
+	snap.zeroRunIndex = comp.zeroRunIndex
+	snap.newMaskFlagHistory = comp.newMaskFlagHistory
+	snap.flagHistoryIndex = comp.flagHistoryIndex
+	snap.t = comp.t
+	snap.saturated = comp.saturated
+	snap.maskResend = comp.maskResend
+	snap.stats = comp.stats
+	snap.denseWords = comp.denseWords
+	snap.tuneChanges = comp.tuneChanges
+}
+
+func (snap *compressorSnapshot) restore(comp *Compressor) {
+	comp.mask.CopyFrom(snap.mask)
+	comp.prevMask.CopyFrom(snap.prevMask)
+	comp.build.CopyFrom(snap.build)
+	comp.prevInput.CopyFrom(snap.prevInput)
+	comp.historyIndex = snap.historyIndex
+	comp.changeHistory[comp.historyIndex].CopyFrom(snap.change)
+	comp.workXt.CopyFrom(snap.xt)
+	comp.zeroRunHistory = snap.zeroRunHistory
+	comp.zeroRunIndex = snap.zeroRunIndex
+	comp.newMaskFlagHistory = snap.newMaskFlagHistory
+	comp.flagHistoryIndex = snap.flagHistoryIndex
+	comp.t = snap.t
+	comp.saturated = snap.saturated
+	comp.maskResend = snap.maskResend
+	comp.stats = snap.stats
+	comp.denseWords = snap.denseWords
+	comp.tuneChanges = snap.tuneChanges
 }
 
 // computeRobustnessWindowInto computes Xt = OR of recent change vectors into dst.
@@ -342,7 +765,7 @@ func (comp *Compressor) computeRobustnessWindowInto(currentChange *BitVector, ds
 		// OR with historical changes (going backwards from current)
 		for i := 1; i <= numChanges; i++ {
 			// Calculate index of change from i iterations ago
-			histIdx := (comp.historyIndex + MaxHistory - i) % MaxHistory
+			histIdx := (comp.historyIndex + len(comp.changeHistory) - i) % len(comp.changeHistory)
 			// OR in place: dst = dst OR changeHistory[histIdx]
 			for w := 0; w < dst.numWords; w++ {
 				dst.data[w] |= comp.changeHistory[histIdx].data[w]
@@ -373,23 +796,21 @@ func (comp *Compressor) computeEffectiveRobustness(currentChange *BitVector) int
 
 	// For t > Rt, compute Ct
 	if comp.t > Rt {
-		// Count backwards through history starting from Rt+1 positions back
-		Ct := 0
+		// Ct counts consecutive empty changes backwards starting Rt+1
+		// positions back, which is the run length recorded for that packet
+		// (the current packet is not yet in zeroRunHistory)
+		runIdx := (comp.zeroRunIndex + MaxHistory - (Rt + 1)) % MaxHistory
+		Ct := comp.zeroRunHistory[runIdx]
 
 		maxI := 15
 		if comp.t < maxI {
 			maxI = comp.t
 		}
-
-		for i := Rt + 1; i <= maxI; i++ {
-			histIdx := (comp.historyIndex + MaxHistory - i) % MaxHistory
-			if comp.changeHistory[histIdx].HammingWeight() > 0 {
-				break // Found a change, stop counting
-			}
-			Ct++
-			if Ct >= 15-Rt {
-				break // Cap at maximum Ct value
-			}
+		if Ct > maxI-Rt {
+			Ct = maxI - Rt
+		}
+		if Ct > 15-Rt {
+			Ct = 15 - Rt // Cap at maximum Ct value
 		}
 
 		Vt = Rt + Ct
@@ -469,7 +890,8 @@ func updateMaskInternal(mask, inputVec, prevInput, buildPrev, workChanges *BitVe
 		// Mt = changes OR Bt-1
 		mask.ORInto(workChanges, buildPrev)
 	} else {
-		// Mt = changes OR Mt-1 (in place)
+		// Mt = changes OR Mt-1 (in place). All compressor vectors are F
+		// bits long (inputs are length-checked), so words line up.
 		for w := 0; w < mask.numWords; w++ {
 			mask.data[w] = workChanges.data[w] | mask.data[w]
 		}
@@ -0,0 +1,67 @@
+package pocketplus
+
+import "hash/crc32"
+
+// CRCSink is a BitBuffer that keeps a running CRC-32 (IEEE) of its bytes,
+// so the checksum of compressed output is available as soon as it has been
+// written, without a second pass. A receiver can compare it before
+// attempting to decode.
+//
+// Bits are appended with the embedded BitBuffer methods, or a whole
+// compressed packet at a time with Compressor.CompressPacketTo(sink.BitBuffer,
+// ...). Completed bytes are hashed once, on the next Sum32 call. PatchBits
+// on the sink keeps the CRC valid; patching through the embedded buffer
+// directly (sink.BitBuffer.PatchBits) bypasses it and must not be used for
+// bytes already hashed.
+type CRCSink struct {
+	*BitBuffer
+	crc    uint32
+	hashed int // Number of leading bytes included in crc
+}
+
+// NewCRCSink creates a CRCSink writing to bb, which must be empty.
+func NewCRCSink(bb *BitBuffer) *CRCSink {
+	return &CRCSink{BitBuffer: bb}
+}
+
+// Sum32 returns the CRC-32 of the bytes ToBytes would return now,
+// including the final partial byte filled with PadBit.
+func (s *CRCSink) Sum32() uint32 {
+	full := s.numBits / 8
+	if full > s.hashed {
+		s.crc = crc32.Update(s.crc, crc32.IEEETable, s.data[s.hashed:full])
+		s.hashed = full
+	}
+
+	if s.numBits%8 == 0 {
+		return s.crc
+	}
+	// The partial byte is hashed but not committed, as more bits may follow
+	last := byte(s.acc << (8 - s.accLen))
+	if s.PadBit != 0 {
+		last |= 0xFF >> s.accLen
+	}
+	return crc32.Update(s.crc, crc32.IEEETable, []byte{last})
+}
+
+// PatchBits overwrites bits like BitBuffer.PatchBits. Patching a byte that
+// is already part of the CRC makes the next Sum32 rehash the buffer from
+// the start, since a CRC cannot be rewound; patch before calling Sum32 to
+// avoid that.
+func (s *CRCSink) PatchBits(handle int, value uint64, n int) error {
+	if err := s.BitBuffer.PatchBits(handle, value, n); err != nil {
+		return err
+	}
+	if n > 0 && handle/8 < s.hashed {
+		s.crc = 0
+		s.hashed = 0
+	}
+	return nil
+}
+
+// Clear resets the buffer and the CRC.
+func (s *CRCSink) Clear() {
+	s.BitBuffer.Clear()
+	s.crc = 0
+	s.hashed = 0
+}
@@ -0,0 +1,99 @@
+package pocketplus
+
+import (
+	"hash/crc32"
+	"testing"
+)
+
+func TestCRCSink(t *testing.T) {
+	sink := NewCRCSink(NewBitBuffer())
+	if sink.Sum32() != crc32.ChecksumIEEE(nil) {
+		t.Error("Empty sink CRC mismatch")
+	}
+
+	// Check after every append, across byte boundaries
+	for i := 0; i < 200; i++ {
+		sink.AppendValue(uint64(i*7919), i%13+1)
+		if got, want := sink.Sum32(), crc32.ChecksumIEEE(sink.ToBytes()); got != want {
+			t.Fatalf("Append %d (%d bits): CRC %08x, expected %08x", i, sink.NumBits(), got, want)
+		}
+	}
+
+	sink.Clear()
+	sink.AppendBit(1)
+	if got, want := sink.Sum32(), crc32.ChecksumIEEE([]byte{0x80}); got != want {
+		t.Errorf("After Clear: CRC %08x, expected %08x", got, want)
+	}
+}
+
+func TestCRCSinkPadBit(t *testing.T) {
+	sink := NewCRCSink(NewBitBuffer())
+	sink.PadBit = 1
+	sink.AppendValue(0x5, 3)
+	if got := sink.ToBytes(); len(got) != 1 || got[0] != 0xBF {
+		t.Fatalf("ToBytes = %x, expected bf", got)
+	}
+	if got, want := sink.Sum32(), crc32.ChecksumIEEE([]byte{0xBF}); got != want {
+		t.Errorf("CRC %08x, expected %08x", got, want)
+	}
+
+	// The partial byte follows PadBit across appends
+	for i := 0; i < 50; i++ {
+		sink.AppendValue(uint64(i*7919), i%13+1)
+		if got, want := sink.Sum32(), crc32.ChecksumIEEE(sink.ToBytes()); got != want {
+			t.Fatalf("Append %d (%d bits): CRC %08x, expected %08x", i, sink.NumBits(), got, want)
+		}
+	}
+}
+
+func TestCRCSinkCompressedStream(t *testing.T) {
+	packetSize := 90
+	data := generateTestPackets(50, packetSize)
+
+	// Write every compressed packet through the sink
+	comp, _ := newStreamCompressor(packetSize, 2, 10, 20, 50)
+	input, _ := NewBitVector(packetSize * 8)
+	sink := NewCRCSink(NewBitBuffer())
+	for i := 0; i < 50; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		if err := comp.CompressPacketTo(sink.BitBuffer, input, comp.scheduleParams(i)); err != nil {
+			t.Fatalf("Packet %d: %v", i, err)
+		}
+	}
+
+	expected, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	if got, want := sink.Sum32(), crc32.ChecksumIEEE(expected); got != want {
+		t.Errorf("Stream CRC %08x, expected %08x", got, want)
+	}
+}
+
+func TestCRCSinkPatchBits(t *testing.T) {
+	for padBit := 0; padBit <= 1; padBit++ {
+		sink := NewCRCSink(NewBitBuffer())
+		sink.PadBit = padBit
+		handle := sink.ReserveBits(16)
+		sink.AppendValue(0xAB, 8)
+		sink.Sum32()
+
+		// Patch bytes that are already hashed, then the partial byte
+		if err := sink.PatchBits(handle, 0x1234, 16); err != nil {
+			t.Fatalf("PatchBits failed: %v", err)
+		}
+		if got, want := sink.Sum32(), crc32.ChecksumIEEE(sink.ToBytes()); got != want {
+			t.Errorf("PadBit %d, after patching hashed bytes: CRC %08x, expected %08x", padBit, got, want)
+		}
+
+		tail := sink.ReserveBits(4)
+		sink.Sum32()
+		if err := sink.PatchBits(tail, 0x9, 4); err != nil {
+			t.Fatalf("PatchBits failed: %v", err)
+		}
+		if got, want := sink.Sum32(), crc32.ChecksumIEEE(sink.ToBytes()); got != want {
+			t.Errorf("PadBit %d, after patching the partial byte: CRC %08x, expected %08x", padBit, got, want)
+		}
+
+		if err := sink.PatchBits(100, 0, 8); err == nil {
+			t.Error("Expected error for patch outside the buffer")
+		}
+	}
+}
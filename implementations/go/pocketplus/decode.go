@@ -1,6 +1,7 @@
 package pocketplus
 
 import (
+	"errors"
 	"fmt"
 	"math/bits"
 )
@@ -15,6 +16,28 @@ import (
 //
 // Returns decoded positive integer (0 for terminator, 1+ for values).
 func CountDecode(br *BitReader) (int, error) {
+	// Fast path: with a full byte available, the common short codes
+	// ('0', '10' and '110' + BIT5) can be decoded from a single peek
+	if br.Remaining() >= 8 {
+		next := br.peekByte()
+
+		if next&0x80 == 0 {
+			// '0' -> 1
+			br.position++
+			return 1, nil
+		}
+		if next&0xC0 == 0x80 {
+			// '10' -> terminator (0)
+			br.position += 2
+			return 0, nil
+		}
+		if next&0xE0 == 0xC0 {
+			// '110' + BIT5 -> value + 2
+			br.position += 8
+			return int(next&0x1F) + 2, nil
+		}
+	}
+
 	// Read first bit
 	firstBit, err := br.ReadBit()
 	if err != nil {
@@ -100,25 +123,78 @@ func CountDecode(br *BitReader) (int, error) {
 	return int(value) + 2, nil
 }
 
+// CountDecodeExtended decodes a value written by CountEncodeExtended:
+// each COUNT(MaxCount) adds MaxCount-1 and continues with the next COUNT.
+// Like CountDecode it returns 0 for the RLE terminator, which must not
+// follow a continuation. Values above MaxVectorBits are rejected.
+func CountDecodeExtended(br *BitReader) (int, error) {
+	total := 0
+	for {
+		count, err := CountDecode(br)
+		if err != nil {
+			return 0, err
+		}
+		if count != MaxCount {
+			if count == 0 && total > 0 {
+				return 0, errors.New("COUNT decode: terminator after continuation")
+			}
+			return total + count, nil
+		}
+
+		total += MaxCount - 1
+		if total > MaxVectorBits {
+			return 0, fmt.Errorf("COUNT decode: chained value exceeds %d", MaxVectorBits)
+		}
+	}
+}
+
 // RLEDecode decodes an RLE-encoded bit vector.
 //
 // Decoding rules (inverse of CCSDS Equation 10):
 //   - Read COUNT values until terminator (0)
 //   - Each COUNT value represents position delta to next '1' bit
+//   - A run reaching before position 0 is an error (wrong length)
 func RLEDecode(br *BitReader, length int) (*BitVector, error) {
 	result, err := NewBitVector(length)
 	if err != nil {
 		return nil, fmt.Errorf("RLE decode: %w", err)
 	}
+	if err := RLEDecodeInto(br, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RLEDecodeInto is RLEDecode into a caller-provided vector, whose length
+// gives the decoded length. The vector is overwritten; on error its
+// contents are undefined.
+func RLEDecodeInto(br *BitReader, result *BitVector) error {
+	return rleDecodeInto(br, result, false)
+}
+
+// RLEDecodeExtended decodes a vector written by RLEEncodeExtended into
+// result, reading every run with CountDecodeExtended.
+func RLEDecodeExtended(br *BitReader, result *BitVector) error {
+	return rleDecodeInto(br, result, true)
+}
+
+// rleDecodeInto implements RLEDecodeInto and RLEDecodeExtended.
+func rleDecodeInto(br *BitReader, result *BitVector, extended bool) error {
+	result.Zero()
+
+	decodeCount := CountDecode
+	if extended {
+		decodeCount = CountDecodeExtended
+	}
 
 	// Start from end of vector
-	position := length
+	position := result.length
 
 	for {
 		// Decode next count
-		count, err := CountDecode(br)
+		count, err := decodeCount(br)
 		if err != nil {
-			return nil, fmt.Errorf("RLE decode: %w", err)
+			return fmt.Errorf("RLE decode: %w", err)
 		}
 
 		if count == 0 {
@@ -128,14 +204,15 @@ func RLEDecode(br *BitReader, length int) (*BitVector, error) {
 
 		// Move position back by count
 		position -= count
-
-		if position >= 0 {
-			// Set the bit at this position
-			result.SetBit(position, 1)
+		if position < 0 {
+			return fmt.Errorf("RLE decode: run of %d exceeds vector length %d", count, result.length)
 		}
+
+		// Set the bit at this position
+		result.SetBit(position, 1)
 	}
 
-	return result, nil
+	return nil
 }
 
 // BitInsert inserts bits into data at positions specified by mask (inverse of BE).
@@ -158,6 +235,11 @@ func BitInsert(br *BitReader, data, mask *BitVector) error {
 		if maskWord == 0 {
 			continue
 		}
+		if maskWord == 0xFFFFFFFF && br.Remaining() >= 32 {
+			// Dense mask: the next 32 bits fill the word in reverse order
+			data.data[w] = bits.Reverse32(br.readWord(32))
+			continue
+		}
 
 		// Process from low bit index to high (= high position to low)
 		for maskWord != 0 {
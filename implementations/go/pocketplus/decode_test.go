@@ -1,6 +1,7 @@
 package pocketplus
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -135,6 +136,33 @@ func TestRLEDecodeMultipleOnes(t *testing.T) {
 	}
 }
 
+func TestRLEDecodeInto(t *testing.T) {
+	bv, _ := NewBitVector(40)
+	bv.SetBit(3, 1)
+	bv.SetBit(33, 1)
+
+	bb := NewBitBuffer()
+	RLEEncode(bb, bv)
+
+	// The destination is overwritten, not ORed into
+	result, _ := NewBitVector(40)
+	result.SetAll()
+	br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	if err := RLEDecodeInto(br, result); err != nil {
+		t.Fatalf("RLEDecodeInto error: %v", err)
+	}
+	if !result.Equals(bv) {
+		t.Errorf("RLEDecodeInto: expected %s, got %s", bv, result)
+	}
+
+	// A destination shorter than the encoded runs is an error
+	short, _ := NewBitVector(20)
+	br = NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	if err := RLEDecodeInto(br, short); err == nil {
+		t.Error("Expected error for runs exceeding the vector length")
+	}
+}
+
 func TestRLEDecodeLargeVector(t *testing.T) {
 	// Test with 720-bit vector (standard packet size)
 	bv, _ := NewBitVector(720)
@@ -244,13 +272,13 @@ func TestBitExtractInsertRoundTrip(t *testing.T) {
 	}
 
 	// Compare only the masked positions
-	for i := 0; i < 8; i++ {
-		if mask.GetBit(i) != 0 {
-			if originalData.GetBit(i) != newData.GetBit(i) {
-				t.Errorf("Position %d: expected %d, got %d",
-					i, originalData.GetBit(i), newData.GetBit(i))
-			}
-		}
+	equal, err := originalData.EqualsWhere(newData, mask)
+	if err != nil {
+		t.Fatalf("EqualsWhere error: %v", err)
+	}
+	if !equal {
+		t.Errorf("Masked positions differ: expected %v, got %v",
+			originalData.ToBytes(), newData.ToBytes())
 	}
 }
 
@@ -432,3 +460,158 @@ func TestBitInsertInsufficientBits(t *testing.T) {
 		t.Error("Expected error for insufficient bits")
 	}
 }
+
+func TestCountDecodeFastPathUnaligned(t *testing.T) {
+	// Mix short and long codes so the fast path sees every bit offset
+	values := []int{1, 2, 33, 1, 34, 17, 1, 1, 1000, 5, 1, 65535, 3, 1, 2}
+
+	for offset := 0; offset < 8; offset++ {
+		bb := NewBitBuffer()
+		for i := 0; i < offset; i++ {
+			bb.AppendBit(1)
+		}
+		for _, v := range values {
+			CountEncode(bb, v)
+		}
+		CountEncodeTerminator(bb)
+
+		br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+		br.Skip(offset)
+
+		for _, expected := range values {
+			val, err := CountDecode(br)
+			if err != nil {
+				t.Fatalf("offset %d: CountDecode(%d) error: %v", offset, expected, err)
+			}
+			if val != expected {
+				t.Errorf("offset %d: expected %d, got %d", offset, expected, val)
+			}
+		}
+
+		val, err := CountDecode(br)
+		if err != nil || val != 0 {
+			t.Errorf("offset %d: expected terminator, got %d (err=%v)", offset, val, err)
+		}
+		if br.Remaining() != 0 {
+			t.Errorf("offset %d: expected all bits consumed, %d remaining", offset, br.Remaining())
+		}
+	}
+}
+
+func TestExtractInsertOrderingInvariant(t *testing.T) {
+	rng := rand.New(rand.NewSource(124))
+
+	for _, length := range []int{1, 7, 31, 32, 33, 64, 90, 720} {
+		masks := make([]*BitVector, 0, 8)
+		for _, fill := range []func(i int) int{
+			func(i int) int { return 1 },
+			func(i int) int { return i % 2 },
+			func(i int) int { return boolToBit(i == 0 || i == length-1) },
+			func(i int) int { return boolToBit(i%32 == 31) },
+			func(i int) int { return rng.Intn(2) },
+			func(i int) int { return boolToBit(rng.Intn(10) == 0) },
+			func(i int) int { return boolToBit(i < 64 || i%5 == 0) }, // Full and partial words
+		} {
+			mask, _ := NewBitVector(length)
+			for i := 0; i < length; i++ {
+				mask.SetBit(i, fill(i))
+			}
+			masks = append(masks, mask)
+		}
+
+		for m, mask := range masks {
+			data, _ := NewBitVector(length)
+			for i := 0; i < length; i++ {
+				data.SetBit(i, rng.Intn(2))
+			}
+
+			// Expected order: BE from highest position to lowest, forward
+			// from lowest to highest
+			var positions []int
+			for i := 0; i < length; i++ {
+				if mask.GetBit(i) == 1 {
+					positions = append(positions, i)
+				}
+			}
+
+			for _, forward := range []bool{false, true} {
+				bb := NewBitBuffer()
+				if forward {
+					BitExtractForward(bb, data, mask)
+				} else {
+					BitExtract(bb, data, mask)
+				}
+				if bb.NumBits() != len(positions) {
+					t.Fatalf("Length %d mask %d forward=%v: extracted %d bits, expected %d",
+						length, m, forward, bb.NumBits(), len(positions))
+				}
+
+				br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+				for k := range positions {
+					pos := positions[len(positions)-1-k]
+					if forward {
+						pos = positions[k]
+					}
+					bit, _ := br.ReadBit()
+					if bit != data.GetBit(pos) {
+						t.Fatalf("Length %d mask %d forward=%v: bit %d is not position %d",
+							length, m, forward, k, pos)
+					}
+				}
+
+				// Insert over the complement, so unmasked bits must survive
+				restored := data.NOT()
+				br = NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+				var err error
+				if forward {
+					err = BitInsertForward(br, restored, mask)
+				} else {
+					err = BitInsert(br, restored, mask)
+				}
+				if err != nil {
+					t.Fatalf("Length %d mask %d forward=%v: insert failed: %v", length, m, forward, err)
+				}
+				for i := 0; i < length; i++ {
+					want := data.GetBit(i)
+					if mask.GetBit(i) == 0 {
+						want ^= 1
+					}
+					if restored.GetBit(i) != want {
+						t.Fatalf("Length %d mask %d forward=%v: position %d is %d, expected %d",
+							length, m, forward, i, restored.GetBit(i), want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBitInsertFullWordShortData(t *testing.T) {
+	mask, _ := NewBitVector(64)
+	mask.SetAll()
+	data, _ := NewBitVector(64)
+
+	// 20 bits cannot fill a full mask word
+	br := NewBitReaderWithBits([]byte{0xFF, 0xFF, 0xF0}, 20)
+	if err := BitInsert(br, data, mask); err == nil {
+		t.Error("Expected error when the data ends inside a full mask word")
+	}
+}
+
+func TestRLEDecodeRunTooLong(t *testing.T) {
+	// RLE of a 16-bit vector with only bit 0 set: COUNT(16) || '10'
+	bb := NewBitBuffer()
+	input, _ := NewBitVector(16)
+	input.SetBit(0, 1)
+	RLEEncode(bb, input)
+
+	br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	if _, err := RLEDecode(br, 16); err != nil {
+		t.Errorf("RLEDecode at correct length failed: %v", err)
+	}
+
+	br = NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	if _, err := RLEDecode(br, 8); err == nil {
+		t.Error("Expected error for run beyond vector length")
+	}
+}
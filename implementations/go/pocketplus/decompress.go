@@ -3,6 +3,8 @@ package pocketplus
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
 )
 
 // Decompress decompresses POCKET+ compressed data.
@@ -17,33 +19,184 @@ func Decompress(data []byte, packetSize, robustness int) ([]byte, error) {
 	if len(data) == 0 {
 		return []byte{}, nil
 	}
-	if packetSize <= 0 {
-		return nil, errors.New("packet size must be positive")
-	}
-	if robustness < 1 || robustness > 7 {
-		return nil, errors.New("robustness must be between 1 and 7")
+
+	var output bytes.Buffer
+	if _, err := DecompressTo(&output, data, packetSize, robustness); err != nil {
+		return nil, err
 	}
 
-	// Convert packet size from bytes to bits
-	F := packetSize * 8
+	return output.Bytes(), nil
+}
+
+// ErrUnexpectedLength is returned by DecompressExact when the decompressed
+// output does not have the expected length.
+var ErrUnexpectedLength = errors.New("unexpected decompressed length")
 
-	// Create decompressor
-	decomp, err := NewDecompressor(F, nil, robustness)
+// DecompressExact decompresses like Decompress and additionally checks that
+// the output is exactly expectedBytes long. A mismatch, which typically
+// points to truncated input, a wrong robustness or padding problems, is
+// reported as an error wrapping ErrUnexpectedLength that includes the
+// actual length.
+func DecompressExact(data []byte, packetSize, robustness, expectedBytes int) ([]byte, error) {
+	output, err := Decompress(data, packetSize, robustness)
 	if err != nil {
 		return nil, err
 	}
+	if len(output) != expectedBytes {
+		return nil, fmt.Errorf("%w: got %d bytes, expected %d", ErrUnexpectedLength, len(output), expectedBytes)
+	}
+	return output, nil
+}
 
-	// Decompress stream
-	packets, err := decomp.DecompressStream(data, len(data)*8)
+// DecompressTo decompresses POCKET+ compressed data, writing each packet
+// to w as soon as it is decoded.
+//
+// Unlike Decompress, the decompressed stream is never held in memory as a
+// whole; a single packet buffer is reused across iterations. Packets
+// decoded before an error have already been written to w.
+//
+// Returns the number of bytes written to w.
+func DecompressTo(w io.Writer, data []byte, packetSize, robustness int) (int64, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	decomp, err := newStreamDecompressor(packetSize, robustness)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := NewBitReaderWithBits(data, len(data)*8)
+	packet := make([]byte, packetSize)
+	output, _ := NewBitVector(decomp.F)
+	var written int64
+
+	// Decompress packets until input exhausted
+	for reader.Remaining() > 0 {
+		if err := decomp.decompressPacketInto(reader, output, nil); err != nil {
+			return written, err
+		}
+
+		output.toBytesInto(packet)
+		n, err := w.Write(packet)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+
+		// Align to byte boundary for next packet
+		if err := decomp.alignPacket(reader); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// DecompressConcatenated decompresses independently compressed streams that
+// were concatenated, such as archives built by appending per-pass files.
+//
+// streamPacketCounts gives the number of packets in each stream in order;
+// the decompressor is reset at the start of every stream. Returns the
+// decompressed data of each stream. Data left over after the last stream
+// is reported as an error.
+func DecompressConcatenated(data []byte, packetSize, robustness int, streamPacketCounts []int) ([][]byte, error) {
+	decomp, err := newStreamDecompressor(packetSize, robustness)
 	if err != nil {
 		return nil, err
 	}
 
-	// Concatenate output
-	var output bytes.Buffer
-	for _, packet := range packets {
-		output.Write(packet)
+	reader := NewBitReader(data)
+	packet := make([]byte, packetSize)
+	out, _ := NewBitVector(decomp.F)
+	streams := make([][]byte, 0, len(streamPacketCounts))
+
+	for s, count := range streamPacketCounts {
+		decomp.Reset()
+
+		var output bytes.Buffer
+		for i := 0; i < count; i++ {
+			if err := decomp.decompressPacketInto(reader, out, nil); err != nil {
+				return streams, fmt.Errorf("stream %d packet %d: %w", s, i, err)
+			}
+			out.toBytesInto(packet)
+			output.Write(packet)
+
+			// Align to byte boundary for next packet
+			if err := decomp.alignPacket(reader); err != nil {
+				return streams, fmt.Errorf("stream %d packet %d: %w", s, i, err)
+			}
+		}
+		streams = append(streams, output.Bytes())
 	}
 
-	return output.Bytes(), nil
+	if reader.Remaining() > 0 {
+		return streams, fmt.Errorf("%d bytes left after %d streams",
+			reader.Remaining()/8, len(streamPacketCounts))
+	}
+
+	return streams, nil
+}
+
+// Validate checks the structure of POCKET+ compressed data without
+// reconstructing the decompressed output.
+//
+// Every packet is parsed and the mask is tracked exactly as in Decompress,
+// but unpredictable and uncompressed input bits are skipped rather than
+// inserted. This is cheaper than Decompress for integrity triage of large
+// captures.
+//
+// Returns the number of packets that parsed cleanly; on error this is the
+// number of packets before the failing one.
+func Validate(data []byte, packetSize, robustness int) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	decomp, err := newStreamDecompressor(packetSize, robustness)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := NewBitReaderWithBits(data, len(data)*8)
+	count := 0
+
+	for reader.Remaining() > 0 {
+		if _, err := decomp.parsePacket(reader, nil, nil); err != nil {
+			return count, fmt.Errorf("packet %d: %w", count, err)
+		}
+		decomp.t++
+		count++
+
+		// Align to byte boundary for next packet
+		reader.AlignByte()
+	}
+
+	return count, nil
+}
+
+// CountPackets returns the number of packets in POCKET+ compressed data.
+//
+// The packet structure is parsed to completion as in Validate, so this is
+// substantially cheaper than Decompress when only the count is needed.
+// Any parse error is returned and the count is discarded.
+func CountPackets(data []byte, packetSize, robustness int) (int, error) {
+	count, err := Validate(data, packetSize, robustness)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// newStreamDecompressor validates top-level stream parameters and creates
+// a decompressor for them.
+func newStreamDecompressor(packetSize, robustness int) (*Decompressor, error) {
+	if err := validatePacketSize(packetSize); err != nil {
+		return nil, err
+	}
+	if robustness < 1 || robustness > 7 {
+		return nil, errors.New("robustness must be between 1 and 7")
+	}
+
+	// Convert packet size from bytes to bits
+	return NewDecompressor(packetSize*8, nil, robustness)
 }
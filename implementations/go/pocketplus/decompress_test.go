@@ -0,0 +1,331 @@
+package pocketplus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	packetSize := 8
+	numPackets := 60
+	data := generateTestPackets(numPackets, packetSize)
+
+	compressed, err := Compress(data, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	count, err := Validate(compressed, packetSize, 2)
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if count != numPackets {
+		t.Errorf("Expected %d packets, got %d", numPackets, count)
+	}
+}
+
+func TestValidateTruncated(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(20, packetSize)
+
+	compressed, err := Compress(data, packetSize, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	// The first packet is uncompressed (> F bits), so cutting into it fails
+	count, err := Validate(compressed[:4], packetSize, 1)
+	if err == nil {
+		t.Error("Expected error for truncated stream")
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 clean packets, got %d", count)
+	}
+}
+
+func TestValidateEmpty(t *testing.T) {
+	count, err := Validate([]byte{}, 8, 1)
+	if err != nil || count != 0 {
+		t.Errorf("Expected (0, nil) for empty input, got (%d, %v)", count, err)
+	}
+}
+
+func TestValidateInvalidParams(t *testing.T) {
+	if _, err := Validate([]byte{1}, 0, 1); err == nil {
+		t.Error("Expected error for zero packet size")
+	}
+	if _, err := Validate([]byte{1}, 8, 8); err == nil {
+		t.Error("Expected error for robustness > 7")
+	}
+}
+
+func TestCountPackets(t *testing.T) {
+	packetSize := 16
+	numPackets := 45
+	data := generateTestPackets(numPackets, packetSize)
+
+	compressed, err := Compress(data, packetSize, 3, 5, 10, 20)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	count, err := CountPackets(compressed, packetSize, 3)
+	if err != nil {
+		t.Fatalf("CountPackets failed: %v", err)
+	}
+	if count != numPackets {
+		t.Errorf("Expected %d packets, got %d", numPackets, count)
+	}
+
+	if _, err := CountPackets(compressed[:len(compressed)/2], packetSize, 3); err == nil {
+		t.Error("Expected error for truncated stream")
+	}
+}
+
+func TestDecompressPacketTraced(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	numPackets := 25
+	data := generateTestPackets(numPackets, packetSize)
+
+	comp, _ := NewCompressor(F, nil, 2, 10, 20, 50)
+	input, _ := NewBitVector(F)
+	var compressed []byte
+	var packetBits []int
+	for i := 0; i < numPackets; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		out, err := comp.CompressPacket(input, comp.scheduleParams(i))
+		if err != nil {
+			t.Fatalf("CompressPacket failed: %v", err)
+		}
+		compressed = append(compressed, out...)
+		packetBits = append(packetBits, comp.workOutput.NumBits())
+	}
+
+	decomp, _ := NewDecompressor(F, nil, 2)
+	reader := NewBitReader(compressed)
+	for i := 0; i < numPackets; i++ {
+		output, trace, err := decomp.DecompressPacketTraced(reader)
+		if err != nil {
+			t.Fatalf("Packet %d: DecompressPacketTraced failed: %v", i, err)
+		}
+		if !bytes.Equal(output.ToBytes(), data[i*packetSize:(i+1)*packetSize]) {
+			t.Errorf("Packet %d: output mismatch", i)
+		}
+		if trace.Length != packetBits[i] {
+			t.Errorf("Packet %d: trace length %d, compressor wrote %d bits", i, trace.Length, packetBits[i])
+		}
+
+		// Components tile the packet exactly
+		offset := 0
+		for _, c := range trace.Components {
+			if c.Offset != offset {
+				t.Errorf("Packet %d: %s at offset %d, expected %d", i, c.Name, c.Offset, offset)
+			}
+			offset += c.Length
+		}
+		if offset != trace.Length {
+			t.Errorf("Packet %d: components cover %d of %d bits", i, offset, trace.Length)
+		}
+
+		reader.AlignByte()
+	}
+}
+
+func TestDecompressPacketTracedUncompressed(t *testing.T) {
+	// The first packet is uncompressed with a mask resend
+	data := generateTestPackets(1, 8)
+	compressed, _ := Compress(data, 8, 1, 10, 20, 50)
+
+	decomp, _ := NewDecompressor(64, nil, 1)
+	_, trace, err := decomp.DecompressPacketTraced(NewBitReader(compressed))
+	if err != nil {
+		t.Fatalf("DecompressPacketTraced failed: %v", err)
+	}
+
+	var names []string
+	for _, c := range trace.Components {
+		names = append(names, c.Name)
+	}
+	last := trace.Components[len(trace.Components)-1]
+	if names[len(names)-1] != "It" || last.Length != 64 {
+		t.Errorf("Expected trailing 64-bit It component, got %v", trace.Components)
+	}
+	if names[len(names)-2] != "COUNT(F)" {
+		t.Errorf("Expected COUNT(F) before It, got %v", names)
+	}
+}
+
+func TestDecompressConcatenated(t *testing.T) {
+	packetSize := 8
+	first := generateTestPackets(12, packetSize)
+	second := generateTestPackets(30, packetSize)[8*packetSize:] // Different content
+
+	a, _ := Compress(first, packetSize, 2, 10, 20, 50)
+	b, _ := Compress(second, packetSize, 2, 10, 20, 50)
+	archive := append(append([]byte{}, a...), b...)
+
+	streams, err := DecompressConcatenated(archive, packetSize, 2, []int{12, 22})
+	if err != nil {
+		t.Fatalf("DecompressConcatenated failed: %v", err)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("Expected 2 streams, got %d", len(streams))
+	}
+	if !bytes.Equal(streams[0], first) || !bytes.Equal(streams[1], second) {
+		t.Error("Concatenated stream round-trip mismatch")
+	}
+}
+
+func TestDecompressConcatenatedCountMismatch(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(10, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	if _, err := DecompressConcatenated(compressed, packetSize, 1, []int{6}); err == nil {
+		t.Error("Expected error for leftover data")
+	}
+	if _, err := DecompressConcatenated(compressed, packetSize, 1, []int{6, 6}); err == nil {
+		t.Error("Expected error for too many packets")
+	}
+}
+
+func TestDecodeErrorBitPosition(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(10, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	// Locate the Vt and BE fields of packet 5 using a traced decode
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	reader := NewBitReader(compressed)
+	var vtPos, bePos, beLen int
+	for i := 0; i <= 5; i++ {
+		start := reader.Position()
+		_, trace, err := decomp.DecompressPacketTraced(reader)
+		if err != nil {
+			t.Fatalf("Packet %d: decode failed: %v", i, err)
+		}
+		for _, c := range trace.Components {
+			switch c.Name {
+			case "Vt":
+				vtPos = start + c.Offset
+			case "BE":
+				bePos, beLen = start+c.Offset, c.Length
+			}
+		}
+		reader.AlignByte()
+	}
+	if beLen < 2 {
+		t.Fatalf("Packet 5 BE is %d bits, need at least 2", beLen)
+	}
+
+	// decodeAt decodes packets 0-5 from the first truncated bits
+	decodeAt := func(truncated int) error {
+		decomp.Reset()
+		reader := NewBitReaderWithBits(compressed, truncated)
+		var err error
+		for i := 0; i <= 5 && err == nil; i++ {
+			_, err = decomp.DecompressPacket(reader)
+			reader.AlignByte()
+		}
+		return err
+	}
+
+	tests := []struct {
+		name      string
+		truncated int
+		want      string
+	}{
+		// Vt is read in one go, so the read fails where it starts
+		{"Vt", vtPos + 2, fmt.Sprintf("failed to read Vt starting at bit %d, failed at bit %d", vtPos, vtPos)},
+		// BE is read bit by bit and fails where the data ends
+		{"BE", bePos + 1, fmt.Sprintf("failed to insert bits starting at bit %d, failed at bit %d", bePos, bePos+1)},
+	}
+
+	for _, tt := range tests {
+		err := decodeAt(tt.truncated)
+		if err == nil {
+			t.Errorf("%s: expected error for truncated stream", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %q", tt.name, tt.want, err.Error())
+		}
+	}
+}
+
+func TestDecompressWrongPacketSize(t *testing.T) {
+	data := generateTestPackets(20, 90)
+	compressed, _ := Compress(data, 90, 1, 10, 20, 50)
+
+	for _, size := range []int{8, 45, 89, 91, 180} {
+		_, err := Decompress(compressed, size, 1)
+		if !errors.Is(err, ErrParameterMismatch) {
+			t.Errorf("Packet size %d: expected ErrParameterMismatch, got %v", size, err)
+		}
+		if _, err := Validate(compressed, size, 1); !errors.Is(err, ErrParameterMismatch) {
+			t.Errorf("Packet size %d: Validate expected ErrParameterMismatch, got %v", size, err)
+		}
+	}
+
+	if _, err := Decompress(compressed, 90, 1); err != nil {
+		t.Errorf("Correct packet size failed: %v", err)
+	}
+}
+
+func TestDecompressStreamUnalignedNumBits(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(5, packetSize)
+
+	// Compress, noting the exact bit length of the final packet
+	comp, _ := newStreamCompressor(packetSize, 1, 10, 20, 50)
+	input, _ := NewBitVector(packetSize * 8)
+	var compressed []byte
+	numBits := 0
+	for i := 0; i < 5; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		out, _ := comp.CompressPacket(input, comp.scheduleParams(i))
+		numBits = len(compressed)*8 + comp.workOutput.NumBits()
+		compressed = append(compressed, out...)
+	}
+	if numBits%8 == 0 {
+		t.Fatal("Test needs a final packet that is not byte-aligned")
+	}
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	packets, err := decomp.DecompressStream(compressed, numBits)
+	if err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if len(packets) != 5 {
+		t.Fatalf("Expected 5 packets, got %d", len(packets))
+	}
+	if !bytes.Equal(bytes.Join(packets, nil), data) {
+		t.Error("Decompressed data mismatch")
+	}
+}
+
+func TestDecompressExact(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(10, packetSize)
+	compressed, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	output, err := DecompressExact(compressed, packetSize, 1, len(data))
+	if err != nil {
+		t.Fatalf("DecompressExact failed: %v", err)
+	}
+	if !bytes.Equal(output, data) {
+		t.Error("DecompressExact round-trip mismatch")
+	}
+
+	_, err = DecompressExact(compressed, packetSize, 1, len(data)+packetSize)
+	if !errors.Is(err, ErrUnexpectedLength) {
+		t.Fatalf("Expected ErrUnexpectedLength, got %v", err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("got %d bytes", len(data))) {
+		t.Errorf("Error should report the actual length: %v", err)
+	}
+}
@@ -5,6 +5,16 @@ import (
 	"fmt"
 )
 
+// ErrParameterMismatch is returned when compressed data does not match the
+// decompressor's packet length, typically because the wrong packet size was
+// given.
+var ErrParameterMismatch = errors.New("compressed data does not match decompressor parameters")
+
+// ErrTrailingData is returned in strict trailing mode (see
+// Decompressor.SetStrictTrailing) when the bits after a packet are not
+// zero byte-alignment padding.
+var ErrTrailingData = errors.New("trailing data after last packet")
+
 // Decompressor maintains state for POCKET+ decompression.
 type Decompressor struct {
 	// Configuration (immutable after init)
@@ -17,8 +27,36 @@ type Decompressor struct {
 	prevOutput  *BitVector
 	Xt          *BitVector // Positive changes tracker
 
+	// Working buffers for the BE extraction mask and the decoded RLE(Xt)
+	workExtractMask *BitVector
+	workXt          *BitVector
+
 	// Cycle counter
 	t int
+
+	// Non-standard raw mask extension (see CompressParams.AllowRawMask)
+	allowRawMask bool
+
+	// Non-standard mask check extension (see Compressor.SetMaskCheck)
+	maskCheck bool
+
+	// Reject non-zero padding and data after the last packet
+	strictTrailing bool
+
+	// Non-standard chained COUNT extension (see Compressor.SetExtendedCount)
+	extendedCount bool
+
+	// Reject packets that change the mask (see SetFixedMask)
+	fixedMask bool
+
+	// Require DecompressStreamN to consume all but the padding
+	checkExhausted bool
+
+	// Compressed bits read since the last Reset (see BitsConsumed)
+	bitsConsumed int
+
+	// Optional per-packet metrics (nil = disabled)
+	metrics MetricsSink
 }
 
 // NewDecompressor creates a new decompressor.
@@ -26,6 +64,9 @@ func NewDecompressor(F int, initialMask *BitVector, robustness int) (*Decompress
 	if F <= 0 {
 		return nil, errors.New("F must be positive")
 	}
+	if F > MaxVectorBits {
+		return nil, fmt.Errorf("F must not exceed %d bits", MaxVectorBits)
+	}
 	if robustness < 0 || robustness > MaxRobustness {
 		return nil, fmt.Errorf("robustness must be between 0 and %d", MaxRobustness)
 	}
@@ -44,6 +85,8 @@ func NewDecompressor(F int, initialMask *BitVector, robustness int) (*Decompress
 	decomp.initialMask, _ = NewBitVector(F)
 	decomp.prevOutput, _ = NewBitVector(F)
 	decomp.Xt, _ = NewBitVector(F)
+	decomp.workExtractMask, _ = NewBitVector(F)
+	decomp.workXt, _ = NewBitVector(F)
 
 	// Set initial mask if provided
 	if initialMask != nil {
@@ -63,39 +106,252 @@ func (decomp *Decompressor) Reset() {
 	decomp.mask.CopyFrom(decomp.initialMask)
 	decomp.prevOutput.Zero()
 	decomp.Xt.Zero()
+	decomp.bitsConsumed = 0
+}
+
+// SetAllowRawMask enables decoding of the non-standard raw mask extension
+// produced with CompressParams.AllowRawMask. It must match the setting used
+// by the compressor; streams with the extension are not CCSDS compliant.
+func (decomp *Decompressor) SetAllowRawMask(enabled bool) {
+	decomp.allowRawMask = enabled
+}
+
+// SetStrictTrailing makes the stream decoders (DecompressStream,
+// DecompressStreamN, NewPacketIterator, Packets and StreamPackets) check
+// that the bits skipped to align each packet to a byte boundary are zero,
+// and DecompressStreamN additionally that no data follows the last
+// expected packet. A violation returns ErrTrailingData (from
+// PacketIterator.Err for the iterator), which catches files with garbage
+// or another stream appended. The packet before the bad padding is still
+// returned; Packets and StreamPackets, which cannot report errors, stop
+// after it. The default is lenient, matching earlier releases.
+func (decomp *Decompressor) SetStrictTrailing(enabled bool) {
+	decomp.strictTrailing = enabled
+}
+
+// alignPacket skips to the byte boundary after a packet, checking in
+// strict trailing mode that the skipped padding bits are zero.
+func (decomp *Decompressor) alignPacket(reader *BitReader) error {
+	start := reader.Position()
+	if decomp.strictTrailing {
+		n := min((8-start%8)%8, reader.Remaining())
+		if padding, err := reader.ReadBits(n); err != nil || padding != 0 {
+			return fmt.Errorf("%w: non-zero padding at bit %d", ErrTrailingData, start)
+		}
+	}
+	reader.AlignByte()
+	decomp.bitsConsumed += reader.Position() - start
+	return nil
+}
+
+// SetCheckExhausted makes DecompressStreamN verify that fewer than 8 bits
+// (i.e. at most the final byte's padding) remain after the last expected
+// packet. More data left over usually means the packet size or count was
+// wrong and the packets decoded so far are garbage, so this returns an
+// error wrapping ErrParameterMismatch. Unlike SetStrictTrailing, it
+// tolerates non-zero padding. DecompressStream decodes until the data is
+// exhausted, so it needs no such check.
+func (decomp *Decompressor) SetCheckExhausted(enabled bool) {
+	decomp.checkExhausted = enabled
+}
+
+// BitsConsumed returns the number of compressed bits read since the last
+// Reset: the packets decoded, plus the alignment padding skipped between
+// them by the stream decoders (DecompressStream, DecompressStreamN,
+// NewPacketIterator, Packets and StreamPackets). After decoding a whole
+// buffer with one of them it equals the buffer length; DecompressPacket
+// and its variants leave alignment to the caller and count packet bits
+// only.
+func (decomp *Decompressor) BitsConsumed() int {
+	return decomp.bitsConsumed
 }
 
 // DecompressPacket decompresses a single compressed packet.
 func (decomp *Decompressor) DecompressPacket(reader *BitReader) (*BitVector, error) {
+	return decomp.decompressPacket(reader, nil)
+}
+
+// DecompressPacketAgainst decompresses a packet produced by
+// CompressPacketAgainst, predicting from reference instead of the previous
+// output for this packet only.
+func (decomp *Decompressor) DecompressPacketAgainst(reader *BitReader, reference *BitVector) (*BitVector, error) {
+	if reader == nil {
+		return nil, errors.New("reader must not be nil")
+	}
+	if reference == nil || reference.length != decomp.F {
+		return nil, errors.New("reference must be non-nil and match F length")
+	}
+
+	decomp.prevOutput.CopyFrom(reference)
+	return decomp.decompressPacket(reader, nil)
+}
+
+// TraceComponent locates one parsed component of a compressed packet.
+type TraceComponent struct {
+	Name   string // Component name, e.g. "RLE(Xt)", "Vt", "kt", "BE"
+	Offset int    // Bit offset relative to the packet start
+	Length int    // Length in bits
+}
+
+// PacketTrace records where each component of a decoded packet lies.
+type PacketTrace struct {
+	Components []TraceComponent // In stream order
+	Length     int              // Total packet length in bits (before alignment)
+}
+
+// DecompressPacketTraced decompresses a single packet like DecompressPacket
+// and also returns the bit offset and length of every parsed component.
+// Offsets are relative to the reader's position at entry, so tools can map
+// a whole file by calling this in a loop.
+func (decomp *Decompressor) DecompressPacketTraced(reader *BitReader) (*BitVector, *PacketTrace, error) {
+	trace := &PacketTrace{}
+	output, err := decomp.decompressPacket(reader, trace)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, trace, nil
+}
+
+// DecompressPacketInto decompresses a single packet like DecompressPacket
+// into output instead of a new vector, so a caller that consumes each
+// packet before decoding the next can reuse one vector for the whole
+// stream. output must be F bits long and is overwritten; on error its
+// contents are undefined.
+func (decomp *Decompressor) DecompressPacketInto(reader *BitReader, output *BitVector) error {
+	if reader == nil {
+		return errors.New("reader must not be nil")
+	}
+	if output == nil || output.length != decomp.F {
+		return errors.New("output must be non-nil and match F length")
+	}
+	return decomp.decompressPacketInto(reader, output, nil)
+}
+
+// decompressPacket implements DecompressPacket, recording component
+// locations in trace if it is non-nil.
+func (decomp *Decompressor) decompressPacket(reader *BitReader, trace *PacketTrace) (*BitVector, error) {
 	if reader == nil {
 		return nil, errors.New("reader must not be nil")
 	}
 
 	output, _ := NewBitVector(decomp.F)
+	if err := decomp.decompressPacketInto(reader, output, trace); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// decompressPacketInto implements decompressPacket, decoding into a
+// caller-provided vector of F bits.
+func (decomp *Decompressor) decompressPacketInto(reader *BitReader, output *BitVector, trace *PacketTrace) error {
+	startPos := reader.Position()
 
 	// Copy previous output as prediction base
 	output.CopyFrom(decomp.prevOutput)
 
+	rt, err := decomp.parsePacket(reader, output, trace)
+	if err != nil {
+		return err
+	}
+	if trace != nil {
+		trace.Length = reader.Position() - startPos
+	}
+
+	// ====================================================================
+	// Update state for next cycle
+	// ====================================================================
+
+	decomp.prevOutput.CopyFrom(output)
+	decomp.t++
+	decomp.bitsConsumed += reader.Position() - startPos
+
+	if decomp.metrics != nil {
+		decomp.metrics.ObservePacket(decomp.F, reader.Position()-startPos, rt == 1)
+	}
+
+	return nil
+}
+
+// parsePacket parses one compressed packet and applies its mask updates.
+//
+// Unpredictable (or full) input bits are inserted into output; when output
+// is nil they are skipped instead, which validates the packet structure
+// without reconstructing data. Component locations are recorded in trace
+// if it is non-nil. Returns the packet's rt flag.
+//
+// A first packet that fails to parse almost always means the stream was
+// compressed with a different packet size, so its errors are reported as
+// ErrParameterMismatch.
+func (decomp *Decompressor) parsePacket(reader *BitReader, output *BitVector, trace *PacketTrace) (int, error) {
+	base := reader.Position()
+	rt, err := decomp.parseComponents(reader, output, trace)
+	if err != nil && decomp.t == 0 && !errors.Is(err, ErrParameterMismatch) {
+		return 0, fmt.Errorf("%w: first packet does not decode with F=%d: %w",
+			ErrParameterMismatch, decomp.F, err)
+	}
+	if err == nil && decomp.fixedMask {
+		if err := decomp.checkFixedMask(); err != nil {
+			return 0, err
+		}
+	}
+	if err == nil && decomp.maskCheck {
+		start := reader.Position()
+		if err := decomp.decodeMaskCheck(reader); err != nil {
+			return 0, err
+		}
+		if trace != nil {
+			trace.Components = append(trace.Components, TraceComponent{
+				Name:   "mask check",
+				Offset: start - base,
+				Length: reader.Position() - start,
+			})
+		}
+	}
+	return rt, err
+}
+
+// parseComponents implements parsePacket.
+func (decomp *Decompressor) parseComponents(reader *BitReader, output *BitVector, trace *PacketTrace) (int, error) {
 	// Clear positive changes tracker
 	decomp.Xt.Zero()
 
+	base := reader.Position()
+	start := base
+	mark := func(name string) {
+		if trace != nil {
+			trace.Components = append(trace.Components, TraceComponent{
+				Name:   name,
+				Offset: start - base,
+				Length: reader.Position() - start,
+			})
+		}
+		start = reader.Position()
+	}
+	// fail reports the component's first bit and where the read failed
+	fail := func(what string, err error) error {
+		return fmt.Errorf("failed to %s starting at bit %d, failed at bit %d: %w",
+			what, start, reader.Position(), err)
+	}
+
 	// ====================================================================
 	// Parse ht: Mask change information
 	// ht = RLE(Xt) || BIT4(Vt) || et || kt || ct || dt
 	// ====================================================================
 
-	// Decode RLE(Xt) - mask changes
-	Xt, err := RLEDecode(reader, decomp.F)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode RLE(Xt): %w", err)
+	// Decode RLE(Xt) - mask changes - reuse workXt
+	Xt := decomp.workXt
+	if err := rleDecodeInto(reader, Xt, decomp.extendedCount); err != nil {
+		return 0, fail("decode RLE(Xt)", err)
 	}
+	mark("RLE(Xt)")
 
 	// Read BIT4(Vt) - effective robustness
 	vtRaw, err := reader.ReadBits(4)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read Vt: %w", err)
+		return 0, fail("read Vt", err)
 	}
 	Vt := int(vtRaw & 0x0F)
+	mark("Vt")
 
 	// Process et, kt, ct if Vt > 0 and there are changes
 	ct := 0
@@ -105,47 +361,39 @@ func (decomp *Decompressor) DecompressPacket(reader *BitReader) (*BitVector, err
 		// Read et
 		et, err := reader.ReadBit()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read et: %w", err)
+			return 0, fail("read et", err)
 		}
+		mark("et")
 
 		if et == 1 {
 			// Read kt - determines positive/negative updates
-			// kt has one bit per change in Xt
-			ktBits := make([]int, 0, changeCount)
-
-			// Read kt bits (forward order)
+			// kt has one bit per change in Xt, read in forward order and
+			// applied as it is read
 			for i := 0; i < decomp.F; i++ {
 				if Xt.GetBit(i) != 0 {
 					bit, err := reader.ReadBit()
 					if err != nil {
-						return nil, fmt.Errorf("failed to read kt bit: %w", err)
+						return 0, fail("read kt", err)
 					}
-					ktBits = append(ktBits, bit)
-				}
-			}
-
-			// Apply mask updates based on kt
-			ktIdx := 0
-			for i := 0; i < decomp.F; i++ {
-				if Xt.GetBit(i) != 0 {
 					// kt=1 means positive update (mask becomes 0)
 					// kt=0 means negative update (mask becomes 1)
-					if ktBits[ktIdx] != 0 {
+					if bit != 0 {
 						decomp.mask.SetBit(i, 0)
 						decomp.Xt.SetBit(i, 1) // Track positive change
 					} else {
 						decomp.mask.SetBit(i, 1)
 					}
-					ktIdx++
 				}
 			}
+			mark("kt")
 
 			// Read ct
 			ctBit, err := reader.ReadBit()
 			if err != nil {
-				return nil, fmt.Errorf("failed to read ct: %w", err)
+				return 0, fail("read ct", err)
 			}
 			ct = ctBit
+			mark("ct")
 		} else {
 			// et = 0: all updates are negative (mask bits become 1)
 			for i := 0; i < decomp.F; i++ {
@@ -172,8 +420,9 @@ func (decomp *Decompressor) DecompressPacket(reader *BitReader) (*BitVector, err
 	// Read dt
 	dt, err := reader.ReadBit()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read dt: %w", err)
+		return 0, fail("read dt", err)
 	}
+	mark("dt")
 
 	// ====================================================================
 	// Parse qt: Optional full mask
@@ -187,42 +436,44 @@ func (decomp *Decompressor) DecompressPacket(reader *BitReader) (*BitVector, err
 		// Read ft flag
 		ft, err := reader.ReadBit()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read ft: %w", err)
+			return 0, fail("read ft", err)
 		}
+		mark("ft")
 
-		if ft == 1 {
-			// Full mask follows: decode RLE(M XOR (M<<))
-			maskDiff, err := RLEDecode(reader, decomp.F)
+		rawMask := 0
+		if ft == 1 && decomp.allowRawMask {
+			rawMask, err = reader.ReadBit()
 			if err != nil {
-				return nil, fmt.Errorf("failed to decode mask: %w", err)
+				return 0, fail("read raw mask flag", err)
 			}
+			mark("raw mask flag")
+		}
 
-			// Reverse the horizontal XOR to get the actual mask.
-			// HXOR encoding: HXOR[i] = M[i] XOR M[i+1], with HXOR[F-1] = M[F-1]
-			// Reversal: start from LSB (position F-1) and work towards MSB (position 0)
-			// M[F-1] = HXOR[F-1] (just copy)
-			// M[i] = HXOR[i] XOR M[i+1] for i < F-1
-
-			// Copy LSB bit directly (position F-1 in bitvector)
-			current := maskDiff.GetBit(decomp.F - 1)
-			decomp.mask.SetBit(decomp.F-1, current)
-
-			// Process remaining bits from F-2 down to 0
-			for i := decomp.F - 1; i > 0; i-- {
-				pos := i - 1
-				hxorBit := maskDiff.GetBit(pos)
-				// M[pos] = HXOR[pos] XOR M[pos+1] = HXOR[pos] XOR current
-				current = hxorBit ^ current
-				decomp.mask.SetBit(pos, current)
+		if rawMask == 1 {
+			// Non-standard extension: F raw mask bits follow
+			for i := 0; i < decomp.F; i++ {
+				bit, err := reader.ReadBit()
+				if err != nil {
+					return 0, fail("read raw mask", err)
+				}
+				decomp.mask.SetBit(i, bit)
+			}
+			mark("mask")
+		} else if ft == 1 {
+			// Full mask follows: decode RLE(M XOR (M<<)) in place
+			if err := decodeMaskDiffInto(reader, decomp.mask, decomp.extendedCount); err != nil {
+				return 0, fail("decode mask", err)
 			}
+			mark("RLE(mask)")
 		}
 
 		// Read rt flag
 		rtBit, err := reader.ReadBit()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read rt: %w", err)
+			return 0, fail("read rt", err)
 		}
 		rt = rtBit
+		mark("rt")
 	}
 
 	// ====================================================================
@@ -231,49 +482,70 @@ func (decomp *Decompressor) DecompressPacket(reader *BitReader) (*BitVector, err
 
 	if rt == 1 {
 		// Full packet follows: COUNT(F) || It
-		_, err := CountDecode(reader)
+		count, err := decomp.countDecode(reader)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode packet length: %w", err)
+			return 0, fail("decode packet length", err)
+		}
+		if count != decomp.F {
+			return 0, fmt.Errorf("%w: packet at bit %d declares F=%d, expected %d",
+				ErrParameterMismatch, start, count, decomp.F)
 		}
+		mark("COUNT(F)")
 
-		// Read full packet
-		for i := 0; i < decomp.F; i++ {
-			bit, err := reader.ReadBit()
-			if err != nil {
-				return nil, fmt.Errorf("failed to read input bit %d: %w", i, err)
+		if output == nil {
+			if err := reader.Skip(decomp.F); err != nil {
+				return 0, fail("skip input bits", err)
 			}
-			output.SetBit(i, bit)
+			mark("It")
+			return rt, nil
+		}
+
+		// Read full packet
+		if err := reader.ReadBitsIntoVector(output); err != nil {
+			return 0, fail("read input bits", err)
 		}
+		mark("It")
 	} else {
-		// Compressed: extract unpredictable bits
-		var extractionMask *BitVector
+		// Compressed: extract unpredictable bits - reuse workExtractMask
+		extractionMask := decomp.workExtractMask
 
 		if ct == 1 && Vt > 0 {
 			// BE(It, (Xt OR Mt))
-			extractionMask = decomp.mask.OR(decomp.Xt)
+			extractionMask.ORInto(decomp.mask, decomp.Xt)
 		} else {
 			// BE(It, Mt)
-			extractionMask = decomp.mask.Copy()
+			extractionMask.CopyFrom(decomp.mask)
+		}
+
+		if output == nil {
+			if err := reader.Skip(extractionMask.HammingWeight()); err != nil {
+				return 0, fail("skip bits", err)
+			}
+			mark("BE")
+			return rt, nil
 		}
 
 		// Insert unpredictable bits
 		err := BitInsert(reader, output, extractionMask)
 		if err != nil {
-			return nil, fmt.Errorf("failed to insert bits: %w", err)
+			return 0, fail("insert bits", err)
 		}
+		mark("BE")
 	}
 
-	// ====================================================================
-	// Update state for next cycle
-	// ====================================================================
-
-	decomp.prevOutput.CopyFrom(output)
-	decomp.t++
-
-	return output, nil
+	return rt, nil
 }
 
 // DecompressStream decompresses multiple packets from a byte stream.
+//
+// Packets are decoded until fewer than one bit remains, so this is best
+// effort for padded input: trailing padding longer than the final byte
+// alignment is parsed as another packet. Use DecompressStreamN when the
+// packet count is known.
+//
+// Every packet decodes to exactly F bits or fails: a final packet cut short
+// by the end of the data returns an error together with the packets before
+// it, and is never zero-padded.
 func (decomp *Decompressor) DecompressStream(data []byte, numBits int) ([][]byte, error) {
 	if len(data) == 0 {
 		return nil, errors.New("input data is empty")
@@ -287,27 +559,71 @@ func (decomp *Decompressor) DecompressStream(data []byte, numBits int) ([][]byte
 
 	// Output packet size in bytes
 	packetBytes := (decomp.F + 7) / 8
+	output, _ := NewBitVector(decomp.F)
 	var outputs [][]byte
 
 	// Decompress packets until input exhausted
 	for reader.Remaining() > 0 {
-		output, err := decomp.DecompressPacket(reader)
-		if err != nil {
+		if err := decomp.decompressPacketInto(reader, output, nil); err != nil {
 			return outputs, err
 		}
 
-		// Convert to bytes
-		outputBytes := output.ToBytes()
-		if len(outputBytes) < packetBytes {
-			// Pad if necessary
-			padded := make([]byte, packetBytes)
-			copy(padded, outputBytes)
-			outputBytes = padded
+		outputBytes := make([]byte, packetBytes)
+		output.toBytesInto(outputBytes)
+		outputs = append(outputs, outputBytes)
+
+		// Align to byte boundary for next packet
+		if err := decomp.alignPacket(reader); err != nil {
+			return outputs, err
+		}
+	}
+
+	return outputs, nil
+}
+
+// DecompressStreamN decompresses exactly packetCount packets from a byte
+// stream and ignores any bits that follow them, unless SetStrictTrailing is
+// enabled. An error is returned if the data runs out before packetCount
+// packets have been decoded.
+func (decomp *Decompressor) DecompressStreamN(data []byte, numBits, packetCount int) ([][]byte, error) {
+	if packetCount < 0 {
+		return nil, errors.New("packet count must not be negative")
+	}
+
+	// Reset decompressor
+	decomp.Reset()
+
+	reader := NewBitReaderWithBits(data, numBits)
+	packetBytes := (decomp.F + 7) / 8
+	output, _ := NewBitVector(decomp.F)
+	outputs := make([][]byte, 0, packetCount)
+
+	for i := 0; i < packetCount; i++ {
+		if reader.Remaining() <= 0 {
+			return outputs, fmt.Errorf("data ended after %d of %d packets", i, packetCount)
+		}
+
+		if err := decomp.decompressPacketInto(reader, output, nil); err != nil {
+			return outputs, fmt.Errorf("packet %d: %w", i, err)
 		}
-		outputs = append(outputs, outputBytes[:packetBytes])
+
+		outputBytes := make([]byte, packetBytes)
+		output.toBytesInto(outputBytes)
+		outputs = append(outputs, outputBytes)
 
 		// Align to byte boundary for next packet
-		reader.AlignByte()
+		if err := decomp.alignPacket(reader); err != nil {
+			return outputs, fmt.Errorf("packet %d: %w", i, err)
+		}
+	}
+
+	if decomp.checkExhausted && reader.Remaining() >= 8 {
+		return outputs, fmt.Errorf("%w: %d of %d bits left after %d packets",
+			ErrParameterMismatch, reader.Remaining(), numBits, packetCount)
+	}
+	if decomp.strictTrailing && reader.Remaining() > 0 {
+		return outputs, fmt.Errorf("%w: %d bits after packet %d", ErrTrailingData,
+			reader.Remaining(), packetCount-1)
 	}
 
 	return outputs, nil
@@ -317,6 +633,7 @@ func (decomp *Decompressor) DecompressStream(data []byte, numBits int) ([][]byte
 type PacketIterator struct {
 	decomp      *Decompressor
 	reader      *BitReader
+	output      *BitVector
 	packetBytes int
 	err         error
 }
@@ -324,9 +641,11 @@ type PacketIterator struct {
 // NewPacketIterator creates a streaming packet iterator.
 func (decomp *Decompressor) NewPacketIterator(data []byte, numBits int) *PacketIterator {
 	decomp.Reset()
+	output, _ := NewBitVector(decomp.F)
 	return &PacketIterator{
 		decomp:      decomp,
 		reader:      NewBitReaderWithBits(data, numBits),
+		output:      output,
 		packetBytes: (decomp.F + 7) / 8,
 	}
 }
@@ -337,22 +656,19 @@ func (it *PacketIterator) Next() []byte {
 		return nil
 	}
 
-	output, err := it.decomp.DecompressPacket(it.reader)
-	if err != nil {
+	if err := it.decomp.decompressPacketInto(it.reader, it.output, nil); err != nil {
 		it.err = err
 		return nil
 	}
 
-	// Convert to bytes
-	outputBytes := output.ToBytes()
-	if len(outputBytes) < it.packetBytes {
-		padded := make([]byte, it.packetBytes)
-		copy(padded, outputBytes)
-		outputBytes = padded
-	}
+	outputBytes := make([]byte, it.packetBytes)
+	it.output.toBytesInto(outputBytes)
 
-	it.reader.AlignByte()
-	return outputBytes[:it.packetBytes]
+	// The packet itself decoded; a padding error ends the iteration after it
+	if err := it.decomp.alignPacket(it.reader); err != nil {
+		it.err = err
+	}
+	return outputBytes
 }
 
 // Err returns any error encountered during iteration.
@@ -360,6 +676,39 @@ func (it *PacketIterator) Err() error {
 	return it.err
 }
 
+// Packets returns a pull-style iterator over the packets in data: each
+// call decompresses the next packet and returns it with ok=true, or nil
+// and false once the data is exhausted. The decompressor is reset first;
+// calling Packets again starts over.
+//
+// A single output buffer is reused, so the returned slice is only valid
+// until the next call; copy it to keep it. Iteration also ends at the
+// first packet that fails to decode. Use NewPacketIterator when the error
+// is needed.
+func (decomp *Decompressor) Packets(data []byte, numBits int) func() ([]byte, bool) {
+	decomp.Reset()
+	reader := NewBitReaderWithBits(data, numBits)
+	output, _ := NewBitVector(decomp.F)
+	packet := make([]byte, (decomp.F+7)/8)
+	done := false
+
+	return func() ([]byte, bool) {
+		if done || reader.Remaining() <= 0 {
+			return nil, false
+		}
+		if err := decomp.decompressPacketInto(reader, output, nil); err != nil {
+			done = true
+			return nil, false
+		}
+
+		output.toBytesInto(packet)
+		if err := decomp.alignPacket(reader); err != nil {
+			done = true
+		}
+		return packet, true
+	}
+}
+
 // StreamPackets returns a channel that yields decompressed packets.
 // The channel is closed when all packets are processed or on error.
 func (decomp *Decompressor) StreamPackets(data []byte, numBits int) <-chan []byte {
@@ -371,22 +720,20 @@ func (decomp *Decompressor) StreamPackets(data []byte, numBits int) <-chan []byt
 		decomp.Reset()
 		reader := NewBitReaderWithBits(data, numBits)
 		packetBytes := (decomp.F + 7) / 8
+		output, _ := NewBitVector(decomp.F)
 
 		for reader.Remaining() > 0 {
-			output, err := decomp.DecompressPacket(reader)
-			if err != nil {
+			if err := decomp.decompressPacketInto(reader, output, nil); err != nil {
 				return // Stop on error
 			}
 
-			outputBytes := output.ToBytes()
-			if len(outputBytes) < packetBytes {
-				padded := make([]byte, packetBytes)
-				copy(padded, outputBytes)
-				outputBytes = padded
-			}
+			outputBytes := make([]byte, packetBytes)
+			output.toBytesInto(outputBytes)
 
-			ch <- outputBytes[:packetBytes]
-			reader.AlignByte()
+			ch <- outputBytes
+			if err := decomp.alignPacket(reader); err != nil {
+				return
+			}
 		}
 	}()
 
@@ -0,0 +1,185 @@
+package pocketplus
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// ComputeDeltas returns the per-packet bit deltas of a packet stream.
+//
+// For each packet It the delta is It XOR It-1, the quantity that drives the
+// POCKET+ mask and build updates. The first delta is the first packet itself
+// (I-1 is taken as all zeros).
+//
+// One BitVector is allocated per packet for the returned deltas; the
+// working packet vectors are reused across the stream.
+func ComputeDeltas(data []byte, packetSize int) ([]*BitVector, error) {
+	if err := validatePacketSize(packetSize); err != nil {
+		return nil, err
+	}
+	if len(data)%packetSize != 0 {
+		return nil, errors.New("data length must be multiple of packet size")
+	}
+
+	numPackets := len(data) / packetSize
+	if numPackets == 0 {
+		return []*BitVector{}, nil
+	}
+
+	F := packetSize * 8
+	current, err := NewBitVector(F)
+	if err != nil {
+		return nil, err
+	}
+	prev, _ := NewBitVector(F)
+
+	deltas := make([]*BitVector, numPackets)
+	for i := 0; i < numPackets; i++ {
+		current.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		delta, _ := NewBitVector(F)
+		delta.XORInto(current, prev)
+		deltas[i] = delta
+
+		// Current packet becomes the previous one
+		prev, current = current, prev
+	}
+
+	return deltas, nil
+}
+
+// ChangeRate estimates how compressible a packet stream is by measuring how
+// many bits change between consecutive packets, i.e. the Hamming weight of
+// It XOR It-1 for t >= 1. Returns the mean and maximum over all packet
+// transitions (both zero for fewer than two packets).
+//
+// As a rule of thumb for choosing periods:
+//   - A mean that is small relative to F means the mask stays sparse and
+//     compressed packets are short; long rt and ft periods (e.g. 100+)
+//     cost little robustness.
+//   - A mean near the number of volatile bits with occasional spikes (high
+//     max) favours a short pt, so the mask is rebuilt after a transient.
+//   - A mean approaching F/2 indicates noise-like data that POCKET+ cannot
+//     compress; output will stay close to the input size whatever the
+//     periods.
+func ChangeRate(data []byte, packetSize int) (meanBitsChanged float64, maxBitsChanged int, err error) {
+	if err := validatePacketSize(packetSize); err != nil {
+		return 0, 0, err
+	}
+	if len(data)%packetSize != 0 {
+		return 0, 0, errors.New("data length must be multiple of packet size")
+	}
+
+	numPackets := len(data) / packetSize
+	if numPackets < 2 {
+		return 0, 0, nil
+	}
+
+	F := packetSize * 8
+	current, err := NewBitVector(F)
+	if err != nil {
+		return 0, 0, err
+	}
+	prev, _ := NewBitVector(F)
+	prev.FromBytes(data[:packetSize])
+
+	total := 0
+	for i := 1; i < numPackets; i++ {
+		current.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		changed, _ := current.HammingDistance(prev)
+		total += changed
+		if changed > maxBitsChanged {
+			maxBitsChanged = changed
+		}
+
+		// Current packet becomes the previous one
+		prev, current = current, prev
+	}
+
+	return float64(total) / float64(numPackets-1), maxBitsChanged, nil
+}
+
+// ChangeHistogram returns, for each bit position 0 to F-1 of a packet
+// stream, the number of packets t >= 1 in which that bit differs from
+// packet t-1, e.g. for a heatmap of volatile telemetry bits. Positions
+// that never change are candidates to leave out of an initial mask, and
+// the count of positions that change in most packets suggests how dense
+// the mask will settle. The result is all zeros for fewer than two packets.
+func ChangeHistogram(data []byte, packetSize int) ([]int, error) {
+	if err := validatePacketSize(packetSize); err != nil {
+		return nil, err
+	}
+	if len(data)%packetSize != 0 {
+		return nil, errors.New("data length must be multiple of packet size")
+	}
+
+	F := packetSize * 8
+	histogram := make([]int, F)
+	numPackets := len(data) / packetSize
+	if numPackets < 2 {
+		return histogram, nil
+	}
+
+	current, err := NewBitVector(F)
+	if err != nil {
+		return nil, err
+	}
+	prev, _ := NewBitVector(F)
+	prev.FromBytes(data[:packetSize])
+
+	for i := 1; i < numPackets; i++ {
+		current.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		// Visit the set bits of each changed word, MSB (lowest position) first
+		for w := 0; w < current.numWords; w++ {
+			diff := current.data[w] ^ prev.data[w]
+			for diff != 0 {
+				lz := bits.LeadingZeros32(diff)
+				histogram[w*32+lz]++
+				diff &^= 1 << (31 - lz)
+			}
+		}
+
+		// Current packet becomes the previous one
+		prev, current = current, prev
+	}
+
+	return histogram, nil
+}
+
+// MinBits returns a lower bound on the bits needed to code one packet: the
+// unpredictable bits, H(mask), plus the ceil(log2(C(F, H(changes)))) bits
+// it takes to say which of the F positions the mask changed at, where F is
+// the length of changes. Dividing the actual packet length by it gives an
+// efficiency ratio that exposes, for example, RLE overhead on dense masks.
+//
+// The bound assumes every set of changed positions is equally likely. RLE
+// favours changes near the end of the packet, so a single packet can come
+// out shorter than MinBits; the ratio is meaningful over many packets. A
+// nil argument counts as empty.
+func MinBits(mask, changes *BitVector) int {
+	total := 0
+	if mask != nil {
+		total += mask.HammingWeight()
+	}
+	if changes != nil {
+		total += log2Binomial(changes.length, changes.HammingWeight())
+	}
+	return total
+}
+
+// log2Binomial returns ceil(log2(C(n, k))).
+func log2Binomial(n, k int) int {
+	if k == 0 || k == n {
+		return 0
+	}
+	ln := func(x int) float64 {
+		v, _ := math.Lgamma(float64(x) + 1)
+		return v
+	}
+	bits := (ln(n) - ln(k) - ln(n-k)) / math.Ln2
+	// Absorb rounding error when C(n, k) is a power of two
+	return int(math.Ceil(bits - 1e-9))
+}
@@ -0,0 +1,178 @@
+package pocketplus
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestComputeDeltas(t *testing.T) {
+	data := []byte{
+		0xA5, 0x0F, // packet 0
+		0xA5, 0x0F, // packet 1: no change
+		0xA4, 0x8F, // packet 2: two bits flip
+	}
+
+	deltas, err := ComputeDeltas(data, 2)
+	if err != nil {
+		t.Fatalf("ComputeDeltas failed: %v", err)
+	}
+	if len(deltas) != 3 {
+		t.Fatalf("Expected 3 deltas, got %d", len(deltas))
+	}
+
+	expected := [][]byte{
+		{0xA5, 0x0F}, // first delta is the packet itself
+		{0x00, 0x00},
+		{0x01, 0x80},
+	}
+	for i, want := range expected {
+		if got := deltas[i].ToBytes(); !bytes.Equal(got, want) {
+			t.Errorf("Delta %d: expected %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestComputeDeltasEmpty(t *testing.T) {
+	deltas, err := ComputeDeltas([]byte{}, 8)
+	if err != nil {
+		t.Errorf("ComputeDeltas with empty input should not error: %v", err)
+	}
+	if len(deltas) != 0 {
+		t.Errorf("Expected no deltas, got %d", len(deltas))
+	}
+}
+
+func TestComputeDeltasInvalid(t *testing.T) {
+	if _, err := ComputeDeltas([]byte{1, 2, 3}, 0); err == nil {
+		t.Error("Expected error for zero packet size")
+	}
+	if _, err := ComputeDeltas([]byte{1, 2, 3}, 2); err == nil {
+		t.Error("Expected error for data length not multiple of packet size")
+	}
+}
+
+func TestChangeRate(t *testing.T) {
+	data := []byte{
+		0xA5, 0x0F, // packet 0
+		0xA5, 0x0F, // packet 1: no change
+		0xA4, 0x8F, // packet 2: two bits flip
+		0x5B, 0x8F, // packet 3: eight bits flip
+	}
+
+	mean, maxChanged, err := ChangeRate(data, 2)
+	if err != nil {
+		t.Fatalf("ChangeRate failed: %v", err)
+	}
+	if mean != 10.0/3.0 {
+		t.Errorf("Expected mean %f, got %f", 10.0/3.0, mean)
+	}
+	if maxChanged != 8 {
+		t.Errorf("Expected max 8, got %d", maxChanged)
+	}
+}
+
+func TestChangeRateSinglePacket(t *testing.T) {
+	mean, maxChanged, err := ChangeRate([]byte{0xFF, 0xFF}, 2)
+	if err != nil || mean != 0 || maxChanged != 0 {
+		t.Errorf("Expected (0, 0, nil), got (%f, %d, %v)", mean, maxChanged, err)
+	}
+}
+
+func TestChangeRateInvalid(t *testing.T) {
+	if _, _, err := ChangeRate([]byte{1, 2, 3}, 2); err == nil {
+		t.Error("Expected error for partial packet")
+	}
+	if _, _, err := ChangeRate([]byte{1, 2}, 0); err == nil {
+		t.Error("Expected error for zero packet size")
+	}
+}
+
+func TestChangeHistogram(t *testing.T) {
+	data := []byte{
+		0xA5, 0x0F, // packet 0
+		0xA5, 0x0F, // packet 1: no change
+		0xA4, 0x8F, // packet 2: bits 7 and 8 flip
+		0x5B, 0x8F, // packet 3: bits 0-7 flip
+	}
+
+	histogram, err := ChangeHistogram(data, 2)
+	if err != nil {
+		t.Fatalf("ChangeHistogram failed: %v", err)
+	}
+	expected := []int{1, 1, 1, 1, 1, 1, 1, 2, 1, 0, 0, 0, 0, 0, 0, 0}
+	if fmt.Sprint(histogram) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, histogram)
+	}
+
+	// Multi-word packets agree with the per-packet deltas
+	packetSize := 13
+	data = generateTelemetry(50, packetSize, 0.05, 3)
+	histogram, _ = ChangeHistogram(data, packetSize)
+	deltas, _ := ComputeDeltas(data, packetSize)
+	for pos := 0; pos < packetSize*8; pos++ {
+		count := 0
+		for _, delta := range deltas[1:] {
+			count += delta.GetBit(pos)
+		}
+		if histogram[pos] != count {
+			t.Errorf("Position %d: expected %d changes, got %d", pos, count, histogram[pos])
+		}
+	}
+
+	histogram, err = ChangeHistogram([]byte{0xFF, 0xFF}, 2)
+	if err != nil || len(histogram) != 16 || fmt.Sprint(histogram) != fmt.Sprint(make([]int, 16)) {
+		t.Errorf("Single packet: expected 16 zeros, got %v (%v)", histogram, err)
+	}
+	if _, err := ChangeHistogram([]byte{1, 2, 3}, 2); err == nil {
+		t.Error("Expected error for partial packet")
+	}
+}
+
+func TestMinBits(t *testing.T) {
+	mask, _ := NewBitVector(64)
+	changes, _ := NewBitVector(64)
+
+	if got := MinBits(mask, changes); got != 0 {
+		t.Errorf("Empty packet: expected 0, got %d", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		mask.SetBit(i*3, 1)
+	}
+	if got := MinBits(mask, changes); got != 5 {
+		t.Errorf("Mask only: expected 5, got %d", got)
+	}
+
+	// One change among 64 positions costs log2(64) = 6 bits
+	changes.SetBit(40, 1)
+	if got := MinBits(mask, changes); got != 11 {
+		t.Errorf("One change: expected 11, got %d", got)
+	}
+
+	// Two changes: C(64, 2) = 2016 needs 11 bits
+	changes.SetBit(7, 1)
+	if got := MinBits(nil, changes); got != 11 {
+		t.Errorf("Two changes: expected 11, got %d", got)
+	}
+
+	// Every position changed is a single possibility
+	changes.SetAll()
+	if got := MinBits(nil, changes); got != 0 {
+		t.Errorf("All changed: expected 0, got %d", got)
+	}
+}
+
+func TestLog2BinomialExact(t *testing.T) {
+	for _, tc := range []struct{ n, k, want int }{
+		{4, 2, 3},    // 6
+		{8, 1, 3},    // 8
+		{16, 8, 14},  // 12870
+		{720, 1, 10}, // 720
+		{3, 1, 2},    // 3
+	} {
+		if got := log2Binomial(tc.n, tc.k); got != tc.want {
+			t.Errorf("log2Binomial(%d, %d): expected %d, got %d", tc.n, tc.k, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pocketplus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DetectPrefixPackets is the number of leading packets DetectParameters
+// examines.
+const DetectPrefixPackets = 64
+
+// DetectParameters recovers the robustness R of compressed data whose
+// parameters were not recorded, given the packet size in bytes (which
+// cannot be recovered from the stream).
+//
+// No trial decode per candidate R is needed: the decompressor never uses
+// its configured robustness to parse, because every packet carries
+// Vt = R + Ct in-band. The first R+1 packets have Ct = 0, so R is the Vt of
+// the first packet. The remaining evidence only scores how plausible that
+// guess is. Up to DetectPrefixPackets packets are parsed, and confidence
+// is the fraction of them that are consistent with R:
+//   - the packet parses cleanly within the data
+//   - its Vt is at least R, and exactly R for the first R+1 packets
+//   - for the first R+1 packets, it is uncompressed and sends the mask
+//
+// A packet that fails to parse counts as inconsistent and ends the scan.
+//
+// Limitations: the result is only as good as packetSize; a wrong size
+// usually fails on the first packet with ErrParameterMismatch. Streams
+// produced outside the Compress schedule (custom CompressParams,
+// ChangeRobustness, non-standard extensions) may score low even when the
+// guess is right. Only a prefix is examined, so corruption later in the
+// data is not detected; use Validate for that. A stream that uses R=0
+// is reported as such, although the stream functions require R >= 1.
+func DetectParameters(data []byte, packetSize int) (robustness int, confidence float64, err error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("no data to examine")
+	}
+	if err := validatePacketSize(packetSize); err != nil {
+		return 0, 0, err
+	}
+
+	// Robustness does not affect parsing, so any valid value will do
+	decomp, err := NewDecompressor(packetSize*8, nil, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reader := NewBitReaderWithBits(data, len(data)*8)
+	examined, consistent := 0, 0
+
+	for examined < DetectPrefixPackets && reader.Remaining() > 0 {
+		start := reader.Position()
+		trace := &PacketTrace{}
+		rt, parseErr := decomp.parsePacket(reader, nil, trace)
+		if parseErr != nil && examined == 0 {
+			return 0, 0, fmt.Errorf("first packet: %w", parseErr)
+		}
+		examined++
+		if parseErr != nil {
+			break
+		}
+
+		vt := traceField(data, start, trace, "Vt")
+		if examined == 1 {
+			if vt > MaxRobustness {
+				return 0, 0, fmt.Errorf("%w: first packet has Vt=%d, above the maximum robustness %d",
+					ErrParameterMismatch, vt, MaxRobustness)
+			}
+			robustness = vt
+		}
+
+		ok := vt >= robustness
+		if decomp.t <= robustness {
+			ok = ok && vt == robustness &&
+				traceField(data, start, trace, "ft") == 1 && rt == 1
+		}
+		decomp.t++
+
+		reader.AlignByte()
+		if ok {
+			consistent++
+		}
+	}
+
+	return robustness, float64(consistent) / float64(examined), nil
+}
+
+// traceField reads the value of the named component of a packet that
+// starts at bit packetStart of data, or -1 if the packet has no such
+// component.
+func traceField(data []byte, packetStart int, trace *PacketTrace, name string) int {
+	for _, c := range trace.Components {
+		if c.Name != name {
+			continue
+		}
+		reader := NewBitReader(data)
+		if err := reader.Skip(packetStart + c.Offset); err != nil {
+			return -1
+		}
+		value, err := reader.ReadBits(c.Length)
+		if err != nil {
+			return -1
+		}
+		return int(value)
+	}
+	return -1
+}
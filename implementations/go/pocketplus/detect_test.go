@@ -0,0 +1,63 @@
+package pocketplus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectParameters(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(100, packetSize)
+
+	for r := 1; r <= MaxRobustness; r++ {
+		compressed, err := Compress(data, packetSize, r, 10, 20, 50)
+		if err != nil {
+			t.Fatalf("R=%d: Compress failed: %v", r, err)
+		}
+
+		robustness, confidence, err := DetectParameters(compressed, packetSize)
+		if err != nil {
+			t.Fatalf("R=%d: DetectParameters failed: %v", r, err)
+		}
+		if robustness != r {
+			t.Errorf("R=%d: detected robustness %d", r, robustness)
+		}
+		if confidence != 1 {
+			t.Errorf("R=%d: confidence %v, want 1", r, confidence)
+		}
+	}
+}
+
+func TestDetectParametersTruncated(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(10, packetSize)
+	compressed, _ := Compress(data, packetSize, 3, 10, 20, 50)
+
+	// Cutting the data mid-packet leaves the guess but lowers confidence
+	robustness, confidence, err := DetectParameters(compressed[:len(compressed)-1], packetSize)
+	if err != nil {
+		t.Fatalf("DetectParameters failed: %v", err)
+	}
+	if robustness != 3 {
+		t.Errorf("Detected robustness %d, want 3", robustness)
+	}
+	if confidence >= 1 || confidence <= 0 {
+		t.Errorf("Confidence %v, want between 0 and 1", confidence)
+	}
+}
+
+func TestDetectParametersInvalid(t *testing.T) {
+	if _, _, err := DetectParameters(nil, 16); err == nil {
+		t.Error("Expected error for empty data")
+	}
+	if _, _, err := DetectParameters([]byte{0xFF}, 0); err == nil {
+		t.Error("Expected error for invalid packet size")
+	}
+
+	// A wrong packet size fails on the first packet
+	data := generateTestPackets(10, 16)
+	compressed, _ := Compress(data, 16, 2, 10, 20, 50)
+	if _, _, err := DetectParameters(compressed, 64); !errors.Is(err, ErrParameterMismatch) {
+		t.Errorf("Wrong packet size: got %v, want ErrParameterMismatch", err)
+	}
+}
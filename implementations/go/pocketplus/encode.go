@@ -2,9 +2,17 @@ package pocketplus
 
 import (
 	"errors"
+	"fmt"
 	"math/bits"
 )
 
+// MaxCount is the largest value COUNT can encode. Every RLE run (the
+// distance between consecutive '1' bits, see RLEEncode) and COUNT(F) in
+// uncompressed packets must fit, so F is limited to MaxCount bits for
+// uncompressed packets, and longer vectors (up to MaxVectorBits) encode only
+// while no run of zeros in Xt or the mask reaches MaxCount.
+const MaxCount = 65535
+
 // CountEncode implements CCSDS 124.0-B-1 Section 5.2.2, Table 5-1, Equation 9.
 //
 // Encodes positive integers 1 <= A <= 2^16 - 1:
@@ -12,46 +20,64 @@ import (
 //   - 2 <= A <= 33 -> '110' || BIT_5(A-2)
 //   - A >= 34 -> '111' || BIT_E(A-2) where E = 2*floor(log2(A-2)+1) - 6
 func CountEncode(bb *BitBuffer, A int) error {
-	if A < 1 || A > 65535 {
-		return errors.New("COUNT: A must be in range [1, 65535]")
+	if A < 1 || A > MaxCount {
+		return fmt.Errorf("COUNT: A must be in range [1, %d]", MaxCount)
 	}
 
 	if A == 1 {
 		// Case 1: A = 1 -> '0'
 		bb.AppendBit(0)
 	} else if A <= 33 {
-		// Case 2: 2 <= A <= 33 -> '110' || BIT_5(A-2)
-		bb.AppendBit(1)
-		bb.AppendBit(1)
-		bb.AppendBit(0)
-
-		// Append BIT_5(A-2) MSB-first - 5 bits
-		value := A - 2
-		for i := 4; i >= 0; i-- {
-			bit := (value >> i) & 1
-			bb.AppendBit(bit)
-		}
+		// Case 2: 2 <= A <= 33 -> '110' || BIT_5(A-2), 8 bits in one append
+		bb.AppendValue(0b110<<5|uint64(A-2), 8)
 	} else {
 		// Case 3: A >= 34 -> '111' || BIT_E(A-2)
-		bb.AppendBit(1)
-		bb.AppendBit(1)
-		bb.AppendBit(1)
 
 		// Calculate E = 2*floor(log2(A-2)+1) - 6
 		// Using bits.Len for fast integer log2: floor(log2(x)) = bits.Len(x) - 1
-		value := A - 2
-		E := (2 * bits.Len(uint(value))) - 6
+		// A-2 >= 32 has at least 6 significant bits, so it fits in E bits
+		value := uint64(A - 2)
+		E := (2 * bits.Len64(value)) - 6
 
-		// Append BIT_E(A-2) MSB-first - E bits
-		for i := E - 1; i >= 0; i-- {
-			bit := (value >> i) & 1
-			bb.AppendBit(bit)
-		}
+		// Prefix and BIT_E(A-2), at most 3+26 bits, in one append
+		bb.AppendValue(0b111<<E|value, E+3)
 	}
 
 	return nil
 }
 
+// CountEncodeExtended writes A >= 1 with the NON-STANDARD chained COUNT
+// extension, which lifts the MaxCount limit: while A exceeds MaxCount-1,
+// COUNT(MaxCount) is emitted as a continuation standing for MaxCount-1,
+// followed by COUNT of the remainder (1 to MaxCount-1). Values below
+// MaxCount encode exactly as CountEncode; MaxCount itself becomes
+// COUNT(MaxCount) || COUNT(1). Decode with CountDecodeExtended.
+func CountEncodeExtended(bb *BitBuffer, A int) error {
+	if A < 1 {
+		return errors.New("COUNT: A must be positive")
+	}
+	for A > MaxCount-1 {
+		_ = CountEncode(bb, MaxCount)
+		A -= MaxCount - 1
+	}
+	return CountEncode(bb, A)
+}
+
+// CountEncodedBits returns the length in bits of COUNT(A), or 0 if A is
+// outside the encodable range [1, MaxCount].
+func CountEncodedBits(A int) int {
+	switch {
+	case A < 1 || A > MaxCount:
+		return 0
+	case A == 1:
+		return 1
+	case A <= 33:
+		return 3 + 5
+	default:
+		return 3 + (2 * bits.Len(uint(A-2))) - 6
+	}
+}
+
 // CountEncodeTerminator writes the RLE terminator pattern '10'.
 func CountEncodeTerminator(bb *BitBuffer) {
 	bb.AppendBit(1)
@@ -73,7 +99,24 @@ var debruijnLookup = [32]int{
 // and H(a) = Hamming weight (number of '1' bits in a)
 //
 // Note: Trailing zeros are not encoded (deducible from vector length)
+//
+// A C_i above MaxCount, possible only for vectors longer than MaxCount bits,
+// is reported as an error; the bits appended up to that point remain in bb.
+// RLEEncodeExtended lifts that limit.
 func RLEEncode(bb *BitBuffer, input *BitVector) error {
+	return rleEncode(bb, input, false)
+}
+
+// RLEEncodeExtended is RLEEncode with every C_i written by
+// CountEncodeExtended, so runs of any length encode. This is a NON-STANDARD
+// extension that only RLEDecodeExtended reads back; for vectors shorter
+// than MaxCount bits the output is identical to RLEEncode.
+func RLEEncodeExtended(bb *BitBuffer, input *BitVector) error {
+	return rleEncode(bb, input, true)
+}
+
+// rleEncode implements RLEEncode and RLEEncodeExtended.
+func rleEncode(bb *BitBuffer, input *BitVector, extended bool) error {
 	if input == nil {
 		return errors.New("RLE: input cannot be nil")
 	}
@@ -102,10 +145,18 @@ func RLEEncode(bb *BitBuffer, input *BitVector) error {
 
 			// Calculate delta (number of zeros + 1)
 			delta := oldBitPosition - newBitPosition
-
-			// Encode the count
-			if err := CountEncode(bb, delta); err != nil {
-				return err
+			if extended {
+				if err := CountEncodeExtended(bb, delta); err != nil {
+					return err
+				}
+			} else {
+				if delta > MaxCount {
+					return fmt.Errorf("RLE: run of %d bits before bit %d exceeds MaxCount (%d)",
+						delta, newBitPosition, MaxCount)
+				}
+				if err := CountEncode(bb, delta); err != nil {
+					return err
+				}
 			}
 
 			// Update old position for next iteration
@@ -122,6 +173,12 @@ func RLEEncode(bb *BitBuffer, input *BitVector) error {
 	return nil
 }
 
+// Bit ordering contract: position 0 is the first (most significant) bit of
+// a packet. RLE and BE walk from position F-1 down to 0, BitExtractForward
+// (kt) from 0 up to F-1. The decoders mirror these orders exactly and all
+// implementations must agree on them; TestExtractInsertOrderingInvariant
+// pins them down.
+
 // BitExtract implements CCSDS 124.0-B-1 Section 5.2.4, Equation 11.
 //
 // BE(a, b) = a_{g_{H(b)-1}} || ... || a_{g_1} || a_{g_0}
@@ -130,7 +187,16 @@ func RLEEncode(bb *BitBuffer, input *BitVector) error {
 //
 // Extracts bits from 'data' at positions where 'mask' has '1' bits.
 // Output order: highest position to lowest position
+//
+// Bits are appended to bb without clearing it, so extractions can be
+// chained into one buffer; see GetBitBuffer for reusing buffers.
 func BitExtract(bb *BitBuffer, data, mask *BitVector) error {
+	return bitExtract(bb, data, mask, true)
+}
+
+// bitExtract implements BitExtract. denseWords selects whether all-ones
+// mask words are copied whole (see WorkloadHint).
+func bitExtract(bb *BitBuffer, data, mask *BitVector, denseWords bool) error {
 	if data == nil || mask == nil {
 		return errors.New("BitExtract: data and mask cannot be nil")
 	}
@@ -147,6 +213,12 @@ func BitExtract(bb *BitBuffer, data, mask *BitVector) error {
 		if maskWord == 0 {
 			continue
 		}
+		if denseWords && maskWord == 0xFFFFFFFF {
+			// Dense mask (e.g. saturated by noisy data): the whole word in
+			// reverse order
+			bb.AppendBitsFromWord(bits.Reverse32(dataWord), 32)
+			continue
+		}
 
 		// Extract bits from low bit index to high (= high position to low position)
 		// Use isolate-LSB technique to process from bit 0 upward
@@ -172,6 +244,7 @@ func BitExtract(bb *BitBuffer, data, mask *BitVector) error {
 // BitExtractForward extracts bits in forward order (lowest position to highest).
 // Used for kt component: processes mask values at changed positions
 // in order from lowest position index to highest.
+// Like BitExtract, it appends to bb without clearing it.
 func BitExtractForward(bb *BitBuffer, data, mask *BitVector) error {
 	if data == nil || mask == nil {
 		return errors.New("BitExtractForward: data and mask cannot be nil")
@@ -2,6 +2,8 @@ package pocketplus
 
 import (
 	"bytes"
+	"math/bits"
+	"strings"
 	"testing"
 )
 
@@ -374,6 +376,31 @@ func TestRLEEncodeNil(t *testing.T) {
 	}
 }
 
+func TestRLEEncodeMaxCount(t *testing.T) {
+	bv, _ := NewBitVector(MaxCount + 1)
+
+	// The run up to bit 1 is exactly MaxCount long
+	bv.SetBit(1, 1)
+	if err := RLEEncode(NewBitBuffer(), bv); err != nil {
+		t.Errorf("Run of MaxCount should encode: %v", err)
+	}
+
+	// One bit further is out of range
+	bv.SetBit(1, 0)
+	bv.SetBit(0, 1)
+	err := RLEEncode(NewBitBuffer(), bv)
+	if err == nil || !strings.Contains(err.Error(), "MaxCount") {
+		t.Errorf("Expected MaxCount error, got %v", err)
+	}
+
+	// Zeros left of the first '1' are never encoded, however many
+	bv.SetBit(0, 0)
+	bv.SetBit(MaxCount, 1)
+	if err := RLEEncode(NewBitBuffer(), bv); err != nil {
+		t.Errorf("Run at the end of a long vector should encode: %v", err)
+	}
+}
+
 func TestBitExtractForwardNil(t *testing.T) {
 	bb := NewBitBuffer()
 	err := BitExtractForward(bb, nil, nil)
@@ -392,3 +419,130 @@ func TestBitExtractForwardLengthMismatch(t *testing.T) {
 		t.Error("Expected error for length mismatch")
 	}
 }
+
+func TestCountEncodeAllValues(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping exhaustive COUNT round-trip in short mode")
+	}
+
+	bb := NewBitBuffer()
+	for A := 1; A <= 65535; A++ {
+		bb.Clear()
+		if err := CountEncode(bb, A); err != nil {
+			t.Fatalf("CountEncode(%d) error: %v", A, err)
+		}
+		if got := CountEncodedBits(A); got != bb.NumBits() {
+			t.Fatalf("CountEncodedBits(%d) = %d, encoder wrote %d bits", A, got, bb.NumBits())
+		}
+
+		br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+		val, err := CountDecode(br)
+		if err != nil {
+			t.Fatalf("CountDecode(%d) error: %v", A, err)
+		}
+		if val != A {
+			t.Fatalf("Round-trip failed for %d: got %d", A, val)
+		}
+		if br.Remaining() != 0 {
+			t.Fatalf("CountDecode(%d) left %d bits unread", A, br.Remaining())
+		}
+	}
+}
+
+// referenceCountEncode is Equation 9 written out bit by bit, as a check
+// on the multi-bit appends in CountEncode.
+func referenceCountEncode(bb *BitBuffer, A int) {
+	if A == 1 {
+		bb.AppendBit(0)
+		return
+	}
+	width := 5
+	prefix := []int{1, 1, 0}
+	if A >= 34 {
+		width = 2*bits.Len(uint(A-2)) - 6
+		prefix = []int{1, 1, 1}
+	}
+	for _, bit := range prefix {
+		bb.AppendBit(bit)
+	}
+	for i := width - 1; i >= 0; i-- {
+		bb.AppendBit(((A - 2) >> i) & 1)
+	}
+}
+
+func TestCountEncodeMatchesReference(t *testing.T) {
+	// Encode every value after a 3-bit offset, so appends straddle bytes
+	got, want := NewBitBuffer(), NewBitBuffer()
+	for A := 1; A <= MaxCount; A++ {
+		got.Clear()
+		want.Clear()
+		got.AppendValue(0b101, 3)
+		want.AppendValue(0b101, 3)
+
+		_ = CountEncode(got, A)
+		referenceCountEncode(want, A)
+		if got.NumBits() != want.NumBits() || !bytes.Equal(got.ToBytes(), want.ToBytes()) {
+			t.Fatalf("CountEncode(%d) = %X (%d bits), expected %X (%d bits)",
+				A, got.ToBytes(), got.NumBits(), want.ToBytes(), want.NumBits())
+		}
+	}
+}
+
+func TestCountEncodedBitsOutOfRange(t *testing.T) {
+	for _, A := range []int{-1, 0, 65536} {
+		if got := CountEncodedBits(A); got != 0 {
+			t.Errorf("CountEncodedBits(%d) = %d, expected 0", A, got)
+		}
+	}
+}
+
+func TestRLEEncodeSingleBitPositions(t *testing.T) {
+	bb := NewBitBuffer()
+	for pos := 0; pos < 256; pos++ {
+		input, _ := NewBitVector(256)
+		input.SetBit(pos, 1)
+
+		bb.Clear()
+		if err := RLEEncode(bb, input); err != nil {
+			t.Fatalf("position %d: RLEEncode error: %v", pos, err)
+		}
+
+		// One COUNT for the distance from the end, then the terminator
+		expectedBits := CountEncodedBits(256-pos) + 2
+		if bb.NumBits() != expectedBits {
+			t.Errorf("position %d: expected %d bits, got %d", pos, expectedBits, bb.NumBits())
+		}
+
+		br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+		decoded, err := RLEDecode(br, 256)
+		if err != nil {
+			t.Fatalf("position %d: RLEDecode error: %v", pos, err)
+		}
+		if !decoded.Equals(input) {
+			t.Errorf("position %d: round-trip mismatch", pos)
+		}
+	}
+}
+
+func TestBitExtractChained(t *testing.T) {
+	data, _ := NewBitVector(8)
+	data.FromBytes([]byte{0xB3}) // 10110011
+
+	maskHigh, _ := NewBitVector(8)
+	maskHigh.FromBytes([]byte{0xF0})
+	maskLow, _ := NewBitVector(8)
+	maskLow.FromBytes([]byte{0x0F})
+
+	// Extractions append to the same buffer without clearing it
+	bb := NewBitBuffer()
+	BitExtract(bb, data, maskHigh)
+	BitExtractForward(bb, data, maskLow)
+
+	// BE over 1011 (reversed) = 1101, then forward 0011 -> 1101 0011
+	if bb.NumBits() != 8 {
+		t.Fatalf("Expected 8 bits, got %d", bb.NumBits())
+	}
+	if result := bb.ToBytes(); result[0] != 0xD3 {
+		t.Errorf("Expected 0xD3, got 0x%02X", result[0])
+	}
+}
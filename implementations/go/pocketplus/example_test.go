@@ -0,0 +1,114 @@
+package pocketplus_test
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tanagraspace/pocket-plus/implementations/go/pocketplus"
+)
+
+// telemetry returns numPackets 8-byte housekeeping packets in which only a
+// sequence counter in the last byte changes.
+func telemetry(numPackets int) []byte {
+	data := make([]byte, 0, numPackets*8)
+	for i := 0; i < numPackets; i++ {
+		data = append(data, 0xCA, 0xFE, 0x12, 0x34, 0x00, 0x00, 0x00, byte(i))
+	}
+	return data
+}
+
+func ExampleCompress() {
+	data := telemetry(100)
+
+	// 8-byte packets, robustness 1, pt=10, ft=20, rt=50
+	compressed, err := pocketplus.Compress(data, 8, 1, 10, 20, 50)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("%d bytes -> %d bytes\n", len(data), len(compressed))
+	// Output:
+	// 800 bytes -> 261 bytes
+}
+
+func ExampleDecompress() {
+	data := telemetry(100)
+	compressed, _ := pocketplus.Compress(data, 8, 1, 10, 20, 50)
+
+	// Packet size and robustness must match the compressor's
+	decompressed, err := pocketplus.Decompress(compressed, 8, 1)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(bytes.Equal(decompressed, data))
+	// Output:
+	// true
+}
+
+func ExampleNewCompressor_streaming() {
+	// F = 64 bits (8-byte packets), no initial mask, robustness 1
+	comp, _ := pocketplus.NewCompressor(64, nil, 1, 10, 20, 50)
+	decomp, _ := pocketplus.NewDecompressor(64, nil, 1)
+
+	input, _ := pocketplus.NewBitVector(64)
+	data := telemetry(3)
+
+	for i := 0; i < 3; i++ {
+		input.FromBytes(data[i*8 : (i+1)*8])
+
+		// The first packet is sent uncompressed with its mask; the rest
+		// only carry what changed
+		params := &pocketplus.CompressParams{MinRobustness: 1}
+		if i == 0 {
+			params.SendMaskFlag = true
+			params.UncompressedFlag = true
+		}
+
+		packet, err := comp.CompressPacket(input, params)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		output, err := decomp.DecompressPacket(pocketplus.NewBitReader(packet))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("packet %d: %d bytes, %x\n", i, len(packet), output.ToBytes())
+	}
+	// Output:
+	// packet 0: 11 bytes, cafe123400000000
+	// packet 1: 2 bytes, cafe123400000001
+	// packet 2: 2 bytes, cafe123400000002
+}
+
+func ExampleDecompressor_NewPacketIterator() {
+	compressed, _ := pocketplus.Compress(telemetry(3), 8, 1, 10, 20, 50)
+
+	decomp, _ := pocketplus.NewDecompressor(64, nil, 1)
+	iter := decomp.NewPacketIterator(compressed, len(compressed)*8)
+	for packet := iter.Next(); packet != nil; packet = iter.Next() {
+		fmt.Printf("%x\n", packet)
+	}
+	// Output:
+	// cafe123400000000
+	// cafe123400000001
+	// cafe123400000002
+}
+
+func ExampleBitVector() {
+	bv, _ := pocketplus.NewBitVector(12)
+
+	// Bit 0 is the most significant bit of the first byte
+	bv.SetBit(0, 1)
+	bv.SetBit(3, 1)
+	bv.SetBit(11, 1)
+
+	fmt.Printf("%x %d\n", bv.ToBytes(), bv.HammingWeight())
+	// Output:
+	// 9010 3
+}
@@ -0,0 +1,38 @@
+package pocketplus
+
+// SetExtendedCount enables a NON-STANDARD extension that chains COUNT
+// values (see CountEncodeExtended) so that RLE runs and COUNT(F) are no
+// longer limited to MaxCount. This allows packets longer than MaxCount
+// bits (8191 bytes) to be sent uncompressed and masks with longer runs to
+// be run-length encoded.
+//
+// A chain only appears where a value reaches MaxCount, so for F below
+// MaxCount the output is bit-identical to the standard encoding. Output is
+// only decodable by a Decompressor with SetExtendedCount(true); streams
+// with longer packets are not CCSDS compliant.
+func (comp *Compressor) SetExtendedCount(enabled bool) {
+	comp.extendedCount = enabled
+}
+
+// SetExtendedCount enables decoding of the chained COUNT extension
+// produced with Compressor.SetExtendedCount. It must match the setting
+// used by the compressor.
+func (decomp *Decompressor) SetExtendedCount(enabled bool) {
+	decomp.extendedCount = enabled
+}
+
+// countEncode writes COUNT(A) in the form selected by SetExtendedCount.
+func (comp *Compressor) countEncode(bb *BitBuffer, A int) error {
+	if comp.extendedCount {
+		return CountEncodeExtended(bb, A)
+	}
+	return CountEncode(bb, A)
+}
+
+// countDecode reads COUNT in the form selected by SetExtendedCount.
+func (decomp *Decompressor) countDecode(br *BitReader) (int, error) {
+	if decomp.extendedCount {
+		return CountDecodeExtended(br)
+	}
+	return CountDecode(br)
+}
@@ -0,0 +1,162 @@
+package pocketplus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCountExtendedRoundTrip(t *testing.T) {
+	values := []int{1, 2, 33, 34, MaxCount - 1, MaxCount, MaxCount + 1, 2*MaxCount - 2, 2*MaxCount - 1, 200000}
+
+	for _, A := range values {
+		bb := NewBitBuffer()
+		if err := CountEncodeExtended(bb, A); err != nil {
+			t.Fatalf("CountEncodeExtended(%d) failed: %v", A, err)
+		}
+
+		// Below MaxCount the encoding is the standard one
+		if A < MaxCount {
+			std := NewBitBuffer()
+			_ = CountEncode(std, A)
+			if !bytes.Equal(bb.ToBytes(), std.ToBytes()) || bb.NumBits() != std.NumBits() {
+				t.Errorf("A=%d: extended encoding differs from CountEncode", A)
+			}
+		}
+
+		br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+		got, err := CountDecodeExtended(br)
+		if err != nil {
+			t.Fatalf("CountDecodeExtended(%d) failed: %v", A, err)
+		}
+		if got != A || br.Remaining() != 0 {
+			t.Errorf("A=%d: decoded %d with %d bits left", A, got, br.Remaining())
+		}
+	}
+
+	if err := CountEncodeExtended(NewBitBuffer(), 0); err == nil {
+		t.Error("CountEncodeExtended: expected error for A=0")
+	}
+}
+
+func TestCountDecodeExtendedErrors(t *testing.T) {
+	// A continuation followed by the terminator
+	bb := NewBitBuffer()
+	_ = CountEncode(bb, MaxCount)
+	_ = CountEncode(bb, 0)
+	if _, err := CountDecodeExtended(NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())); err == nil {
+		t.Error("Expected error for terminator after continuation")
+	}
+
+	// A chain that never ends within the data
+	bb = NewBitBuffer()
+	_ = CountEncode(bb, MaxCount)
+	if _, err := CountDecodeExtended(NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())); err == nil {
+		t.Error("Expected error for truncated chain")
+	}
+
+	// A chain longer than any vector
+	bb = NewBitBuffer()
+	for i := 0; i <= MaxVectorBits/(MaxCount-1); i++ {
+		_ = CountEncode(bb, MaxCount)
+	}
+	_ = CountEncode(bb, 1)
+	if _, err := CountDecodeExtended(NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())); err == nil {
+		t.Error("Expected error for chain above MaxVectorBits")
+	}
+}
+
+func TestRLEExtendedRoundTrip(t *testing.T) {
+	F := 3*MaxCount + 100
+	bv, _ := NewBitVector(F)
+	for _, pos := range []int{0, 5, MaxCount - 2, MaxCount + 7, F - 2*MaxCount - 1} {
+		bv.SetBit(pos, 1)
+	}
+	bv.SetBit(F-1, 1)
+
+	if err := RLEEncode(NewBitBuffer(), bv); err == nil {
+		t.Fatal("RLEEncode: expected error for runs beyond MaxCount")
+	}
+
+	bb := NewBitBuffer()
+	if err := RLEEncodeExtended(bb, bv); err != nil {
+		t.Fatalf("RLEEncodeExtended failed: %v", err)
+	}
+	decoded, _ := NewBitVector(F)
+	br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	if err := RLEDecodeExtended(br, decoded); err != nil {
+		t.Fatalf("RLEDecodeExtended failed: %v", err)
+	}
+	if !decoded.Equals(bv) || br.Remaining() != 0 {
+		t.Error("Extended RLE round-trip mismatch")
+	}
+
+	// A chained run past the start of the vector is rejected
+	short, _ := NewBitVector(MaxCount)
+	br = NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	if err := RLEDecodeExtended(br, short); err == nil {
+		t.Error("RLEDecodeExtended: expected error for run beyond vector length")
+	}
+}
+
+func TestExtendedCountRoundTrip(t *testing.T) {
+	packetSize := 9000 // 72000 bits, above MaxCount
+	F := packetSize * 8
+	data := generateTestPackets(6, packetSize)
+
+	comp, _ := NewCompressor(F, nil, 1, 0, 0, 0)
+	params := &CompressParams{MinRobustness: 1, SendMaskFlag: true, UncompressedFlag: true}
+	input, _ := NewBitVector(F)
+	input.FromBytes(data[:packetSize])
+	if _, err := comp.CompressPacket(input, params); err == nil {
+		t.Fatal("Expected COUNT(F) error without SetExtendedCount")
+	}
+
+	comp.Reset()
+	comp.SetExtendedCount(true)
+	var compressed []byte
+	for i := 0; i < 6; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		params := &CompressParams{MinRobustness: 1}
+		if i < 2 || i == 4 {
+			params.SendMaskFlag = true
+			params.UncompressedFlag = true
+		}
+		packet, err := comp.CompressPacket(input, params)
+		if err != nil {
+			t.Fatalf("Packet %d: CompressPacket failed: %v", i, err)
+		}
+		compressed = append(compressed, packet...)
+	}
+
+	decomp, _ := NewDecompressor(F, nil, 1)
+	decomp.SetExtendedCount(true)
+	packets, err := decomp.DecompressStream(compressed, len(compressed)*8)
+	if err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if !bytes.Equal(bytes.Join(packets, nil), data) {
+		t.Error("Extended COUNT round-trip mismatch")
+	}
+
+	// The standard decoder cannot read COUNT(F)
+	std, _ := NewDecompressor(F, nil, 1)
+	if _, err := std.DecompressStream(compressed, len(compressed)*8); err == nil {
+		t.Error("Expected standard decoder to reject chained COUNT(F)")
+	}
+}
+
+func TestExtendedCountMatchesStandard(t *testing.T) {
+	packetSize := 64
+	data := generateTestPackets(100, packetSize)
+	plain, _ := Compress(data, packetSize, 2, 10, 20, 50)
+
+	comp, _ := newStreamCompressor(packetSize, 2, 10, 20, 50)
+	comp.SetExtendedCount(true)
+	extended, err := comp.compressAppend(nil, data, packetSize)
+	if err != nil {
+		t.Fatalf("Compression failed: %v", err)
+	}
+	if !bytes.Equal(plain, extended) {
+		t.Error("Extended COUNT output differs from standard below MaxCount")
+	}
+}
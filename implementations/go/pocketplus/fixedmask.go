@@ -0,0 +1,67 @@
+package pocketplus
+
+import "fmt"
+
+// SetFixedMask makes the mask static: it stays equal to the initial mask
+// instead of adapting to the data, pt (NewMaskFlag) has no effect, and
+// every packet extracts the bits at the fixed mask positions. A packet
+// whose input differs from the previous one outside the mask cannot be
+// predicted and is sent uncompressed (rt=1) instead, so the compressor
+// stays lossless. Such packets are counted in Stats as uncompressed.
+//
+// A fixed mask beats the adaptive one when the layout of varying fields
+// is known and static: there is no learning cost while the mask adapts,
+// no mask changes to signal, and a bit that changes once (e.g. a mode
+// flag) does not enter the mask and cost a bit in every later packet.
+// When the layout is not known exactly, unexpected changes fall back to
+// uncompressed packets, which is far worse than adapting.
+//
+// The output is standard CCSDS 124.0-B-1 and decodes with any
+// decompressor that has the same initial mask; Decompressor.SetFixedMask
+// additionally checks that the mask never changes. ForcedChanges cannot
+// be used with a fixed mask.
+func (comp *Compressor) SetFixedMask(enabled bool) {
+	comp.fixedMask = enabled
+}
+
+// SetFixedMask makes the decompressor verify that every packet leaves the
+// mask equal to the initial mask, as streams from a Compressor with
+// SetFixedMask do. A packet that changes it returns an error wrapping
+// ErrParameterMismatch.
+func (decomp *Decompressor) SetFixedMask(enabled bool) {
+	decomp.fixedMask = enabled
+}
+
+// fixedMaskParams returns params with UncompressedFlag set if input
+// changed from the previous packet outside the fixed mask.
+func (comp *Compressor) fixedMaskParams(input *BitVector, params *CompressParams) (*CompressParams, error) {
+	if params.UncompressedFlag || !changedOutside(input, comp.prevInput, comp.mask) {
+		return params, nil
+	}
+	if !comp.extendedCount && CountEncodedBits(comp.F) == 0 {
+		return nil, fmt.Errorf("input changed outside the fixed mask, and F=%d bits is too large to send uncompressed", comp.F)
+	}
+
+	forced := *params
+	forced.UncompressedFlag = true
+	return &forced, nil
+}
+
+// changedOutside reports whether a and b differ at any position where
+// mask is zero.
+func changedOutside(a, b, mask *BitVector) bool {
+	for i := 0; i < a.numWords; i++ {
+		if (a.data[i]^b.data[i])&^mask.data[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFixedMask verifies that the last packet left the mask unchanged.
+func (decomp *Decompressor) checkFixedMask() error {
+	if !decomp.mask.Equals(decomp.initialMask) {
+		return fmt.Errorf("%w: packet %d changes the fixed mask", ErrParameterMismatch, decomp.t)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package pocketplus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// compressFixedMask compresses data as a stream with a fixed mask, using
+// the Compress parameter schedule.
+func compressFixedMask(t *testing.T, data []byte, packetSize int, mask *BitVector) ([]byte, StreamStats) {
+	t.Helper()
+	comp, _ := NewCompressor(packetSize*8, mask, 2, 10, 20, 50)
+	comp.SetFixedMask(true)
+
+	input, _ := NewBitVector(packetSize * 8)
+	var out []byte
+	for i := 0; i < len(data)/packetSize; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		packet, err := comp.CompressPacket(input, comp.scheduleParams(i))
+		if err != nil {
+			t.Fatalf("Packet %d: CompressPacket failed: %v", i, err)
+		}
+		out = append(out, packet...)
+	}
+	return out, comp.Stats()
+}
+
+func TestFixedMaskRoundTrip(t *testing.T) {
+	packetSize := 16
+	numPackets := 100
+	data := generateTestPackets(numPackets, packetSize)
+
+	// The 7 counter bits in use and the toggling bit of the last byte
+	mask, _ := NewBitVector(packetSize * 8)
+	mask.FromBytes([]byte{0, 0x7F, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10})
+
+	compressed, stats := compressFixedMask(t, data, packetSize, mask)
+
+	// Only the scheduled packets (the first R+1 and packet 50) are sent
+	// uncompressed
+	if got := stats.Sync + stats.Uncompressed; got != 4 {
+		t.Errorf("Expected 4 uncompressed packets, got %d", got)
+	}
+
+	// Standard decoding with the same initial mask, and the checked mode
+	for _, fixed := range []bool{false, true} {
+		decomp, _ := NewDecompressor(packetSize*8, mask, 2)
+		decomp.SetFixedMask(fixed)
+		packets, err := decomp.DecompressStream(compressed, len(compressed)*8)
+		if err != nil {
+			t.Fatalf("Fixed=%v: DecompressStream failed: %v", fixed, err)
+		}
+		if !bytes.Equal(bytes.Join(packets, nil), data) {
+			t.Errorf("Fixed=%v: round-trip mismatch", fixed)
+		}
+	}
+
+	// With the layout known up front there is no learning cost
+	adaptive, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	if len(compressed) >= len(adaptive) {
+		t.Errorf("Fixed mask output %d bytes, expected less than adaptive %d bytes",
+			len(compressed), len(adaptive))
+	}
+}
+
+func TestFixedMaskChangeOutsideMask(t *testing.T) {
+	packetSize := 16
+	numPackets := 30
+	data := generateTestPackets(numPackets, packetSize)
+
+	// Leave out the toggling bit, which changes every 5 packets
+	mask, _ := NewBitVector(packetSize * 8)
+	mask.FromBytes([]byte{0, 0x7F, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+	compressed, stats := compressFixedMask(t, data, packetSize, mask)
+
+	// Packets 0-2 are scheduled uncompressed; 5, 10, 15, 20 and 25 toggle
+	// the bit outside the mask
+	if got := stats.Sync + stats.Uncompressed; got != 8 {
+		t.Errorf("Expected 8 uncompressed packets, got %d", got)
+	}
+
+	decomp, _ := NewDecompressor(packetSize*8, mask, 2)
+	decomp.SetFixedMask(true)
+	packets, err := decomp.DecompressStream(compressed, len(compressed)*8)
+	if err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+	if !bytes.Equal(bytes.Join(packets, nil), data) {
+		t.Error("Round-trip mismatch")
+	}
+}
+
+func TestFixedMaskErrors(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(20, packetSize)
+
+	// An adaptive stream changes the mask
+	adaptive, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	decomp, _ := NewDecompressor(packetSize*8, nil, 2)
+	decomp.SetFixedMask(true)
+	if _, err := decomp.DecompressStream(adaptive, len(adaptive)*8); !errors.Is(err, ErrParameterMismatch) {
+		t.Errorf("Expected ErrParameterMismatch for adaptive stream, got %v", err)
+	}
+
+	comp, _ := NewCompressor(packetSize*8, nil, 2, 10, 20, 50)
+	comp.SetFixedMask(true)
+	input, _ := NewBitVector(packetSize * 8)
+	forced, _ := NewBitVector(packetSize * 8)
+	if _, err := comp.CompressPacket(input, &CompressParams{MinRobustness: 2, ForcedChanges: forced}); err == nil {
+		t.Error("Expected error for ForcedChanges with a fixed mask")
+	}
+}
@@ -0,0 +1,237 @@
+package pocketplus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// MaxFrameBits is the largest compressed packet that fits a frame prefix,
+// bounded by the COUNT code range.
+const MaxFrameBits = MaxCount
+
+// CompressFramed compresses data like Compress, but emits every packet as
+// COUNT(L) || ot, where L is the packet's length in bits.
+//
+// The length prefix lets a reader skip to the next packet without decoding
+// the current one, which simplifies indexing and resynchronization after
+// corruption. Frames are packed back to back without byte alignment; only
+// the end of the stream is padded with zeros.
+//
+// Size overhead per packet is the COUNT prefix: 8 bits for L <= 33, 9 bits
+// for L <= 65, then 2 more bits each time L doubles (11 bits for L <= 129,
+// 13 bits for L <= 257, at most 29 bits). This is partly offset by dropping
+// the 0-7 alignment bits of the unframed format. Packets whose compressed
+// size exceeds MaxFrameBits cannot be framed and cause an error.
+func CompressFramed(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	comp, err := newStreamCompressor(packetSize, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%packetSize != 0 {
+		return nil, errors.New("data length must be multiple of packet size")
+	}
+
+	input, _ := NewBitVector(comp.F)
+	output := NewBitBuffer()
+
+	for i := 0; i*packetSize < len(data); i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+		if err != nil {
+			return nil, err
+		}
+
+		packetBits := comp.workOutput.NumBits()
+		if packetBits > MaxFrameBits {
+			return nil, fmt.Errorf("packet %d: %d bits exceeds frame limit of %d",
+				i, packetBits, MaxFrameBits)
+		}
+
+		CountEncode(output, packetBits)
+		output.AppendBits(compressed, packetBits)
+	}
+
+	return output.ToBytes(), nil
+}
+
+// DecompressFramed decompresses data produced by CompressFramed.
+//
+// Each packet is decoded from exactly the bits its length prefix declares;
+// a packet that does not consume its whole frame is reported as an error.
+// Fewer than 8 trailing bits are treated as end-of-stream padding (every
+// frame is at least 15 bits long).
+func DecompressFramed(data []byte, packetSize, robustness int) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	decomp, err := newStreamDecompressor(packetSize, robustness)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := NewBitReader(data)
+	packet := make([]byte, packetSize)
+	var output bytes.Buffer
+
+	for i := 0; reader.Remaining() >= 8; i++ {
+		frameBits, err := CountDecode(reader)
+		if err != nil {
+			return nil, fmt.Errorf("packet %d: frame length: %w", i, err)
+		}
+		if frameBits > reader.Remaining() {
+			return nil, fmt.Errorf("packet %d: frame of %d bits exceeds remaining %d",
+				i, frameBits, reader.Remaining())
+		}
+
+		// Decode within the frame bounds only
+		frame := NewBitReaderWithBits(data, reader.Position()+frameBits)
+		frame.position = reader.Position()
+
+		out, err := decomp.DecompressPacket(frame)
+		if err != nil {
+			return nil, fmt.Errorf("packet %d: %w", i, err)
+		}
+		if frame.Remaining() != 0 {
+			return nil, fmt.Errorf("packet %d: %d unused bits in frame", i, frame.Remaining())
+		}
+
+		out.toBytesInto(packet)
+		output.Write(packet)
+
+		reader.Skip(frameBits)
+	}
+
+	return output.Bytes(), nil
+}
+
+// DecompressResilient decompresses data produced by CompressFramed on a
+// best-effort basis: a packet that fails to decode is skipped and decoding
+// resumes at the next frame, found through the length prefix.
+//
+// packets has one entry per frame in stream order; skipped frames are nil
+// and their indices are listed in skipped. The decompressor state is
+// rolled back to before a skipped packet, which is then treated as lost,
+// so the following packets decode correctly once the loss is covered by
+// the robustness window or a packet carrying the full mask and input. A
+// corrupted packet that still parses is not detected and may yield wrong
+// output. A corrupted length prefix loses frame sync; decoding stops with
+// an error and the packets decoded so far are returned.
+//
+// Recovery relies on the framed format: in the unframed format the end of
+// a packet is only known by decoding it.
+func DecompressResilient(data []byte, packetSize, robustness int) (packets [][]byte, skipped []int, err error) {
+	if len(data) == 0 {
+		return [][]byte{}, nil, nil
+	}
+	decomp, err := newStreamDecompressor(packetSize, robustness)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	savedMask, _ := NewBitVector(decomp.F)
+	savedOutput, _ := NewBitVector(decomp.F)
+	savedXt, _ := NewBitVector(decomp.F)
+
+	reader := NewBitReader(data)
+	for i := 0; reader.Remaining() >= 8; i++ {
+		frameBits, err := CountDecode(reader)
+		if err != nil {
+			return packets, skipped, fmt.Errorf("packet %d: frame length: %w", i, err)
+		}
+		if frameBits > reader.Remaining() {
+			return packets, skipped, fmt.Errorf("packet %d: frame of %d bits exceeds remaining %d",
+				i, frameBits, reader.Remaining())
+		}
+
+		savedMask.CopyFrom(decomp.mask)
+		savedOutput.CopyFrom(decomp.prevOutput)
+		savedXt.CopyFrom(decomp.Xt)
+		savedT := decomp.t
+
+		frame := NewBitReaderWithBits(data, reader.Position()+frameBits)
+		frame.position = reader.Position()
+
+		out, err := decomp.DecompressPacket(frame)
+		if err == nil && frame.Remaining() == 0 {
+			packets = append(packets, out.ToBytes())
+		} else {
+			// Roll back and count the packet as lost
+			decomp.mask.CopyFrom(savedMask)
+			decomp.prevOutput.CopyFrom(savedOutput)
+			decomp.Xt.CopyFrom(savedXt)
+			decomp.t = savedT + 1
+			packets = append(packets, nil)
+			skipped = append(skipped, i)
+		}
+
+		reader.Skip(frameBits)
+	}
+
+	return packets, skipped, nil
+}
+
+// CompressToFrames compresses data into transfer frames of at most
+// frameBytes bytes each.
+//
+// Every frame starts with a sync packet (see Compressor.EmitSync) and is
+// filled with the following packets until the next one would not fit, so
+// each frame decodes on its own with Decompress and the loss of a frame
+// does not affect the others. Packets are not split across frames; a sync
+// packet larger than frameBytes is an error.
+//
+// Each frame pays for one sync packet, roughly the size of an uncompressed
+// packet, so frameBytes should hold many compressed packets.
+func CompressToFrames(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit, frameBytes int) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	comp, err := newStreamCompressor(packetSize, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%packetSize != 0 {
+		return nil, errors.New("data length must be multiple of packet size")
+	}
+	if frameBytes <= 0 {
+		return nil, errors.New("frame size must be positive")
+	}
+
+	input, _ := NewBitVector(comp.F)
+	var frames [][]byte
+	var frame []byte
+
+	for i := 0; i*packetSize < len(data); i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(frame)+len(compressed) > frameBytes {
+			frames = append(frames, frame)
+
+			// Start the next frame with this packet as a sync packet. The
+			// compressor has already seen the input, so it is sent as an
+			// unchanged repeat carrying the full mask and input.
+			compressed, err = comp.EmitSync(input)
+			if err != nil {
+				return nil, err
+			}
+			frame = nil
+		}
+		if len(frame) == 0 && len(compressed) > frameBytes {
+			return nil, fmt.Errorf("packet %d: sync packet of %d bytes exceeds frame size %d",
+				i, len(compressed), frameBytes)
+		}
+
+		frame = append(frame, compressed...)
+	}
+
+	return append(frames, frame), nil
+}
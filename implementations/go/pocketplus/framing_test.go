@@ -0,0 +1,198 @@
+package pocketplus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressFramedRoundTrip(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(80, packetSize)
+
+	framed, err := CompressFramed(data, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressFramed failed: %v", err)
+	}
+
+	decompressed, err := DecompressFramed(framed, packetSize, 2)
+	if err != nil {
+		t.Fatalf("DecompressFramed failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Framed round-trip mismatch")
+	}
+
+	// Overhead stays within the prefix size per packet
+	unframed, _ := Compress(data, packetSize, 2, 10, 20, 50)
+	if len(framed) > len(unframed)+80*13/8+1 {
+		t.Errorf("Framed size %d too large vs unframed %d", len(framed), len(unframed))
+	}
+}
+
+func TestDecompressFramedSkipsByPrefix(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(10, packetSize)
+
+	framed, err := CompressFramed(data, packetSize, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressFramed failed: %v", err)
+	}
+
+	// Walk the frames using only the prefixes
+	reader := NewBitReader(framed)
+	frames := 0
+	for reader.Remaining() >= 8 {
+		frameBits, err := CountDecode(reader)
+		if err != nil {
+			t.Fatalf("CountDecode failed: %v", err)
+		}
+		if err := reader.Skip(frameBits); err != nil {
+			t.Fatalf("Skip failed: %v", err)
+		}
+		frames++
+	}
+	if frames != 10 {
+		t.Errorf("Expected 10 frames, got %d", frames)
+	}
+}
+
+func TestDecompressFramedCorruptLength(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(4, packetSize)
+
+	framed, err := CompressFramed(data, packetSize, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressFramed failed: %v", err)
+	}
+
+	// First frame is uncompressed (> 64 bits), prefixed '111' + BIT_E;
+	// flipping the low bit of the length misframes it
+	corrupt := append([]byte{}, framed...)
+	corrupt[1] ^= 0x20
+	if _, err := DecompressFramed(corrupt, packetSize, 1); err == nil {
+		t.Error("Expected error for corrupted frame length")
+	}
+}
+
+func TestDecompressResilient(t *testing.T) {
+	packetSize := 16
+	numPackets := 40
+	data := generateTestPackets(numPackets, packetSize)
+
+	framed, err := CompressFramed(data, packetSize, 2, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("CompressFramed failed: %v", err)
+	}
+
+	packets, skipped, err := DecompressResilient(framed, packetSize, 2)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("Clean stream: err %v, skipped %v", err, skipped)
+	}
+	if !bytes.Equal(bytes.Join(packets, nil), data) {
+		t.Fatal("Clean stream round-trip mismatch")
+	}
+
+	// Locate frame k's payload through the prefixes
+	k := 15
+	reader := NewBitReader(framed)
+	var start, length int
+	for i := 0; i <= k; i++ {
+		length, _ = CountDecode(reader)
+		start = reader.Position()
+		reader.Skip(length)
+	}
+
+	// Flip each payload bit in turn; whenever the packet is rejected, only
+	// it is lost and every other packet still decodes correctly
+	rejected := 0
+	for b := 0; b < length; b++ {
+		corrupt := append([]byte{}, framed...)
+		corrupt[(start+b)/8] ^= 0x80 >> ((start + b) % 8)
+
+		packets, skipped, err := DecompressResilient(corrupt, packetSize, 2)
+		if err != nil {
+			t.Fatalf("Bit %d: unexpected error: %v", b, err)
+		}
+		if len(packets) != numPackets {
+			t.Fatalf("Bit %d: expected %d packets, got %d", b, numPackets, len(packets))
+		}
+		if len(skipped) == 0 {
+			continue
+		}
+		rejected++
+		if len(skipped) != 1 || skipped[0] != k || packets[k] != nil {
+			t.Fatalf("Bit %d: expected only packet %d skipped, got %v", b, k, skipped)
+		}
+		for i, packet := range packets {
+			if i != k && !bytes.Equal(packet, data[i*packetSize:(i+1)*packetSize]) {
+				t.Errorf("Bit %d: packet %d decoded wrongly after the skip", b, i)
+			}
+		}
+	}
+	if rejected == 0 {
+		t.Error("Expected at least one bit flip to be rejected")
+	}
+}
+
+func TestDecompressResilientCorruptLength(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(4, packetSize)
+	framed, _ := CompressFramed(data, packetSize, 1, 10, 20, 50)
+
+	// A bad prefix loses frame sync, so decoding stops
+	corrupt := append([]byte{}, framed...)
+	corrupt[1] ^= 0x20
+	if _, _, err := DecompressResilient(corrupt, packetSize, 1); err == nil {
+		t.Error("Expected error for corrupted frame length")
+	}
+}
+
+func TestCompressFramedEmpty(t *testing.T) {
+	framed, err := CompressFramed([]byte{}, 8, 1, 10, 20, 50)
+	if err != nil || len(framed) != 0 {
+		t.Errorf("Expected empty output, got %v, %v", framed, err)
+	}
+}
+
+func TestCompressToFrames(t *testing.T) {
+	packetSize := 90
+	numPackets := 200
+	data := generateTestPackets(numPackets, packetSize)
+
+	for _, robustness := range []int{1, 3} {
+		frames, err := CompressToFrames(data, packetSize, robustness, 10, 20, 50, 400)
+		if err != nil {
+			t.Fatalf("R=%d: CompressToFrames failed: %v", robustness, err)
+		}
+		if len(frames) < 2 {
+			t.Fatalf("R=%d: expected several frames, got %d", robustness, len(frames))
+		}
+
+		// Every frame decodes on its own, and together they give the input
+		var joined []byte
+		for i, frame := range frames {
+			if len(frame) == 0 || len(frame) > 400 {
+				t.Errorf("R=%d frame %d: size %d outside (0, 400]", robustness, i, len(frame))
+			}
+			decompressed, err := Decompress(frame, packetSize, robustness)
+			if err != nil {
+				t.Fatalf("R=%d frame %d: Decompress failed: %v", robustness, i, err)
+			}
+			joined = append(joined, decompressed...)
+		}
+		if !bytes.Equal(joined, data) {
+			t.Errorf("R=%d: frames do not decode to the input", robustness)
+		}
+	}
+}
+
+func TestCompressToFramesTooSmall(t *testing.T) {
+	data := generateTestPackets(10, 90)
+
+	if _, err := CompressToFrames(data, 90, 1, 10, 20, 50, 50); err == nil {
+		t.Error("Expected error when a sync packet exceeds the frame size")
+	}
+	if _, err := CompressToFrames(data, 90, 1, 10, 20, 50, 0); err == nil {
+		t.Error("Expected error for zero frame size")
+	}
+}
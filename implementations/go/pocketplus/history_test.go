@@ -0,0 +1,292 @@
+package pocketplus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// generateBurstyPackets builds a deterministic stream that alternates
+// between quiet stretches (identical packets) and bursts of changes, so
+// both the robustness window and the Ct run length are exercised.
+func generateBurstyPackets(numPackets, packetSize int) []byte {
+	data := make([]byte, numPackets*packetSize)
+	state := uint32(12345)
+	next := func() uint32 {
+		state = state*1103515245 + 12345
+		return state >> 16
+	}
+
+	for i := 0; i < numPackets; i++ {
+		packet := data[i*packetSize : (i+1)*packetSize]
+		if i > 0 {
+			copy(packet, data[(i-1)*packetSize:i*packetSize])
+		}
+		// Quiet for 20 packets out of every 32
+		if i%32 >= 20 {
+			flips := int(next()%4) + 1
+			for f := 0; f < flips; f++ {
+				bit := int(next()) % (packetSize * 8)
+				packet[bit/8] ^= 0x80 >> (bit % 8)
+			}
+		}
+	}
+	return data
+}
+
+// compressScheduled compresses data packet by packet using the standard
+// parameter schedule, allowing robustness levels below 1.
+func compressScheduled(t *testing.T, data []byte, packetSize, robustness, pt, ft, rt int) []byte {
+	t.Helper()
+
+	F := packetSize * 8
+	comp, err := NewCompressor(F, nil, robustness, pt, ft, rt)
+	if err != nil {
+		t.Fatalf("NewCompressor failed: %v", err)
+	}
+
+	var output []byte
+	input, _ := NewBitVector(F)
+	for i := 0; i < len(data)/packetSize; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+		if err != nil {
+			t.Fatalf("CompressPacket %d failed: %v", i, err)
+		}
+		output = append(output, compressed...)
+	}
+	return output
+}
+
+func TestHistorySizedToRobustness(t *testing.T) {
+	// Golden SHA-256 digests produced by the original fixed 16-slot change
+	// history; the robustness-sized history must reproduce them exactly
+	expected := []string{
+		"391bf20a00c0be57c548d095b2b6375b1ae6ce228c3485f354fb020b34953ddd",
+		"1c4c9b5136cf8b827935bd2c5020c19c70bc06d8940b24aa32e696b5d6eed77b",
+		"16f7b18e484d2ce16cc04c448088fe3774312a5357b7493f2cf24e54d1b4320d",
+		"d079efce2dc2caa43bef3ef7476b3ea0e510f135f5639e52e5380c12fe846dcc",
+		"69a2d6b1d5c67913697623ccf89c5ca4f55383a7e34dafd6c0d0a54bb5cf57b7",
+		"17b405e38e91528c12b94d96033b49f37681552ce33e3757e4ec58fd05032220",
+		"f1f7692543d8fc49875e626d710e9c616138c8b6365b376b1933609cbd98bdcf",
+		"98cc2ebfa9231aa6440949273c3bae4a2dc7acfa58d1f0bcc48f7fbbb53172c7",
+	}
+
+	data := generateBurstyPackets(400, 16)
+
+	for r := 0; r <= MaxRobustness; r++ {
+		compressed := compressScheduled(t, data, 16, r, 10, 20, 50)
+		sum := sha256.Sum256(compressed)
+		got := hex.EncodeToString(sum[:])
+		if got != expected[r] {
+			t.Errorf("Robustness %d: output digest %s, expected %s", r, got, expected[r])
+		}
+
+		decomp, _ := NewDecompressor(16*8, nil, r)
+		packets, err := decomp.DecompressStream(compressed, len(compressed)*8)
+		if err != nil {
+			t.Fatalf("Robustness %d: DecompressStream failed: %v", r, err)
+		}
+		if !bytes.Equal(bytes.Join(packets, nil), data) {
+			t.Errorf("Robustness %d: round-trip mismatch", r)
+		}
+
+		comp, _ := NewCompressor(16*8, nil, r, 10, 20, 50)
+		if len(comp.changeHistory) != r+1 {
+			t.Errorf("Robustness %d: expected %d history slots, got %d",
+				r, r+1, len(comp.changeHistory))
+		}
+	}
+}
+
+func TestVtCtSequence(t *testing.T) {
+	// Mask changes happen at packets 1 and 6 only; every other packet
+	// repeats its predecessor. D0 is empty since M0 = 0.
+	const numPackets = 26
+	changeAt := map[int]int{1: 3, 6: 9} // packet -> bit that flips
+
+	// Expected Vt per packet, hand-computed from CCSDS 124.0-B-1 5.3.2.2:
+	// Vt = Rt for t <= Rt, otherwise Vt = Rt + Ct where Ct counts empty
+	// D(t-Rt-1), D(t-Rt-2), ... back to D(t-15), capped at 15 - Rt.
+	testCases := []struct {
+		robustness int
+		vt         []int
+	}{
+		{0, []int{0, 1, 0, 1, 2, 3, 4, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 15, 15, 15}},
+		{1, []int{1, 1, 2, 1, 2, 3, 4, 5, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 15, 15, 15}},
+		{2, []int{2, 2, 2, 3, 2, 3, 4, 5, 6, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 15, 15, 15}},
+		{7, []int{7, 7, 7, 7, 7, 7, 7, 7, 8, 7, 8, 9, 10, 11, 7, 8, 9, 10, 11, 12, 13, 14, 15, 15, 15, 15}},
+	}
+
+	for _, tc := range testCases {
+		F := 16
+		comp, _ := NewCompressor(F, nil, tc.robustness, 100, 100, 100)
+		input, _ := NewBitVector(F)
+
+		for i := 0; i < numPackets; i++ {
+			if bit, ok := changeAt[i]; ok {
+				input.SetBit(bit, 1-input.GetBit(bit))
+			}
+
+			out, err := comp.CompressPacket(input, nil)
+			if err != nil {
+				t.Fatalf("R=%d packet %d: CompressPacket failed: %v", tc.robustness, i, err)
+			}
+
+			// ht starts with RLE(Xt) || BIT4(Vt)
+			br := NewBitReader(out)
+			if _, err := RLEDecode(br, F); err != nil {
+				t.Fatalf("R=%d packet %d: RLEDecode failed: %v", tc.robustness, i, err)
+			}
+			vt, err := br.ReadBits(4)
+			if err != nil {
+				t.Fatalf("R=%d packet %d: reading Vt failed: %v", tc.robustness, i, err)
+			}
+
+			if int(vt) != tc.vt[i] {
+				t.Errorf("R=%d packet %d: Vt=%d (Ct=%d), expected Vt=%d (Ct=%d)",
+					tc.robustness, i, vt, int(vt)-tc.robustness,
+					tc.vt[i], tc.vt[i]-tc.robustness)
+			}
+		}
+	}
+}
+
+// TestCtFlagSequence scripts pt (NewMaskFlag) so that mask bits drop out
+// (et=1) and checks the ct bit emitted after kt, which the nil-params
+// packets of TestVtCtSequence never reach.
+func TestCtFlagSequence(t *testing.T) {
+	// R=1, F=16. Hand-computed from CCSDS 124.0-B-1 Equations 6-8 and
+	// 5.3.2.2, with Dt the mask change and Xt = Dt OR Dt-1:
+	//   t=1: bit 3 flips            M={3}    D={3}
+	//   t=3: pt, B2={3}             M={3}    D={}   B reset
+	//   t=6: bit 9 flips            M={3,9}  D={9}  Ct run restarts
+	//   t=8: pt, B7={9}             M={9}    D={3}  Xt={3}, et=1, Vt=1
+	//        ct counts pt at t=8 and t=7 (Vt=1 back): 1 -> ct=0
+	//   t=9: pt, B8={}              M={}     D={9}  Xt={3,9}, et=1, Vt=2
+	//        ct counts pt at t=9, t=8 and t=7: 2 -> ct=1
+	// et is -1 where it is not sent (Vt=0 or empty Xt), ct is -1 where et
+	// is not 1.
+	const F = 16
+	flips := map[int]int{1: 3, 6: 9}
+	pt := map[int]bool{3: true, 8: true, 9: true}
+	expected := []struct{ vt, et, ct int }{
+		{1, -1, -1}, {1, 0, -1}, {2, 0, -1}, {1, -1, -1}, {2, -1, -1},
+		{3, -1, -1}, {4, 0, -1}, {5, 0, -1}, {1, 1, 0}, {2, 1, 1},
+	}
+
+	comp, _ := NewCompressor(F, nil, 1, 100, 100, 100)
+	input, _ := NewBitVector(F)
+
+	for i, want := range expected {
+		if bit, ok := flips[i]; ok {
+			input.SetBit(bit, 1-input.GetBit(bit))
+		}
+		out, err := comp.CompressPacket(input, &CompressParams{MinRobustness: 1, NewMaskFlag: pt[i]})
+		if err != nil {
+			t.Fatalf("Packet %d: CompressPacket failed: %v", i, err)
+		}
+
+		// ht = RLE(Xt) || BIT4(Vt) || et || kt || ct || dt
+		br := NewBitReader(out)
+		Xt, err := RLEDecode(br, F)
+		if err != nil {
+			t.Fatalf("Packet %d: RLEDecode failed: %v", i, err)
+		}
+		vt, _ := br.ReadBits(4)
+		et, ct := -1, -1
+		if vt > 0 && Xt.HammingWeight() > 0 {
+			et, _ = br.ReadBit()
+			if et == 1 {
+				if err := br.Skip(Xt.HammingWeight()); err != nil {
+					t.Fatalf("Packet %d: skipping kt failed: %v", i, err)
+				}
+				ct, _ = br.ReadBit()
+			}
+		}
+
+		if int(vt) != want.vt || et != want.et || ct != want.ct {
+			t.Errorf("Packet %d: Vt=%d et=%d ct=%d, expected Vt=%d et=%d ct=%d",
+				i, vt, et, ct, want.vt, want.et, want.ct)
+		}
+	}
+}
+
+// TestXtPositionsRoundTrip checks that the positions the compressor writes
+// with RLE(Xt) are the ones the decompressor recovers and applies kt to.
+// F=40 spans two words, so the reverse word scan in RLEEncode is covered.
+func TestXtPositionsRoundTrip(t *testing.T) {
+	F := 40
+	dropped := []int{2, 17, 35} // Unpredictable from packet 2, dropped at 4
+	added := []int{9}           // Unpredictable from packet 4
+
+	vectorOf := func(positions ...int) *BitVector {
+		bv, _ := NewBitVector(F)
+		for _, p := range positions {
+			bv.SetBit(p, 1)
+		}
+		return bv
+	}
+
+	comp, _ := NewCompressor(F, nil, 1, 100, 100, 100)
+	decomp, _ := NewDecompressor(F, nil, 1)
+	input, _ := NewBitVector(F)
+
+	// 0-1: init packets; 2: dropped bits change; 3: new mask keeps them
+	// (they are in the build); 4: new mask from an empty build drops
+	// them while the added bit changes
+	schedule := []CompressParams{
+		{SendMaskFlag: true, UncompressedFlag: true},
+		{SendMaskFlag: true, UncompressedFlag: true},
+		{},
+		{NewMaskFlag: true},
+		{NewMaskFlag: true},
+	}
+
+	for i, params := range schedule {
+		switch i {
+		case 2:
+			for _, p := range dropped {
+				input.SetBit(p, 1)
+			}
+		case 4:
+			for _, p := range added {
+				input.SetBit(p, 1)
+			}
+		}
+
+		params.MinRobustness = 1
+		compressed, err := comp.CompressPacket(input, &params)
+		if err != nil {
+			t.Fatalf("Packet %d: CompressPacket failed: %v", i, err)
+		}
+		output, err := decomp.DecompressPacket(NewBitReader(compressed))
+		if err != nil {
+			t.Fatalf("Packet %d: DecompressPacket failed: %v", i, err)
+		}
+		if !output.Equals(input) {
+			t.Fatalf("Packet %d: output %s, want %s", i, output, input)
+		}
+		if !decomp.mask.Equals(comp.mask) {
+			t.Fatalf("Packet %d: decompressor mask %s, compressor mask %s", i, decomp.mask, comp.mask)
+		}
+	}
+
+	// Xt = D4 OR D3 with D3 empty: every dropped and added position
+	wantXt := vectorOf(append(append([]int{}, dropped...), added...)...)
+	if !comp.workXt.Equals(wantXt) {
+		t.Errorf("Compressor Xt %s, want %s", comp.workXt, wantXt)
+	}
+	if !decomp.workXt.Equals(wantXt) {
+		t.Errorf("Decoded Xt %s, want %s", decomp.workXt, wantXt)
+	}
+
+	// kt=1 (positive update) exactly at the dropped positions
+	if want := vectorOf(dropped...); !decomp.Xt.Equals(want) {
+		t.Errorf("Positive updates %s, want %s", decomp.Xt, want)
+	}
+	if want := vectorOf(added...); !decomp.mask.Equals(want) {
+		t.Errorf("Mask after packet 4 %s, want %s", decomp.mask, want)
+	}
+}
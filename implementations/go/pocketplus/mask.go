@@ -1,5 +1,10 @@
 package pocketplus
 
+import (
+	"errors"
+	"fmt"
+)
+
 // UpdateBuild updates the build vector (CCSDS Equation 6).
 //
 // Build vector accumulates bits that have changed over time.
@@ -63,12 +68,85 @@ func ComputeChange(change, mask, prevMask *BitVector, t int) {
 	}
 }
 
-// ComputeKt computes the kt component for encoding.
+// ComputeKt writes the kt component of ht: one bit per position set in the
+// change vector (Xt in CompressPacket), in forward order (lowest position
+// first), that is '1' for a positive update (the mask bit became 0,
+// predictable) and '0' for a negative one (it became 1).
 //
-// kt = BE(Mt, Dt) - bits of mask at positions where change occurred
-// This tells the decompressor which mask values to expect.
+// kt is therefore the inverted mask at the change positions,
+// BE(NOT Mt, Xt) extracted forward; this is what CompressPacket sends and
+// what the decompressor applies.
 func ComputeKt(bb *BitBuffer, mask, change *BitVector) error {
-	return BitExtractForward(bb, mask, change)
+	if mask == nil {
+		return errors.New("ComputeKt: mask cannot be nil")
+	}
+	inverted, _ := NewBitVector(mask.length)
+	return computeKtInto(bb, mask, change, inverted)
+}
+
+// computeKtInto implements ComputeKt using a caller-provided working
+// vector for the inverted mask.
+func computeKtInto(bb *BitBuffer, mask, change, inverted *BitVector) error {
+	for i := 0; i < mask.numWords; i++ {
+		inverted.data[i] = ^mask.data[i]
+	}
+	inverted.clearPadding()
+	return BitExtractForward(bb, inverted, change)
+}
+
+// EncodeMaskDiff writes the full-mask component RLE(Mt XOR (Mt<<)) used by
+// qt when ft=1 (CCSDS Section 5.3.3).
+func EncodeMaskDiff(bb *BitBuffer, mask *BitVector) error {
+	if mask == nil {
+		return errors.New("EncodeMaskDiff: mask cannot be nil")
+	}
+
+	shifted, _ := NewBitVector(mask.length)
+	diff, _ := NewBitVector(mask.length)
+	return encodeMaskDiffInto(bb, mask, shifted, diff, false)
+}
+
+// encodeMaskDiffInto is EncodeMaskDiff using caller-provided working
+// vectors; on return diff holds Mt XOR (Mt<<). extended selects
+// RLEEncodeExtended.
+func encodeMaskDiffInto(bb *BitBuffer, mask, shifted, diff *BitVector, extended bool) error {
+	leftShiftInto(shifted, mask)
+	diff.XORInto(mask, shifted)
+	return rleEncode(bb, diff, extended)
+}
+
+// DecodeMaskDiff reads RLE(M XOR (M<<)) and returns the F-bit mask M.
+//
+// The horizontal XOR HXOR[i] = M[i] XOR M[i+1] (with HXOR[F-1] = M[F-1]) is
+// reversed from the LSB (position F-1) towards the MSB (position 0):
+// M[F-1] = HXOR[F-1] and M[i] = HXOR[i] XOR M[i+1] for i < F-1.
+func DecodeMaskDiff(br *BitReader, F int) (*BitVector, error) {
+	mask, err := NewBitVector(F)
+	if err != nil {
+		return nil, fmt.Errorf("RLE decode: %w", err)
+	}
+	if err := decodeMaskDiffInto(br, mask, false); err != nil {
+		return nil, err
+	}
+	return mask, nil
+}
+
+// decodeMaskDiffInto implements DecodeMaskDiff, decoding into mask.
+// extended selects RLEDecodeExtended.
+func decodeMaskDiffInto(br *BitReader, mask *BitVector, extended bool) error {
+	if err := rleDecodeInto(br, mask, extended); err != nil {
+		return err
+	}
+
+	// Reverse in place; current carries M[pos+1]
+	F := mask.length
+	current := mask.GetBit(F - 1)
+	for pos := F - 2; pos >= 0; pos-- {
+		current ^= mask.GetBit(pos)
+		mask.SetBit(pos, current)
+	}
+
+	return nil
 }
 
 // ApplyPrediction applies prediction to get predicted value.
@@ -78,7 +156,7 @@ func ComputeKt(bb *BitBuffer, mask, change *BitVector) error {
 //   - If mask bit is 1, predict same as previous
 //   - If mask bit is 0, predict 0
 func ApplyPrediction(prevInput, mask *BitVector) *BitVector {
-	return prevInput.AND(mask)
+	return prevInput.Masked(mask)
 }
 
 // ComputeResidual computes residual (difference from prediction).
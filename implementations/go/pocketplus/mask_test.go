@@ -2,6 +2,8 @@ package pocketplus
 
 import (
 	"bytes"
+	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -220,13 +222,85 @@ func TestComputeKt(t *testing.T) {
 		t.Errorf("ComputeKt error: %v", err)
 	}
 
-	// Kt extracts mask bits at change positions (forward order)
+	// Kt extracts the inverted mask at change positions (forward order)
 	// Change positions: 4, 5, 6, 7
 	// Mask bits at those positions: 1, 0, 1, 0
-	// Result: 1010 = 4 bits
+	// Result: 0101 = 4 bits ('1' = positive update, mask became 0)
 	if bb.NumBits() != 4 {
 		t.Errorf("Expected 4 bits, got %d", bb.NumBits())
 	}
+	if got := bb.ToBytes(); !bytes.Equal(got, []byte{0x50}) {
+		t.Errorf("Expected kt 0101 (0x50), got 0x%02X", got[0])
+	}
+}
+
+// TestComputeKtMatchesWire pins ComputeKt to the kt bits CompressPacket
+// actually sends, read back from the packet via its trace.
+func TestComputeKtMatchesWire(t *testing.T) {
+	F := 40
+	comp, _ := NewCompressor(F, nil, 1, 100, 100, 100)
+	decomp, _ := NewDecompressor(F, nil, 1)
+	input, _ := NewBitVector(F)
+
+	// Bits 2, 17 and 35 become unpredictable, then a new mask from an
+	// empty build drops them (kt=1) while bit 9 changes (kt=0)
+	schedule := []CompressParams{
+		{SendMaskFlag: true, UncompressedFlag: true},
+		{SendMaskFlag: true, UncompressedFlag: true},
+		{},
+		{NewMaskFlag: true},
+		{NewMaskFlag: true},
+	}
+
+	var kt *TraceComponent
+	var compressed []byte
+	for i, params := range schedule {
+		switch i {
+		case 2:
+			input.SetBit(2, 1)
+			input.SetBit(17, 1)
+			input.SetBit(35, 1)
+		case 4:
+			input.SetBit(9, 1)
+		}
+
+		params.MinRobustness = 1
+		var err error
+		compressed, err = comp.CompressPacket(input, &params)
+		if err != nil {
+			t.Fatalf("Packet %d: CompressPacket failed: %v", i, err)
+		}
+		_, trace, err := decomp.DecompressPacketTraced(NewBitReader(compressed))
+		if err != nil {
+			t.Fatalf("Packet %d: decompression failed: %v", i, err)
+		}
+		kt = nil
+		for j := range trace.Components {
+			if trace.Components[j].Name == "kt" {
+				kt = &trace.Components[j]
+			}
+		}
+	}
+	if kt == nil {
+		t.Fatal("Last packet has no kt component")
+	}
+
+	expected := NewBitBuffer()
+	if err := ComputeKt(expected, comp.mask, comp.workXt); err != nil {
+		t.Fatalf("ComputeKt failed: %v", err)
+	}
+
+	// Positions 2, 9, 17, 35 in forward order: dropped, added, dropped, dropped
+	if expected.NumBits() != 4 || !bytes.Equal(expected.ToBytes(), []byte{0xB0}) {
+		t.Errorf("ComputeKt = %x (%d bits), want 1011", expected.ToBytes(), expected.NumBits())
+	}
+
+	reader := NewBitReader(compressed)
+	reader.Skip(kt.Offset)
+	wire, _ := reader.ReadBits(kt.Length)
+	if kt.Length != 4 || wire != 0xB {
+		t.Errorf("kt on the wire = %b (%d bits), want 1011", wire, kt.Length)
+	}
 }
 
 func TestMaskUpdateSequence(t *testing.T) {
@@ -272,3 +346,110 @@ func TestMaskUpdateSequence(t *testing.T) {
 		t.Errorf("t=1 no change: expected 0x00, got %v", change.ToBytes())
 	}
 }
+
+func TestEncodeMaskDiff(t *testing.T) {
+	// M = 00110000: HXOR = M XOR (M<<) = 00110000 XOR 01100000 = 01010000
+	mask, _ := NewBitVector(8)
+	mask.FromBytes([]byte{0x30})
+
+	bb := NewBitBuffer()
+	if err := EncodeMaskDiff(bb, mask); err != nil {
+		t.Fatalf("EncodeMaskDiff error: %v", err)
+	}
+
+	// Must equal RLE of the horizontal XOR
+	hxor, _ := NewBitVector(8)
+	hxor.FromBytes([]byte{0x50})
+	expected := NewBitBuffer()
+	RLEEncode(expected, hxor)
+
+	if bb.NumBits() != expected.NumBits() || !bytes.Equal(bb.ToBytes(), expected.ToBytes()) {
+		t.Errorf("Expected RLE(HXOR) %v, got %v", expected.ToBytes(), bb.ToBytes())
+	}
+
+	if err := EncodeMaskDiff(bb, nil); err == nil {
+		t.Error("Expected error for nil mask")
+	}
+}
+
+func TestDecodeMaskDiff(t *testing.T) {
+	mask, _ := NewBitVector(8)
+	mask.FromBytes([]byte{0x30})
+
+	bb := NewBitBuffer()
+	EncodeMaskDiff(bb, mask)
+
+	br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+	decoded, err := DecodeMaskDiff(br, 8)
+	if err != nil {
+		t.Fatalf("DecodeMaskDiff error: %v", err)
+	}
+	if !decoded.Equals(mask) {
+		t.Errorf("Expected %v, got %v", mask.ToBytes(), decoded.ToBytes())
+	}
+	if br.Remaining() != 0 {
+		t.Errorf("Expected all bits consumed, %d remaining", br.Remaining())
+	}
+}
+
+func TestDecodeMaskDiffTruncated(t *testing.T) {
+	// '0' (COUNT 1) with no terminator
+	br := NewBitReaderWithBits([]byte{0x00}, 1)
+	if _, err := DecodeMaskDiff(br, 8); err == nil {
+		t.Error("Expected error for truncated mask")
+	}
+}
+
+func TestMaskDiffRoundTripPatterns(t *testing.T) {
+	rng := rand.New(rand.NewSource(124))
+
+	for _, F := range []int{8, 33, 720} {
+		patterns := map[string]func(i int) int{
+			"zeros":       func(i int) int { return 0 },
+			"ones":        func(i int) int { return 1 },
+			"msb":         func(i int) int { return boolToBit(i == 0) },
+			"lsb":         func(i int) int { return boolToBit(i == F-1) },
+			"alternating": func(i int) int { return (i + 1) & 1 },
+			"inverse alt": func(i int) int { return i & 1 },
+		}
+		for trial := 0; trial < 50; trial++ {
+			bits := make([]int, F)
+			for i := range bits {
+				bits[i] = rng.Intn(2)
+			}
+			patterns[fmt.Sprintf("random %d", trial)] = func(i int) int { return bits[i] }
+		}
+
+		for name, bit := range patterns {
+			mask, _ := NewBitVector(F)
+			for i := 0; i < F; i++ {
+				mask.SetBit(i, bit(i))
+			}
+
+			bb := NewBitBuffer()
+			if err := EncodeMaskDiff(bb, mask); err != nil {
+				t.Fatalf("F=%d %s: EncodeMaskDiff error: %v", F, name, err)
+			}
+
+			br := NewBitReaderWithBits(bb.ToBytes(), bb.NumBits())
+			decoded, err := DecodeMaskDiff(br, F)
+			if err != nil {
+				t.Fatalf("F=%d %s: DecodeMaskDiff error: %v", F, name, err)
+			}
+			if !decoded.Equals(mask) {
+				t.Errorf("F=%d %s: round-trip mismatch: expected %X, got %X",
+					F, name, mask.ToBytes(), decoded.ToBytes())
+			}
+			if br.Remaining() != 0 {
+				t.Errorf("F=%d %s: %d bits left unread", F, name, br.Remaining())
+			}
+		}
+	}
+}
+
+func boolToBit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
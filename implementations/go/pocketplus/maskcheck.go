@@ -0,0 +1,75 @@
+package pocketplus
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// ErrMaskDesync is returned when a mask check embedded by the compressor
+// does not match the decompressor's mask, i.e. the decoder lost track of
+// the mask (e.g. after an error burst longer than the robustness window).
+var ErrMaskDesync = errors.New("mask desynchronized")
+
+// maskCheckBits is the length of the embedded mask hash.
+const maskCheckBits = 16
+
+// SetMaskCheck enables a NON-STANDARD integrity extension: every packet
+// ends with a flag bit, and every interval-th packet (starting with the
+// first) sets it and appends a 16-bit hash of the updated mask Mt. An
+// interval of 0 disables the extension.
+//
+// The cost is one bit per packet plus 16 bits per check. Output is only
+// decodable by a Decompressor with SetMaskCheck(true); such streams are not
+// CCSDS compliant.
+func (comp *Compressor) SetMaskCheck(interval int) error {
+	if interval < 0 {
+		return errors.New("mask check interval must not be negative")
+	}
+	comp.maskCheckInterval = interval
+	return nil
+}
+
+// SetMaskCheck enables decoding of the mask check extension produced with
+// Compressor.SetMaskCheck. When a check fails, decompression returns an
+// error wrapping ErrMaskDesync that names the packet index.
+func (decomp *Decompressor) SetMaskCheck(enabled bool) {
+	decomp.maskCheck = enabled
+}
+
+// maskHash returns the 16-bit mask hash carried by the mask check.
+func maskHash(mask *BitVector) uint64 {
+	return uint64(crc32.ChecksumIEEE(mask.ToBytes()) & 0xFFFF)
+}
+
+// encodeMaskCheck appends the mask check flag and, when due, the hash.
+func (comp *Compressor) encodeMaskCheck(output *BitBuffer) {
+	if comp.t%comp.maskCheckInterval != 0 {
+		output.AppendBit(0)
+		return
+	}
+	output.AppendBit(1)
+	output.AppendValue(maskHash(comp.mask), maskCheckBits)
+}
+
+// decodeMaskCheck reads the mask check flag and verifies the hash if one
+// is present.
+func (decomp *Decompressor) decodeMaskCheck(reader *BitReader) error {
+	start := reader.Position()
+	flag, err := reader.ReadBit()
+	if err != nil {
+		return fmt.Errorf("failed to read mask check flag at bit %d: %w", start, err)
+	}
+	if flag == 0 {
+		return nil
+	}
+
+	hash, err := reader.ReadBits(maskCheckBits)
+	if err != nil {
+		return fmt.Errorf("failed to read mask check at bit %d: %w", start, err)
+	}
+	if hash != maskHash(decomp.mask) {
+		return fmt.Errorf("%w at packet %d", ErrMaskDesync, decomp.t)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package pocketplus
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// compressWithMaskCheck compresses data as a stream with the mask check
+// extension enabled.
+func compressWithMaskCheck(t *testing.T, data []byte, packetSize, interval int) []byte {
+	t.Helper()
+	comp, _ := newStreamCompressor(packetSize, 1, 10, 20, 50)
+	if err := comp.SetMaskCheck(interval); err != nil {
+		t.Fatalf("SetMaskCheck failed: %v", err)
+	}
+	out, err := comp.compressAppend(nil, data, packetSize)
+	if err != nil {
+		t.Fatalf("Compression failed: %v", err)
+	}
+	return out
+}
+
+func TestMaskCheckRoundTrip(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(100, packetSize)
+	plain, _ := Compress(data, packetSize, 1, 10, 20, 50)
+
+	for _, interval := range []int{1, 5, 50} {
+		compressed := compressWithMaskCheck(t, data, packetSize, interval)
+		if len(compressed) <= len(plain) {
+			t.Errorf("Interval %d: expected mask checks to add bits", interval)
+		}
+
+		decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+		decomp.SetMaskCheck(true)
+		packets, err := decomp.DecompressStream(compressed, len(compressed)*8)
+		if err != nil {
+			t.Fatalf("Interval %d: DecompressStream failed: %v", interval, err)
+		}
+		if !bytes.Equal(bytes.Join(packets, nil), data) {
+			t.Errorf("Interval %d: round-trip mismatch", interval)
+		}
+	}
+}
+
+func TestMaskCheckDetectsDesync(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(40, packetSize)
+	compressed := compressWithMaskCheck(t, data, packetSize, 1)
+
+	// Decode the first 10 packets, then corrupt the decoder's mask the way
+	// a lost update would
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	decomp.SetMaskCheck(true)
+	reader := NewBitReader(compressed)
+	for i := 0; i < 10; i++ {
+		if _, err := decomp.DecompressPacket(reader); err != nil {
+			t.Fatalf("Packet %d: %v", i, err)
+		}
+		reader.AlignByte()
+	}
+	decomp.mask.SetBit(3, decomp.mask.GetBit(3)^1)
+
+	_, err := decomp.DecompressPacket(reader)
+	if !errors.Is(err, ErrMaskDesync) {
+		t.Fatalf("Expected ErrMaskDesync, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "packet 10") {
+		t.Errorf("Expected packet index in error, got %q", err.Error())
+	}
+}
+
+func TestMaskCheckTrace(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(3, packetSize)
+	compressed := compressWithMaskCheck(t, data, packetSize, 1)
+
+	decomp, _ := NewDecompressor(packetSize*8, nil, 1)
+	decomp.SetMaskCheck(true)
+	_, trace, err := decomp.DecompressPacketTraced(NewBitReader(compressed))
+	if err != nil {
+		t.Fatalf("DecompressPacketTraced failed: %v", err)
+	}
+
+	last := trace.Components[len(trace.Components)-1]
+	if last.Name != "mask check" || last.Length != 1+maskCheckBits {
+		t.Errorf("Unexpected last component %+v", last)
+	}
+	if last.Offset+last.Length != trace.Length {
+		t.Errorf("Mask check ends at %d, packet length %d", last.Offset+last.Length, trace.Length)
+	}
+}
+
+func TestSetMaskCheckInvalid(t *testing.T) {
+	comp, _ := NewCompressor(64, nil, 1, 10, 20, 50)
+	if err := comp.SetMaskCheck(-1); err == nil {
+		t.Error("Expected error for negative interval")
+	}
+}
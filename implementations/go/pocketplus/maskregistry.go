@@ -0,0 +1,58 @@
+package pocketplus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// initialMasks holds the masks registered with RegisterInitialMask.
+var initialMasks = struct {
+	sync.RWMutex
+	byName map[string]*BitVector
+}{byName: make(map[string]*BitVector)}
+
+// RegisterInitialMask stores a copy of mask under name, replacing any mask
+// already registered with that name, so streams that share an initial mask
+// (e.g. identical spacecraft) can refer to it with NewCompressorNamed.
+//
+// Registration is meant to happen once at startup, before compressors are
+// created. It is safe for concurrent use, but re-registering a name while
+// streams are running gives later streams a different mask from earlier
+// ones, which their decompressors must then match.
+func RegisterInitialMask(name string, mask *BitVector) error {
+	if mask == nil {
+		return errors.New("RegisterInitialMask: mask is nil")
+	}
+
+	initialMasks.Lock()
+	defer initialMasks.Unlock()
+	initialMasks.byName[name] = mask.Copy()
+	return nil
+}
+
+// InitialMask returns a copy of the mask registered under name, and whether
+// one was found.
+func InitialMask(name string) (*BitVector, bool) {
+	initialMasks.RLock()
+	defer initialMasks.RUnlock()
+
+	mask, ok := initialMasks.byName[name]
+	if !ok {
+		return nil, false
+	}
+	return mask.Copy(), true
+}
+
+// NewCompressorNamed is NewCompressor with the initial mask looked up by
+// name in the registry. The mask must be F bits long.
+func NewCompressorNamed(F, robustness, ptLimit, ftLimit, rtLimit int, maskName string) (*Compressor, error) {
+	mask, ok := InitialMask(maskName)
+	if !ok {
+		return nil, fmt.Errorf("no initial mask registered as %q", maskName)
+	}
+	if mask.Length() != F {
+		return nil, fmt.Errorf("initial mask %q has %d bits, expected %d", maskName, mask.Length(), F)
+	}
+	return NewCompressor(F, mask, robustness, ptLimit, ftLimit, rtLimit)
+}
@@ -0,0 +1,103 @@
+package pocketplus
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestInitialMaskRegistry(t *testing.T) {
+	F := 64
+	mask, _ := NewBitVector(F)
+	mask.FromBytes([]byte{0x00, 0xFF, 0x00, 0x0F, 0x00, 0x00, 0xF0, 0x01})
+
+	if err := RegisterInitialMask("test-registry", mask); err != nil {
+		t.Fatalf("RegisterInitialMask failed: %v", err)
+	}
+
+	// The registry keeps its own copy
+	mask.SetBit(0, 1)
+	got, ok := InitialMask("test-registry")
+	if !ok {
+		t.Fatal("InitialMask: registered mask not found")
+	}
+	if got.GetBit(0) != 0 {
+		t.Error("InitialMask: registry aliased the caller's vector")
+	}
+	got.SetBit(1, 1)
+	if again, _ := InitialMask("test-registry"); again.GetBit(1) != 0 {
+		t.Error("InitialMask: returned vector aliases the registry")
+	}
+
+	if _, ok := InitialMask("test-registry-missing"); ok {
+		t.Error("InitialMask: found an unregistered name")
+	}
+	if err := RegisterInitialMask("test-registry-nil", nil); err == nil {
+		t.Error("RegisterInitialMask: expected error for nil mask")
+	}
+}
+
+func TestNewCompressorNamed(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	data := generateTestPackets(20, packetSize)
+
+	mask, _ := NewBitVector(F)
+	mask.FromBytes([]byte{0x00, 0xFF, 0x00, 0x0F, 0x00, 0x00, 0xF0, 0x01})
+	if err := RegisterInitialMask("test-named", mask); err != nil {
+		t.Fatalf("RegisterInitialMask failed: %v", err)
+	}
+
+	compressAll := func(comp *Compressor) []byte {
+		input, _ := NewBitVector(F)
+		var out []byte
+		for i := 0; i < 20; i++ {
+			input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+			compressed, err := comp.CompressPacket(input, comp.scheduleParams(i))
+			if err != nil {
+				t.Fatalf("CompressPacket failed: %v", err)
+			}
+			out = append(out, compressed...)
+		}
+		return out
+	}
+
+	named, err := NewCompressorNamed(F, 2, 10, 20, 50, "test-named")
+	if err != nil {
+		t.Fatalf("NewCompressorNamed failed: %v", err)
+	}
+	direct, _ := NewCompressor(F, mask, 2, 10, 20, 50)
+	if !bytes.Equal(compressAll(named), compressAll(direct)) {
+		t.Error("NewCompressorNamed output differs from NewCompressor with the same mask")
+	}
+
+	if _, err := NewCompressorNamed(F, 2, 10, 20, 50, "test-named-missing"); err == nil {
+		t.Error("NewCompressorNamed: expected error for unknown name")
+	}
+	if _, err := NewCompressorNamed(F+8, 2, 10, 20, 50, "test-named"); err == nil {
+		t.Error("NewCompressorNamed: expected error for mask length mismatch")
+	}
+}
+
+func TestInitialMaskRegistryConcurrent(t *testing.T) {
+	mask, _ := NewBitVector(32)
+	mask.SetAll()
+	if err := RegisterInitialMask("test-concurrent", mask); err != nil {
+		t.Fatalf("RegisterInitialMask failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got, ok := InitialMask("test-concurrent"); !ok || !got.Equals(mask) {
+					t.Error("InitialMask: concurrent read returned wrong mask")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
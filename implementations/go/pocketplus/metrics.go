@@ -0,0 +1,70 @@
+package pocketplus
+
+// MetricsSink receives per-packet compression metrics.
+//
+// It lets callers feed Prometheus, OpenTelemetry or any other metrics
+// system without this package depending on it. Implementations must be
+// cheap; they are called synchronously once per packet.
+type MetricsSink interface {
+	// ObservePacket reports one packet. inputBits is the uncompressed
+	// packet length F and outputBits the compressed length (before byte
+	// alignment). uncompressed reports whether the packet carried the full
+	// input (rt=1).
+	ObservePacket(inputBits, outputBits int, uncompressed bool)
+}
+
+// SetMetrics installs a metrics sink called after each compressed packet.
+// A nil sink disables reporting.
+func (comp *Compressor) SetMetrics(sink MetricsSink) {
+	comp.metrics = sink
+}
+
+// SetMetrics installs a metrics sink called after each decompressed packet.
+// A nil sink disables reporting.
+func (decomp *Decompressor) SetMetrics(sink MetricsSink) {
+	decomp.metrics = sink
+}
+
+// StreamStats accumulates totals over all packets compressed since the
+// last Reset. Packet types follow the ft/rt flags: plain (neither), mask
+// resend (ft only), uncompressed (rt only) and sync (both, as at a stream
+// start or from EmitSync).
+type StreamStats struct {
+	Packets    int
+	InputBits  int64 // Sum of F over all packets
+	OutputBits int64 // Compressed bits, including byte-alignment padding
+
+	Plain        int
+	MaskResend   int
+	Uncompressed int
+	Sync         int
+
+	Vt [16]int // Number of packets sent with each effective robustness Vt
+}
+
+// observe adds one compressed packet to the totals.
+func (s *StreamStats) observe(inputBits, outputBits int, params *CompressParams, Vt int) {
+	s.Packets++
+	s.InputBits += int64(inputBits)
+	s.OutputBits += int64(outputBits)
+
+	switch {
+	case params.SendMaskFlag && params.UncompressedFlag:
+		s.Sync++
+	case params.UncompressedFlag:
+		s.Uncompressed++
+	case params.SendMaskFlag:
+		s.MaskResend++
+	default:
+		s.Plain++
+	}
+
+	s.Vt[Vt]++
+}
+
+// Stats returns the totals accumulated over the packets compressed since
+// the compressor was created or last Reset. SizeOfNext dry runs are not
+// counted.
+func (comp *Compressor) Stats() StreamStats {
+	return comp.stats
+}
@@ -0,0 +1,167 @@
+package pocketplus
+
+import "testing"
+
+// countingSink records the packets reported to it.
+type countingSink struct {
+	packets      int
+	uncompressed int
+	inputBits    int
+	outputBits   int
+}
+
+func (s *countingSink) ObservePacket(inputBits, outputBits int, uncompressed bool) {
+	s.packets++
+	s.inputBits += inputBits
+	s.outputBits += outputBits
+	if uncompressed {
+		s.uncompressed++
+	}
+}
+
+func TestMetricsSink(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	numPackets := 10
+	data := generateTestPackets(numPackets, packetSize)
+
+	comp, _ := NewCompressor(F, nil, 1, 10, 20, 50)
+	compSink := &countingSink{}
+	comp.SetMetrics(compSink)
+
+	var compressed []byte
+	for i := 0; i < numPackets; i++ {
+		input, _ := NewBitVector(F)
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+
+		params := &CompressParams{UncompressedFlag: i == 0, SendMaskFlag: i == 0}
+		out, err := comp.CompressPacket(input, params)
+		if err != nil {
+			t.Fatalf("CompressPacket %d failed: %v", i, err)
+		}
+		compressed = append(compressed, out...)
+	}
+
+	if compSink.packets != numPackets {
+		t.Errorf("Expected %d compressor observations, got %d", numPackets, compSink.packets)
+	}
+	if compSink.uncompressed != 1 {
+		t.Errorf("Expected 1 uncompressed packet, got %d", compSink.uncompressed)
+	}
+	if compSink.inputBits != numPackets*F {
+		t.Errorf("Expected %d input bits, got %d", numPackets*F, compSink.inputBits)
+	}
+
+	decomp, _ := NewDecompressor(F, nil, 1)
+	decompSink := &countingSink{}
+	decomp.SetMetrics(decompSink)
+
+	if _, err := decomp.DecompressStream(compressed, len(compressed)*8); err != nil {
+		t.Fatalf("DecompressStream failed: %v", err)
+	}
+
+	if decompSink.packets != numPackets {
+		t.Errorf("Expected %d decompressor observations, got %d", numPackets, decompSink.packets)
+	}
+	if decompSink.uncompressed != 1 {
+		t.Errorf("Expected 1 uncompressed packet, got %d", decompSink.uncompressed)
+	}
+	// Both sides must agree on the unaligned compressed size
+	if decompSink.outputBits != compSink.outputBits {
+		t.Errorf("Compressed bits mismatch: compressor %d, decompressor %d",
+			compSink.outputBits, decompSink.outputBits)
+	}
+}
+
+func TestMetricsSinkNil(t *testing.T) {
+	comp, _ := NewCompressor(64, nil, 1, 10, 20, 50)
+	comp.SetMetrics(nil)
+
+	input, _ := NewBitVector(64)
+	if _, err := comp.CompressPacket(input, nil); err != nil {
+		t.Errorf("CompressPacket with nil sink failed: %v", err)
+	}
+}
+
+func TestCompressorStats(t *testing.T) {
+	packetSize := 8
+	F := packetSize * 8
+	data := generateTestPackets(30, packetSize)
+
+	comp, _ := NewCompressor(F, nil, 2, 10, 20, 50)
+	input, _ := NewBitVector(F)
+	outBits := 0
+	for i := 0; i < 30; i++ {
+		input.FromBytes(data[i*packetSize : (i+1)*packetSize])
+		params := comp.scheduleParams(i)
+		switch i {
+		case 12:
+			params.SendMaskFlag, params.UncompressedFlag = true, false
+		case 13:
+			params.SendMaskFlag, params.UncompressedFlag = false, true
+		}
+		out, err := comp.CompressPacket(input, params)
+		if err != nil {
+			t.Fatalf("CompressPacket %d failed: %v", i, err)
+		}
+		outBits += len(out) * 8
+	}
+
+	// A dry run is not counted
+	if _, err := comp.SizeOfNext(input, nil); err != nil {
+		t.Fatalf("SizeOfNext failed: %v", err)
+	}
+
+	stats := comp.Stats()
+	if stats.Packets != 30 || stats.InputBits != int64(30*F) || stats.OutputBits != int64(outBits) {
+		t.Errorf("Totals: got %d packets, %d in, %d out; want 30, %d, %d",
+			stats.Packets, stats.InputBits, stats.OutputBits, 30*F, outBits)
+	}
+
+	// Packets 0-2 are init syncs, 12 and 20 (ft) resend the mask, 13 is
+	// uncompressed; rt=50 does not fire within 30 packets
+	if stats.Sync != 3 || stats.MaskResend != 2 || stats.Uncompressed != 1 || stats.Plain != 24 {
+		t.Errorf("Packet types: got sync=%d mask=%d uncompressed=%d plain=%d, want 3, 2, 1, 24",
+			stats.Sync, stats.MaskResend, stats.Uncompressed, stats.Plain)
+	}
+
+	vtTotal := 0
+	for vt, n := range stats.Vt {
+		if vt < 2 && n != 0 {
+			t.Errorf("Vt=%d counted %d times below robustness 2", vt, n)
+		}
+		vtTotal += n
+	}
+	if vtTotal != 30 || stats.Vt[2] < 3 {
+		t.Errorf("Vt histogram %v: want 30 packets and at least the 3 init packets at Vt=2", stats.Vt)
+	}
+
+	comp.Reset()
+	if comp.Stats() != (StreamStats{}) {
+		t.Errorf("Stats after Reset: got %+v", comp.Stats())
+	}
+}
+
+func TestCompressReportStats(t *testing.T) {
+	packetSize := 8
+	data := generateTestPackets(60, packetSize)
+
+	compressed, report, err := CompressWithReport(data, packetSize, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressWithReport failed: %v", err)
+	}
+
+	stats := report.Stats
+	if stats.Packets != 60 || stats.OutputBits != int64(len(compressed)*8) {
+		t.Errorf("Got %d packets and %d output bits, want 60 and %d",
+			stats.Packets, stats.OutputBits, len(compressed)*8)
+	}
+	if stats.Sync+stats.Uncompressed != len(report.Uncompressed) {
+		t.Errorf("Sync+Uncompressed = %d, report lists %d uncompressed packets",
+			stats.Sync+stats.Uncompressed, len(report.Uncompressed))
+	}
+	if stats.Sync+stats.MaskResend != len(report.MaskResends) {
+		t.Errorf("Sync+MaskResend = %d, report lists %d mask resends",
+			stats.Sync+stats.MaskResend, len(report.MaskResends))
+	}
+}
@@ -0,0 +1,56 @@
+package pocketplus
+
+import "errors"
+
+// Session bundles the parameters of a POCKET+ link, so that data is always
+// decompressed with the packet size and robustness it was compressed with.
+//
+// Each Compress call produces an independent stream; a Session holds no
+// compression state and is safe for concurrent use.
+type Session struct {
+	packetSize int
+	robustness int
+	ptLimit    int
+	ftLimit    int
+	rtLimit    int
+}
+
+// NewSession creates a session with the given packet size in bytes,
+// robustness (1-7) and pt/ft/rt period limits.
+func NewSession(packetSize, robustness, ptLimit, ftLimit, rtLimit int) (*Session, error) {
+	if err := validatePacketSize(packetSize); err != nil {
+		return nil, err
+	}
+	if robustness < 1 || robustness > 7 {
+		return nil, errors.New("robustness must be between 1 and 7")
+	}
+
+	return &Session{
+		packetSize: packetSize,
+		robustness: robustness,
+		ptLimit:    ptLimit,
+		ftLimit:    ftLimit,
+		rtLimit:    rtLimit,
+	}, nil
+}
+
+// PacketSize returns the session's packet size in bytes.
+func (s *Session) PacketSize() int {
+	return s.packetSize
+}
+
+// Robustness returns the session's robustness level.
+func (s *Session) Robustness() int {
+	return s.robustness
+}
+
+// Compress compresses data with the session parameters, like Compress.
+func (s *Session) Compress(data []byte) ([]byte, error) {
+	return Compress(data, s.packetSize, s.robustness, s.ptLimit, s.ftLimit, s.rtLimit)
+}
+
+// Decompress decompresses data with the session parameters, like
+// Decompress.
+func (s *Session) Decompress(data []byte) ([]byte, error) {
+	return Decompress(data, s.packetSize, s.robustness)
+}
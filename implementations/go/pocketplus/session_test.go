@@ -0,0 +1,47 @@
+package pocketplus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	session, err := NewSession(90, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if session.PacketSize() != 90 || session.Robustness() != 2 {
+		t.Errorf("Unexpected parameters %d/%d", session.PacketSize(), session.Robustness())
+	}
+
+	data := generateTestPackets(30, 90)
+	compressed, err := session.Compress(data)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	expected, _ := Compress(data, 90, 2, 10, 20, 50)
+	if !bytes.Equal(compressed, expected) {
+		t.Error("Session output differs from Compress")
+	}
+
+	decompressed, err := session.Decompress(compressed)
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Round-trip mismatch")
+	}
+}
+
+func TestNewSessionInvalid(t *testing.T) {
+	if _, err := NewSession(0, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for packet size 0")
+	}
+	if _, err := NewSession(90, 0, 10, 20, 50); err == nil {
+		t.Error("Expected error for robustness 0")
+	}
+	if _, err := NewSession(90, 8, 10, 20, 50); err == nil {
+		t.Error("Expected error for robustness 8")
+	}
+}
@@ -0,0 +1,247 @@
+package pocketplus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// stateVersion identifies the compressor state serialization format.
+const stateVersion = 1
+
+// Bits of the flags byte of the state. Unknown bits are rejected.
+const (
+	stateExtendedCount = 1 << iota
+	stateFixedMask
+	stateMaskResend
+)
+
+// ErrInvalidState is returned when serialized compressor state is malformed.
+var ErrInvalidState = errors.New("invalid compressor state")
+
+// MarshalState serializes the compressor state so that compression can be
+// resumed later with UnmarshalCompressorState.
+//
+// The state covers configuration (including the pad bit and the mask
+// check, extended COUNT and fixed mask settings, which change the
+// bitstream), masks, change and flag history, the cycle counter, the
+// pt/ft/rt countdown counters and a pending RequestMaskResend. Metrics
+// sinks are not saved.
+func (comp *Compressor) MarshalState() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(stateVersion)
+	for _, v := range []int{
+		comp.F, comp.robustness, comp.ptLimit, comp.ftLimit, comp.rtLimit,
+		comp.t, comp.historyIndex, comp.zeroRunIndex, comp.flagHistoryIndex,
+		comp.ptCounter, comp.ftCounter, comp.rtCounter,
+	} {
+		binary.Write(&buf, binary.BigEndian, int64(v))
+	}
+	if comp.saturated {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	for _, v := range comp.zeroRunHistory {
+		buf.WriteByte(byte(v))
+	}
+	for _, v := range comp.newMaskFlagHistory {
+		buf.WriteByte(byte(v))
+	}
+	buf.WriteByte(byte(comp.padBit))
+
+	binary.Write(&buf, binary.BigEndian, int64(comp.maskCheckInterval))
+	var flags byte
+	if comp.extendedCount {
+		flags |= stateExtendedCount
+	}
+	if comp.fixedMask {
+		flags |= stateFixedMask
+	}
+	if comp.maskResend {
+		flags |= stateMaskResend
+	}
+	buf.WriteByte(flags)
+
+	for _, bv := range comp.stateVectors() {
+		buf.Write(bv.ToBytes())
+	}
+
+	return buf.Bytes()
+}
+
+// UnmarshalCompressorState restores a compressor from state produced by
+// MarshalState. Compressing further packets with the restored compressor
+// produces the same output as the original would have.
+func UnmarshalCompressorState(state []byte) (*Compressor, error) {
+	r := bytes.NewReader(state)
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	if version != stateVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidState, version)
+	}
+
+	var fields [12]int64
+	if err := binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	comp, err := NewCompressor(int(fields[0]), nil, int(fields[1]),
+		int(fields[2]), int(fields[3]), int(fields[4]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+
+	comp.t = int(fields[5])
+	comp.historyIndex = int(fields[6])
+	comp.zeroRunIndex = int(fields[7])
+	comp.flagHistoryIndex = int(fields[8])
+	comp.ptCounter = int(fields[9])
+	comp.ftCounter = int(fields[10])
+	comp.rtCounter = int(fields[11])
+
+	if comp.t < 0 ||
+		comp.historyIndex < 0 || comp.historyIndex >= len(comp.changeHistory) ||
+		comp.zeroRunIndex < 0 || comp.zeroRunIndex >= MaxHistory ||
+		comp.flagHistoryIndex < 0 || comp.flagHistoryIndex >= MaxVtHistory {
+		return nil, fmt.Errorf("%w: history index out of range", ErrInvalidState)
+	}
+
+	var small [1 + MaxHistory + MaxVtHistory]byte
+	if _, err := io.ReadFull(r, small[:]); err != nil {
+		return nil, ErrInvalidState
+	}
+	comp.saturated = small[0] != 0
+	for i := 0; i < MaxHistory; i++ {
+		comp.zeroRunHistory[i] = int(small[1+i])
+		if comp.zeroRunHistory[i] > MaxHistory {
+			return nil, fmt.Errorf("%w: zero run length out of range", ErrInvalidState)
+		}
+	}
+	for i := 0; i < MaxVtHistory; i++ {
+		comp.newMaskFlagHistory[i] = int(small[1+MaxHistory+i])
+		if comp.newMaskFlagHistory[i] > 1 {
+			return nil, fmt.Errorf("%w: invalid new mask flag", ErrInvalidState)
+		}
+	}
+
+	padBit, err := r.ReadByte()
+	if err != nil || padBit > 1 {
+		return nil, fmt.Errorf("%w: invalid pad bit", ErrInvalidState)
+	}
+	comp.padBit = int(padBit)
+
+	var interval int64
+	if err := binary.Read(r, binary.BigEndian, &interval); err != nil || interval < 0 {
+		return nil, fmt.Errorf("%w: invalid mask check interval", ErrInvalidState)
+	}
+	flags, err := r.ReadByte()
+	if err != nil || flags&^(stateExtendedCount|stateFixedMask|stateMaskResend) != 0 {
+		return nil, fmt.Errorf("%w: invalid extension flags", ErrInvalidState)
+	}
+	comp.maskCheckInterval = int(interval)
+	comp.extendedCount = flags&stateExtendedCount != 0
+	comp.fixedMask = flags&stateFixedMask != 0
+	comp.maskResend = flags&stateMaskResend != 0
+
+	vectors := comp.stateVectors()
+	numBytes := (comp.F + 7) / 8
+	if r.Len() != len(vectors)*numBytes {
+		return nil, fmt.Errorf("%w: expected %d bytes of vector data, got %d",
+			ErrInvalidState, len(vectors)*numBytes, r.Len())
+	}
+	vecData := make([]byte, numBytes)
+	for _, bv := range vectors {
+		r.Read(vecData)
+		bv.FromBytes(vecData)
+	}
+
+	return comp, nil
+}
+
+// stateVectors returns the bit vectors that make up the compressor state,
+// in serialization order.
+func (comp *Compressor) stateVectors() []*BitVector {
+	vectors := []*BitVector{
+		comp.mask, comp.prevMask, comp.build, comp.prevInput, comp.initialMask,
+	}
+	return append(vectors, comp.changeHistory...)
+}
+
+// CompressWithState compresses data like Compress and also returns the
+// final compressor state, so the stream can later be continued with
+// AppendCompress.
+func CompressWithState(data []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int) ([]byte, []byte, error) {
+	comp, err := newStreamCompressor(packetSize, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	compressed, err := comp.compressAppend(nil, data, packetSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return compressed, comp.MarshalState(), nil
+}
+
+// AppendCompress continues a compressed stream with new packets.
+//
+// The compressor is restored from state (as returned by CompressWithState
+// or Compressor.MarshalState), newData is compressed and the result is
+// appended to existing. Every compressed packet is byte-aligned, so the
+// returned stream is identical to compressing the old and new input in
+// one go and decompresses to the full input.
+//
+// The restored compressor is already warm: the first R+1 packets of a new
+// stream are forced uncompressed, but appended packets continue the
+// restored schedule and are compressed against the saved mask instead. The
+// decoder needs no special handling, since it decodes the combined stream
+// from its start.
+//
+// To keep appending, restore the compressor with UnmarshalCompressorState,
+// compress with it and save its state with MarshalState.
+func AppendCompress(existing, state, newData []byte, packetSize int) ([]byte, error) {
+	comp, err := UnmarshalCompressorState(state)
+	if err != nil {
+		return nil, err
+	}
+	if comp.F != packetSize*8 {
+		return nil, fmt.Errorf("packet size %d does not match state packet size %d",
+			packetSize, comp.F/8)
+	}
+
+	out := make([]byte, len(existing), len(existing)+len(newData))
+	copy(out, existing)
+	return comp.compressAppend(out, newData, packetSize)
+}
+
+// compressAppend compresses whole packets from data, continuing the
+// compressor's parameter schedule, and appends the output to dst.
+func (comp *Compressor) compressAppend(dst, data []byte, packetSize int) ([]byte, error) {
+	if len(data)%packetSize != 0 {
+		return nil, errors.New("data length must be multiple of packet size")
+	}
+
+	input, err := NewBitVector(comp.F)
+	if err != nil {
+		return nil, err
+	}
+
+	for off := 0; off < len(data); off += packetSize {
+		input.FromBytes(data[off : off+packetSize])
+
+		compressed, err := comp.CompressPacket(input, comp.scheduleParams(comp.t))
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, compressed...)
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,209 @@
+package pocketplus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAppendCompress(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(120, packetSize)
+
+	full, err := Compress(data, packetSize, 2, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	// Split at several points, including inside a pt/ft period
+	for _, split := range []int{1, 7, 60, 119} {
+		first, state, err := CompressWithState(data[:split*packetSize], packetSize, 2, 10, 20, 50)
+		if err != nil {
+			t.Fatalf("split %d: CompressWithState failed: %v", split, err)
+		}
+
+		combined, err := AppendCompress(first, state, data[split*packetSize:], packetSize)
+		if err != nil {
+			t.Fatalf("split %d: AppendCompress failed: %v", split, err)
+		}
+
+		if !bytes.Equal(combined, full) {
+			t.Errorf("split %d: appended stream differs from one-shot compression", split)
+		}
+
+		decompressed, err := Decompress(combined, packetSize, 2)
+		if err != nil {
+			t.Fatalf("split %d: Decompress failed: %v", split, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Errorf("split %d: round-trip mismatch", split)
+		}
+	}
+}
+
+func TestAppendCompressPacketSizeMismatch(t *testing.T) {
+	_, state, err := CompressWithState(make([]byte, 32), 16, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressWithState failed: %v", err)
+	}
+
+	if _, err := AppendCompress(nil, state, make([]byte, 8), 8); err == nil {
+		t.Error("Expected error for mismatched packet size")
+	}
+}
+
+func TestUnmarshalCompressorStateInvalid(t *testing.T) {
+	_, state, err := CompressWithState(make([]byte, 32), 16, 1, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressWithState failed: %v", err)
+	}
+
+	// Offsets of the first zero run length and new mask flag
+	zeroRun := 1 + 12*8 + 1
+	flag := zeroRun + MaxHistory
+	patched := func(off int, v byte) []byte {
+		s := append([]byte{}, state...)
+		s[off] = v
+		return s
+	}
+
+	cases := map[string][]byte{
+		"empty":     {},
+		"version":   append([]byte{99}, state[1:]...),
+		"truncated": state[:len(state)-1],
+		"trailing":  append(append([]byte{}, state...), 0),
+		"zero run":  patched(zeroRun, MaxHistory+1),
+		"flag":      patched(flag, 2),
+	}
+	for name, s := range cases {
+		if _, err := UnmarshalCompressorState(s); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: expected ErrInvalidState, got %v", name, err)
+		}
+	}
+}
+
+func TestAppendCompressWarmStart(t *testing.T) {
+	packetSize := 90
+	robustness := 3
+	data := generateTestPackets(80, packetSize)
+	split := 60 * packetSize
+
+	first, state, err := CompressWithState(data[:split], packetSize, robustness, 10, 20, 50)
+	if err != nil {
+		t.Fatalf("CompressWithState failed: %v", err)
+	}
+	combined, err := AppendCompress(first, state, data[split:], packetSize)
+	if err != nil {
+		t.Fatalf("AppendCompress failed: %v", err)
+	}
+
+	// A cold start would resend R+1 uncompressed packets
+	warm := len(combined) - len(first)
+	cold, _ := Compress(data[split:], packetSize, robustness, 10, 20, 50)
+	if warm >= len(cold) {
+		t.Errorf("Warm append (%d bytes) not smaller than cold start (%d bytes)", warm, len(cold))
+	}
+
+	decompressed, err := Decompress(combined, packetSize, robustness)
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("Round-trip mismatch")
+	}
+}
+
+// compressPackets compresses the packets of data with comp, continuing its
+// parameter schedule.
+func compressPackets(t *testing.T, comp *Compressor, data []byte, packetSize int) []byte {
+	t.Helper()
+	out, err := comp.compressAppend(nil, data, packetSize)
+	if err != nil {
+		t.Fatalf("Compression failed: %v", err)
+	}
+	return out
+}
+
+func TestMarshalStatePadBit(t *testing.T) {
+	packetSize := 9 // 72 bits, so most packets end with padding
+	data := generateTestPackets(8, packetSize)
+	split := 3 * packetSize
+
+	comp, _ := newStreamCompressor(packetSize, 1, 10, 20, 50)
+	comp.SetPadBit(1)
+	compressPackets(t, comp, data[:split], packetSize)
+	state := comp.MarshalState()
+
+	restored, err := UnmarshalCompressorState(state)
+	if err != nil {
+		t.Fatalf("UnmarshalCompressorState failed: %v", err)
+	}
+	want := compressPackets(t, comp, data[split:], packetSize)
+	if got := compressPackets(t, restored, data[split:], packetSize); !bytes.Equal(got, want) {
+		t.Error("Restored compressor output differs from the original")
+	}
+}
+
+func TestMarshalStateExtensions(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(40, packetSize)
+	split := 17 * packetSize
+
+	mask, _ := NewBitVector(packetSize * 8)
+	mask.FromBytes([]byte{0, 0x7F, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10})
+
+	setups := map[string]func(*Compressor){
+		"mask check":     func(comp *Compressor) { _ = comp.SetMaskCheck(5) },
+		"extended count": func(comp *Compressor) { comp.SetExtendedCount(true) },
+		"fixed mask":     func(comp *Compressor) { comp.SetFixedMask(true) },
+	}
+	for name, setup := range setups {
+		comp, _ := NewCompressor(packetSize*8, mask, 2, 10, 20, 50)
+		setup(comp)
+		compressPackets(t, comp, data[:split], packetSize)
+		state := comp.MarshalState()
+
+		restored, err := UnmarshalCompressorState(state)
+		if err != nil {
+			t.Fatalf("%s: UnmarshalCompressorState failed: %v", name, err)
+		}
+		if restored.maskCheckInterval != comp.maskCheckInterval ||
+			restored.extendedCount != comp.extendedCount || restored.fixedMask != comp.fixedMask {
+			t.Errorf("%s: extension settings not restored", name)
+		}
+		want := compressPackets(t, comp, data[split:], packetSize)
+		if got := compressPackets(t, restored, data[split:], packetSize); !bytes.Equal(got, want) {
+			t.Errorf("%s: restored compressor output differs from the original", name)
+		}
+	}
+
+	// Unknown extension flags are rejected
+	comp, _ := NewCompressor(packetSize*8, mask, 2, 10, 20, 50)
+	bad := comp.MarshalState()
+	bad[len(bad)-len(comp.stateVectors())*packetSize-1] |= 0x80
+	if _, err := UnmarshalCompressorState(bad); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState for unknown flags, got %v", err)
+	}
+}
+
+func TestMarshalStateMaskResend(t *testing.T) {
+	packetSize := 16
+	data := generateTestPackets(20, packetSize)
+	split := 12 * packetSize
+
+	comp, _ := newStreamCompressor(packetSize, 1, 10, 20, 50)
+	compressPackets(t, comp, data[:split], packetSize)
+	comp.RequestMaskResend()
+
+	restored, err := UnmarshalCompressorState(comp.MarshalState())
+	if err != nil {
+		t.Fatalf("UnmarshalCompressorState failed: %v", err)
+	}
+	if !restored.maskResend {
+		t.Fatal("Pending mask resend not restored")
+	}
+	want := compressPackets(t, comp, data[split:], packetSize)
+	if got := compressPackets(t, restored, data[split:], packetSize); !bytes.Equal(got, want) {
+		t.Error("Restored compressor output differs from the original")
+	}
+}
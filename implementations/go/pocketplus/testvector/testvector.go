@@ -0,0 +1,154 @@
+// Package testvector writes POCKET+ test vectors in the shared
+// cross-implementation layout of the repository's test-vectors directory,
+// so the Go implementation can produce vectors as well as check them.
+package testvector
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tanagraspace/pocket-plus/implementations/go/pocketplus"
+)
+
+// vectorMetadata matches the JSON structure of test vector metadata files.
+type vectorMetadata struct {
+	Name        string `json:"name"`
+	GeneratedAt string `json:"generated_at"`
+	Input       struct {
+		File string `json:"file"`
+		Size int    `json:"size"`
+		MD5  string `json:"md5"`
+		Type string `json:"type"`
+	} `json:"input"`
+	Compression struct {
+		PacketLength int `json:"packet_length"`
+		Parameters   struct {
+			Pt         int `json:"pt"`
+			Ft         int `json:"ft"`
+			Rt         int `json:"rt"`
+			Robustness int `json:"robustness"`
+		} `json:"parameters"`
+	} `json:"compression"`
+	Output struct {
+		Compressed struct {
+			File string `json:"file"`
+			Size int    `json:"size"`
+			MD5  string `json:"md5"`
+		} `json:"compressed"`
+		Decompressed struct {
+			File string `json:"file"`
+			Size int    `json:"size"`
+			MD5  string `json:"md5"`
+		} `json:"decompressed"`
+	} `json:"output"`
+	Results struct {
+		CompressionRatio  float64 `json:"compression_ratio"`
+		RoundtripVerified bool    `json:"roundtrip_verified"`
+	} `json:"results"`
+}
+
+// TestVectorOptions describes the input of a test vector. Zero fields take
+// the defaults used for generated vectors.
+type TestVectorOptions struct {
+	InputType      string // Metadata input type, e.g. "real_data" (default "synthetic")
+	InputFile      string // Input file name (default <name>.bin)
+	CompressedFile string // Compressed file name (default <input file>.pkt)
+}
+
+// WriteTestVector writes a generated test vector in the shared
+// cross-implementation layout to dir: the input as <name>.bin, the
+// compressed output as <name>.bin.pkt, the decompressed output as
+// <name>.bin.pkt.depkt and <name>-metadata.json describing them.
+//
+// The metadata matches the files produced by the test vector generator,
+// including the compression ratio truncated to two decimals. An error is
+// returned, and no metadata written, if the round trip does not reproduce
+// the input.
+func WriteTestVector(dir, name string, input []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int) error {
+	return WriteTestVectorWithOptions(dir, name, input, packetSize, robustness, ptLimit, ftLimit, rtLimit,
+		TestVectorOptions{})
+}
+
+// WriteTestVectorWithOptions is WriteTestVector with the input type and
+// file names taken from opts, e.g. for a vector of real telemetry.
+func WriteTestVectorWithOptions(dir, name string, input []byte, packetSize, robustness, ptLimit, ftLimit, rtLimit int,
+	opts TestVectorOptions) error {
+	if len(input) == 0 {
+		return errors.New("input must not be empty")
+	}
+
+	compressed, err := pocketplus.Compress(input, packetSize, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return err
+	}
+	decompressed, err := pocketplus.Decompress(compressed, packetSize, robustness)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(decompressed, input) {
+		return errors.New("round trip does not reproduce the input")
+	}
+
+	var metadata vectorMetadata
+	metadata.Name = name
+	metadata.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	metadata.Input.File = name + ".bin"
+	if opts.InputFile != "" {
+		metadata.Input.File = opts.InputFile
+	}
+	metadata.Input.Size = len(input)
+	metadata.Input.MD5 = md5Hex(input)
+	metadata.Input.Type = "synthetic"
+	if opts.InputType != "" {
+		metadata.Input.Type = opts.InputType
+	}
+	metadata.Compression.PacketLength = packetSize
+	metadata.Compression.Parameters.Pt = ptLimit
+	metadata.Compression.Parameters.Ft = ftLimit
+	metadata.Compression.Parameters.Rt = rtLimit
+	metadata.Compression.Parameters.Robustness = robustness
+	metadata.Output.Compressed.File = metadata.Input.File + ".pkt"
+	if opts.CompressedFile != "" {
+		metadata.Output.Compressed.File = opts.CompressedFile
+	}
+	metadata.Output.Compressed.Size = len(compressed)
+	metadata.Output.Compressed.MD5 = md5Hex(compressed)
+	metadata.Output.Decompressed.File = metadata.Output.Compressed.File + ".depkt"
+	metadata.Output.Decompressed.Size = len(decompressed)
+	metadata.Output.Decompressed.MD5 = md5Hex(decompressed)
+	metadata.Results.CompressionRatio = math.Trunc(float64(len(input))/float64(len(compressed))*100) / 100
+	metadata.Results.RoundtripVerified = true
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{metadata.Input.File, input},
+		{metadata.Output.Compressed.File, compressed},
+		{metadata.Output.Decompressed.File, decompressed},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.data, 0644); err != nil {
+			return err
+		}
+	}
+
+	metadataJSON, err := json.MarshalIndent(&metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+"-metadata.json"), append(metadataJSON, '\n'), 0644)
+}
+
+// md5Hex returns the MD5 hash of data as a hex string.
+func md5Hex(data []byte) string {
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
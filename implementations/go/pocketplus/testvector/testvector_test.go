@@ -0,0 +1,111 @@
+package testvector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testVectorsPath is the path to the shared test vectors directory.
+const testVectorsPath = "../../../../test-vectors"
+
+// loadMetadata loads the reference metadata of the named vector.
+func loadMetadata(name string) (*vectorMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(testVectorsPath, "expected-output", name+"-metadata.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata vectorMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+func TestWriteTestVector(t *testing.T) {
+	// Generated vectors use the default names and type
+	runWriteTestVector(t, "simple", "simple.bin", func(dir string, input []byte, m *vectorMetadata) error {
+		params := m.Compression.Parameters
+		return WriteTestVector(dir, "simple", input, m.Compression.PacketLength,
+			params.Robustness, params.Pt, params.Ft, params.Rt)
+	})
+}
+
+func TestWriteTestVectorWithOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping venus-express in short mode (151K packets)")
+	}
+	// Real telemetry keeps its own file names and is typed as real data
+	opts := TestVectorOptions{
+		InputType:      "real_data",
+		InputFile:      "1028packets.ccsds",
+		CompressedFile: "venus-express.ccsds.pkt",
+	}
+	runWriteTestVector(t, "venus-express", "venus-express.ccsds", func(dir string, input []byte, m *vectorMetadata) error {
+		params := m.Compression.Parameters
+		return WriteTestVectorWithOptions(dir, "venus-express", input, m.Compression.PacketLength,
+			params.Robustness, params.Pt, params.Ft, params.Rt, opts)
+	})
+}
+
+// runWriteTestVector writes the named vector from inputFile with write and
+// checks the files and metadata against the reference.
+func runWriteTestVector(t *testing.T, name, inputFile string,
+	write func(dir string, input []byte, m *vectorMetadata) error) {
+	t.Helper()
+
+	expected, err := loadMetadata(name)
+	if err != nil {
+		t.Skipf("Skipping: could not load metadata: %v", err)
+	}
+	input, err := os.ReadFile(filepath.Join(testVectorsPath, "input", inputFile))
+	if err != nil {
+		t.Skipf("Skipping: could not load input: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := write(dir, input, expected); err != nil {
+		t.Fatalf("Writing the test vector failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name+"-metadata.json"))
+	if err != nil {
+		t.Fatalf("Metadata not written: %v", err)
+	}
+	var written vectorMetadata
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Invalid metadata JSON: %v", err)
+	}
+
+	// Everything but the timestamp must match the reference metadata
+	written.GeneratedAt = expected.GeneratedAt
+	if written != *expected {
+		t.Errorf("Metadata mismatch:\n got %+v\nwant %+v", written, *expected)
+	}
+
+	for _, f := range []struct{ file, md5 string }{
+		{written.Input.File, written.Input.MD5},
+		{written.Output.Compressed.File, written.Output.Compressed.MD5},
+		{written.Output.Decompressed.File, written.Output.Decompressed.MD5},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, f.file))
+		if err != nil {
+			t.Fatalf("%s not written: %v", f.file, err)
+		}
+		if md5Hex(data) != f.md5 {
+			t.Errorf("%s: MD5 does not match metadata", f.file)
+		}
+	}
+}
+
+func TestWriteTestVectorErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteTestVector(dir, "empty", nil, 8, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for empty input")
+	}
+	if err := WriteTestVector(dir, "odd", make([]byte, 12), 8, 1, 10, 20, 50); err == nil {
+		t.Error("Expected error for input that is not whole packets")
+	}
+}
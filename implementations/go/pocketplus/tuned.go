@@ -0,0 +1,90 @@
+package pocketplus
+
+// WorkloadHint selects the bit extraction path of a compressor built with
+// NewCompressorTuned. It only affects speed: the output is the same for
+// every hint.
+type WorkloadHint int
+
+const (
+	// WorkloadAuto measures how many input bits change over the first
+	// AutoTunePackets packets and then picks WorkloadSparse or
+	// WorkloadDense.
+	WorkloadAuto WorkloadHint = iota
+
+	// WorkloadSparse walks the set mask bits one at a time, for
+	// housekeeping data with few unpredictable bits.
+	WorkloadSparse
+
+	// WorkloadDense also copies all-ones mask words 32 bits at a time, for
+	// noisy data whose mask fills up. NewCompressor uses this path.
+	WorkloadDense
+)
+
+// AutoTunePackets is the number of packets WorkloadAuto measures before
+// picking a path. It uses the dense path while measuring.
+const AutoTunePackets = 8
+
+// autoDenseDivisor sets the mean number of input bits changed per packet,
+// F divided by it, from which WorkloadAuto picks the dense path.
+const autoDenseDivisor = 4
+
+// String returns the name of the hint.
+func (h WorkloadHint) String() string {
+	switch h {
+	case WorkloadAuto:
+		return "auto"
+	case WorkloadSparse:
+		return "sparse"
+	case WorkloadDense:
+		return "dense"
+	}
+	return "unknown"
+}
+
+// NewCompressorTuned creates a compressor like NewCompressor, with no
+// initial mask, whose bit extraction path is selected by hint.
+//
+// The hint is a performance setting and is not saved by MarshalState; a
+// restored compressor uses the WorkloadDense path.
+func NewCompressorTuned(F, robustness, ptLimit, ftLimit, rtLimit int, hint WorkloadHint) (*Compressor, error) {
+	comp, err := NewCompressor(F, nil, robustness, ptLimit, ftLimit, rtLimit)
+	if err != nil {
+		return nil, err
+	}
+	comp.workload = hint
+	comp.Reset()
+	return comp, nil
+}
+
+// Workload returns the extraction path in use: WorkloadSparse or
+// WorkloadDense, or WorkloadAuto while the first packets are measured.
+func (comp *Compressor) Workload() WorkloadHint {
+	if comp.workload == WorkloadAuto && comp.t < AutoTunePackets {
+		return WorkloadAuto
+	}
+	if comp.denseWords {
+		return WorkloadDense
+	}
+	return WorkloadSparse
+}
+
+// resetWorkload restarts the WorkloadAuto measurement and selects the path
+// for the configured hint.
+func (comp *Compressor) resetWorkload() {
+	comp.tuneChanges = 0
+	comp.denseWords = comp.workload != WorkloadSparse
+}
+
+// observeWorkload records how many bits of input changed from the
+// previous packet and, for WorkloadAuto, picks the path after the last
+// measured packet.
+func (comp *Compressor) observeWorkload(input *BitVector) {
+	if comp.workload != WorkloadAuto || comp.t >= AutoTunePackets || comp.t == 0 {
+		return
+	}
+	changed, _ := input.HammingDistance(comp.prevInput)
+	comp.tuneChanges += changed
+	if comp.t == AutoTunePackets-1 {
+		comp.denseWords = comp.tuneChanges*autoDenseDivisor >= comp.F*(AutoTunePackets-1)
+	}
+}
@@ -0,0 +1,117 @@
+package pocketplus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// compressTuned compresses data with a compressor tuned by hint, following
+// the Compress schedule.
+func compressTuned(t *testing.T, data []byte, packetSize int, hint WorkloadHint) ([]byte, *Compressor) {
+	t.Helper()
+	comp, err := NewCompressorTuned(packetSize*8, 2, 10, 20, 50, hint)
+	if err != nil {
+		t.Fatalf("NewCompressorTuned failed: %v", err)
+	}
+	return compressPackets(t, comp, data, packetSize), comp
+}
+
+func TestCompressTunedOutput(t *testing.T) {
+	packetSize := 90
+	inputs := map[string][]byte{
+		"sparse": generateTelemetry(40, packetSize, 0.001, 1),
+		"dense":  generateTelemetry(40, packetSize, 0.5, 2),
+	}
+
+	// Every hint produces the Compress output
+	for name, data := range inputs {
+		want, _ := Compress(data, packetSize, 2, 10, 20, 50)
+		for _, hint := range []WorkloadHint{WorkloadAuto, WorkloadSparse, WorkloadDense} {
+			if got, _ := compressTuned(t, data, packetSize, hint); !bytes.Equal(got, want) {
+				t.Errorf("%s data, %v hint: output differs from Compress", name, hint)
+			}
+		}
+	}
+}
+
+func TestCompressTunedAuto(t *testing.T) {
+	packetSize := 90
+	tests := []struct {
+		name    string
+		density float64
+		want    WorkloadHint
+	}{
+		{"sparse", 0.001, WorkloadSparse},
+		{"dense", 0.5, WorkloadDense},
+	}
+
+	for _, tt := range tests {
+		data := generateTelemetry(20, packetSize, tt.density, 1)
+		comp, _ := NewCompressorTuned(packetSize*8, 2, 10, 20, 50, WorkloadAuto)
+
+		// Measuring until AutoTunePackets packets have been seen
+		compressPackets(t, comp, data[:(AutoTunePackets-1)*packetSize], packetSize)
+		if got := comp.Workload(); got != WorkloadAuto {
+			t.Errorf("%s: Workload() = %v while measuring, expected auto", tt.name, got)
+		}
+		compressPackets(t, comp, data[(AutoTunePackets-1)*packetSize:], packetSize)
+		if got := comp.Workload(); got != tt.want {
+			t.Errorf("%s: Workload() = %v, expected %v", tt.name, got, tt.want)
+		}
+
+		// Reset measures again
+		comp.Reset()
+		if got := comp.Workload(); got != WorkloadAuto {
+			t.Errorf("%s: Workload() = %v after Reset, expected auto", tt.name, got)
+		}
+	}
+
+	// Fixed hints and NewCompressor do not measure
+	sparse, _ := NewCompressorTuned(720, 2, 10, 20, 50, WorkloadSparse)
+	plain, _ := NewCompressor(720, nil, 2, 10, 20, 50)
+	if sparse.Workload() != WorkloadSparse || plain.Workload() != WorkloadDense {
+		t.Errorf("Workload() = %v and %v, expected sparse and dense", sparse.Workload(), plain.Workload())
+	}
+}
+
+func BenchmarkCompressTuned(b *testing.B) {
+	vectors := []struct {
+		name       string
+		file       string
+		packetSize int
+	}{
+		{"simple", "simple.bin", 90},
+		{"hiro", "hiro.bin", 90},
+		{"housekeeping", "housekeeping.bin", 90},
+		{"venus-express", "venus-express.ccsds", 90},
+		{"noisy", "", 90},
+	}
+
+	for _, v := range vectors {
+		// Noisy synthetic data, whose mask saturates, has no file
+		input := generateTelemetry(2000, v.packetSize, 0.5, 1)
+		if v.file != "" {
+			var err error
+			if input, err = os.ReadFile(filepath.Join(getTestVectorsPath(), "input", v.file)); err != nil {
+				b.Skipf("Test vector not found: %v", err)
+			}
+		}
+		for _, hint := range []WorkloadHint{WorkloadAuto, WorkloadSparse, WorkloadDense} {
+			b.Run(fmt.Sprintf("%s/%v", v.name, hint), func(b *testing.B) {
+				comp, _ := NewCompressorTuned(v.packetSize*8, 2, 20, 50, 100, hint)
+				b.SetBytes(int64(len(input)))
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					comp.Reset()
+					if _, err := comp.compressAppend(nil, input, v.packetSize); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -103,10 +103,26 @@ func computeMD5(data []byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// runTestVector runs a single test vector.
-func runTestVector(t *testing.T, name string) {
+// strictVectorsEnv names the environment variable that makes every test
+// vector fail on compressed or round-trip MD5 mismatches.
+const strictVectorsEnv = "POCKETPLUS_STRICT_VECTORS"
+
+// strictVectors reports whether POCKETPLUS_STRICT_VECTORS=1 is set.
+func strictVectors() bool {
+	return os.Getenv(strictVectorsEnv) == "1"
+}
+
+// runTestVector runs a single test vector. With strict set, compressed and
+// round-trip MD5 mismatches fail the test; otherwise they are only logged.
+func runTestVector(t *testing.T, name string, strict bool) {
 	t.Helper()
 
+	// Mismatches are errors in strict mode and notes otherwise
+	check := t.Logf
+	if strict {
+		check = t.Errorf
+	}
+
 	// Load metadata
 	metadata, err := loadTestVectorMetadata(name)
 	if err != nil {
@@ -158,62 +174,59 @@ func runTestVector(t *testing.T, name string) {
 		name, len(input), len(compressed), len(expectedOutput))
 	t.Logf("%s: compressed MD5: %s (expected: %s)", name, compressedMD5, expectedMD5)
 
-	// For now, just verify compression produces output
-	// MD5 matching will come after ensuring bit-exact compatibility
 	if len(compressed) == 0 {
 		t.Error("Compression produced empty output")
 		return
 	}
+	if compressedMD5 != expectedMD5 {
+		check("Compressed MD5 mismatch: expected %s, got %s", expectedMD5, compressedMD5)
+	}
 
 	// Decompress and verify round-trip
-	decompressed, err := Decompress(compressed, metadata.Compression.PacketLength, params.Robustness)
+	decompressed, err := DecompressExact(compressed, metadata.Compression.PacketLength,
+		params.Robustness, metadata.Input.Size)
 	if err != nil {
-		t.Logf("Decompression failed (expected for now): %v", err)
-		// Don't fail the test yet - we're still developing
+		if strict {
+			t.Fatalf("Decompression failed: %v", err)
+		}
+		t.Logf("Decompression failed: %v", err)
 		return
 	}
 
 	// Verify round-trip
 	decompressedMD5 := computeMD5(decompressed)
 	if decompressedMD5 != metadata.Input.MD5 {
-		t.Logf("Round-trip MD5 mismatch: expected %s, got %s", metadata.Input.MD5, decompressedMD5)
-		// Don't fail the test yet - we're still developing
+		check("Round-trip MD5 mismatch: expected %s, got %s", metadata.Input.MD5, decompressedMD5)
 	} else {
 		t.Logf("%s: Round-trip verified successfully", name)
 	}
-
-	// Only check MD5 match if we're confident in the implementation
-	// Uncomment when ready:
-	// if compressedMD5 != expectedMD5 {
-	//     t.Errorf("Compressed MD5 mismatch: expected %s, got %s", expectedMD5, compressedMD5)
-	// }
 }
 
 func TestVectorSimple(t *testing.T) {
-	runTestVector(t, "simple")
+	runTestVector(t, "simple", true)
 }
 
 func TestVectorHiro(t *testing.T) {
-	runTestVector(t, "hiro")
+	runTestVector(t, "hiro", true)
 }
 
 func TestVectorEdgeCases(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping edge-cases in short mode")
 	}
-	runTestVector(t, "edge-cases")
+	runTestVector(t, "edge-cases", strictVectors())
 }
 
 func TestVectorHousekeeping(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping housekeeping in short mode (10K packets)")
 	}
-	runTestVector(t, "housekeeping")
+	runTestVector(t, "housekeeping", strictVectors())
 }
 
 func TestVectorVenusExpress(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping venus-express in short mode (151K packets)")
 	}
-	runTestVector(t, "venus-express")
+	runTestVector(t, "venus-express", strictVectors())
 }